@@ -0,0 +1,39 @@
+package todo
+
+import "time"
+
+// Описываем удаление аккаунта пользователя.
+// Запрос на удаление (DELETE /api/me) подтверждается паролем и выполняется
+// не сразу, а по истечении отсрочки, в течение которой его можно отменить.
+// При удалении списки, которыми пользователь владеет один, удаляются вместе с задачами,
+// владельцем общих списков становится другой участник, а авторство пользователя
+// в оставшихся списках и задачах обезличивается (created_by и updated_by обнуляются).
+// Запрос, отмена и само удаление записываются в журнал аудита
+
+// действия журнала аудита
+const (
+	AuditDeletionRequested = "account.deletion_requested"
+	AuditDeletionCancelled = "account.deletion_cancelled"
+	AuditAccountDeleted    = "account.deleted"
+)
+
+// состояние удаления аккаунта, nil-поля - удаление не запрошено
+type AccountDeletion struct {
+	RequestedAt *time.Time `json:"requested_at" db:"deletion_requested_at"`
+	ScheduledAt *time.Time `json:"scheduled_at" db:"deletion_scheduled_at"`
+}
+
+// данные запроса на удаление аккаунта
+type DeleteAccountInput struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// итоги удаления аккаунта для журнала аудита:
+// удаленные списки и задачи, списки, переданные другим участникам,
+// и списки, из участников которых пользователь исключен
+type AccountDeletionReport struct {
+	ListsDeleted     int `json:"lists_deleted"`
+	ItemsDeleted     int `json:"items_deleted"`
+	ListsTransferred int `json:"lists_transferred"`
+	MembershipsLeft  int `json:"memberships_left"`
+}
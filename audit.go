@@ -0,0 +1,38 @@
+package todo
+
+import "time"
+
+// Описываем поля аудита: когда и кем запись создана и изменена в последний раз.
+// Встраивается в структуры пользователя, списка и задачи,
+// заполняется слоем repository, клиент не может задать эти поля.
+// CreatedBy и UpdatedBy равны nil, если пользователь удален.
+type Audit struct {
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	CreatedBy *int      `json:"created_by" db:"created_by"`
+	UpdatedBy *int      `json:"updated_by" db:"updated_by"`
+}
+
+// метод сравнения полей аудита,
+// используется для проверки неизменности полей при применении патча
+func (a Audit) Equal(b Audit) bool {
+	return a.CreatedAt.Equal(b.CreatedAt) && a.UpdatedAt.Equal(b.UpdatedAt) &&
+		equalIds(a.CreatedBy, b.CreatedBy) && equalIds(a.UpdatedBy, b.UpdatedBy)
+}
+
+func equalIds(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+// функция сравнения необязательных дат, например completed_at задачи
+func EqualTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
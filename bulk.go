@@ -0,0 +1,87 @@
+package todo
+
+import (
+	"fmt"
+	"time"
+)
+
+// Описываем структуры массовых операций над задачами.
+// Операция применяется к набору задач в одной транзакции,
+// результат возвращается по каждой задаче отдельно.
+const (
+	BulkComplete   = "complete"
+	BulkUncomplete = "uncomplete"
+	BulkDelete     = "delete"
+	BulkMove       = "move"
+	BulkLabel      = "label"
+	BulkSetDueDate = "set_due_date"
+
+	MaxBulkItems = 500
+
+	BulkStatusOk        = "ok"
+	BulkStatusNotFound  = "not_found"
+	BulkStatusForbidden = "forbidden"
+)
+
+// list_id - целевой список для move,
+// labels - добавляемые метки для label,
+// due_date - новый срок для set_due_date (null очищает срок)
+type BulkItemsInput struct {
+	Action  string     `json:"action" binding:"required"`
+	Ids     []int      `json:"ids" binding:"required"`
+	ListId  int        `json:"list_id"`
+	Labels  []string   `json:"labels"`
+	DueDate *time.Time `json:"due_date"`
+}
+
+// метод валидации данных запроса в зависимости от операции,
+// убирает повторяющиеся id
+// используется в хендлере item.go
+func (i *BulkItemsInput) Validate() error {
+	if len(i.Ids) == 0 {
+		return NewValidationError("ids", CodeRequired, "ids are empty")
+	}
+	if len(i.Ids) > MaxBulkItems {
+		return NewValidationError("ids", CodeOutOfRange, fmt.Sprintf("too many ids, max %d", MaxBulkItems))
+	}
+
+	seen := make(map[int]bool, len(i.Ids))
+	ids := make([]int, 0, len(i.Ids))
+	for _, id := range i.Ids {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	i.Ids = ids
+
+	switch i.Action {
+	case BulkComplete, BulkUncomplete, BulkDelete, BulkSetDueDate:
+	case BulkMove:
+		if i.ListId <= 0 {
+			return NewValidationError("list_id", CodeRequired, "list_id is required for move")
+		}
+	case BulkLabel:
+		if len(i.Labels) == 0 {
+			return NewValidationError("labels", CodeRequired, "labels are required for label")
+		}
+		// метки проверяются так же, как при создании и изменении задачи;
+		// общее число меток задачи после добавления проверяется в репозитории
+		labels := Labels(i.Labels)
+		var errs ValidationError
+		errs.labels("labels", &labels)
+		if err := errs.Err(); err != nil {
+			return err
+		}
+		i.Labels = labels
+	default:
+		return NewValidationError("action", CodeUnsupported, "unsupported bulk action")
+	}
+
+	return nil
+}
+
+type BulkItemResult struct {
+	Id     int    `json:"id"`
+	Status string `json:"status"`
+}
@@ -0,0 +1,91 @@
+package todo
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Описываем календари для клиентов CalDAV (Thunderbird, Apple Reminders)
+// и ленты задач в формате iCalendar.
+// Каждый список пользователя - календарь из компонентов VTODO,
+// каждая задача списка - ресурс календаря <name>.ics.
+// Задача, созданная через CalDAV, сохраняет UID и имя ресурса, заданные клиентом,
+// для остальных задач они строятся из id задачи: todo-item-<id> и todo-item-<id>.ics.
+// Лента списка доступна без авторизации по секретной ссылке с токеном,
+// ссылка действует, пока её владелец остается участником списка.
+
+// максимальная длина UID и имени ресурса задачи
+const MaxCalendarNameLength = 255
+
+// календарь: список вместе с ролью пользователя в нем.
+// CTag меняется при любом изменении списка или его задач,
+// по нему клиенты CalDAV определяют, что календарь нужно перечитать
+type Calendar struct {
+	TodoList
+	Role string `json:"role" db:"role"`
+	CTag int64  `json:"-" db:"ctag"`
+}
+
+// задача календаря с UID компонента VTODO и именем ресурса
+type CalendarItem struct {
+	TodoItem
+	Uid  string `json:"uid" db:"uid"`
+	Name string `json:"name" db:"name"`
+}
+
+// UID и имя ресурса по умолчанию для задачи, созданной не через CalDAV
+func DefaultCalendarUid(itemId int) string {
+	return "todo-item-" + strconv.Itoa(itemId)
+}
+
+func DefaultCalendarName(itemId int) string {
+	return DefaultCalendarUid(itemId) + ".ics"
+}
+
+// функция проверяет, что имя ресурса имеет вид имени по умолчанию todo-item-<id>.ics
+func IsDefaultCalendarName(name string) bool {
+	id := strings.TrimSuffix(strings.TrimPrefix(name, "todo-item-"), ".ics")
+	if len(id) == 0 || len(id)+len("todo-item-.ics") != len(name) {
+		return false
+	}
+	for _, r := range id {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// секретная ссылка на ленту списка, токен возвращается только при создании ссылки
+type CalendarFeed struct {
+	ListId int    `json:"list_id"`
+	Token  string `json:"token"`
+	Url    string `json:"url"`
+}
+
+// запрос на сохранение задачи календаря (PUT ресурса CalDAV).
+// Поля задачи заменяются целиком значениями из компонента VTODO.
+// Version - ожидаемая версия из заголовка If-Match,
+// IfExists и IfNotExists - условия If-Match: * и If-None-Match: *
+type PutCalendarItemInput struct {
+	Name        string
+	Item        CalendarItem
+	Version     *int
+	IfExists    bool
+	IfNotExists bool
+}
+
+// метод валидации запроса, поля задачи проверяются как при её создании
+// используется в сервисе calendar.go
+func (i *PutCalendarItemInput) Validate() error {
+	errs := &ValidationError{}
+
+	errs.text("name", &i.Name, true, MaxCalendarNameLength)
+	errs.text("uid", &i.Item.Uid, true, MaxCalendarNameLength)
+	if err := errs.Err(); err != nil {
+		return err
+	}
+
+	return i.Item.Validate()
+}
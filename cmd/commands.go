@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+	"to-do-list/pkg/service"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Команды обслуживания выполняются вместо запуска сервера:
+//
+//	go run ./cmd <команда> [флаги]
+//
+// gc-orphans [-dry-run] - удаление списков без участников вместе с их задачами,
+// с -dry-run списки только находятся. Отчет выводится в stdout в json
+//
+// migrate up [N] | down N | down -all | version | force V - управление версией схемы БД (migrate.go)
+
+var commands = map[string]func(cfg repository.Config, args []string) error{
+	"gc-orphans": gcOrphans,
+	"migrate":    migrateCommand,
+}
+
+// функция выполняет команду обслуживания и завершает приложение.
+// Команда сама открывает нужные ей подключения к БД
+func runCommand(cfg repository.Config, name string, args []string) {
+	command, ok := commands[name]
+	if !ok {
+		logrus.Fatalf("unknown command %q", name)
+	}
+
+	if err := command(cfg, args); err != nil {
+		logrus.Fatalf("command %s failed: %s", name, err.Error())
+	}
+}
+
+func gcOrphans(cfg repository.Config, args []string) error {
+	flags := flag.NewFlagSet("gc-orphans", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "find orphans without deleting them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := repository.NewPostgresDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			logrus.Errorf("error occured on db connection close: %s", err.Error())
+		}
+	}()
+
+	report, err := service.NewMaintenanceService(repository.NewRepository(db)).CollectOrphans(*dryRun)
+	if err != nil {
+		return err
+	}
+
+	output, err := json.MarshalIndent(struct {
+		DryRun bool `json:"dry_run"`
+		todo.OrphanReport
+	}{*dryRun, report}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(os.Stdout, string(output))
+	return err
+}
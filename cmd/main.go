@@ -47,26 +47,118 @@ func main() {
 	// инициализируем базу данных с помощью метода из модуля repository
 	// используем конфигурациооный файл с помощью viper
 	// используем переменную окруженя для получения пароля с помощью godotenv
-	db, err := repository.NewPostgresDB(repository.Config{
+	dbConfig := repository.Config{
 		Host:     viper.GetString("db.host"),
 		Port:     viper.GetString("db.port"),
 		Username: viper.GetString("db.username"),
 		Password: os.Getenv("DB_PASSWORD"),
 		DBName:   viper.GetString("db.dbname"),
 		SSLMode:  viper.GetString("db.sslmode"),
-	})
+	}
+
+	// команды обслуживания (commands.go) выполняются вместо запуска сервера
+	if len(os.Args) > 1 {
+		runCommand(dbConfig, os.Args[1], os.Args[2:])
+		return
+	}
+
+	// проверяем версию схемы БД и применяем новые миграции (migrate.go)
+	if err := migrateOnStart(dbConfig, viper.GetBool("migrations.on_start")); err != nil {
+		logrus.Fatalf("failed to migrate db: %s", err.Error())
+	}
 
+	db, err := repository.NewPostgresDB(dbConfig)
+
+	if err != nil {
+		logrus.Fatalf("failed to initialize db: %s", err.Error())
+	}
+
+	// подписка на уведомления о событиях через LISTEN/NOTIFY
+	// на отдельном соединении с БД
+	listener, err := repository.NewEventListenerPostgres(dbConfig)
 	if err != nil {
-		logrus.Fatal("failed to initialize db: %s", err.Error())
+		logrus.Fatalf("failed to listen for events: %s", err.Error())
 	}
 
 	// Создаем экземпляры основных объектов и объявляем зависимости в нужном порядке
 	// repos зависит от базы данных
 	// services зависит от repos
 	// handlers зависит от services
+	// брокер событий рассылает их подписчикам потока /api/stream,
+	// диспетчер вебхуков отправляет доставки событий из очереди в БД,
+	// ретранслятор публикует сообщения outbox во внешние получатели
 	repos := repository.NewRepository(db)
-	services := service.NewService(repos)
-	handlers := handler.NewHandler(services)
+	broker := service.NewEventBroker(repos.Event, listener, viper.GetDuration("events.retention"))
+	services := service.NewService(repos, service.Config{
+		IdempotencyTTL:      viper.GetDuration("idempotency.ttl"),
+		IdempotencyLease:    viper.GetDuration("idempotency.lease"),
+		Events:              broker,
+		TombstoneRetention:  viper.GetDuration("sync.tombstone_retention"),
+		DeletionGracePeriod: viper.GetDuration("accounts.deletion_grace_period"),
+	})
+	handlers := handler.NewHandler(services, handler.Config{
+		V1DeprecatedAt: viper.GetTime("api.v1_deprecated_at"),
+		V1Sunset:       viper.GetTime("api.v1_sunset"),
+		StreamTimeout:  viper.GetDuration("events.stream_timeout"),
+		ExportTimeout:  viper.GetDuration("export.timeout"),
+	})
+
+	dispatcher := service.NewWebhookDispatcher(repos.Webhook, service.WebhookConfig{
+		PollInterval: viper.GetDuration("webhooks.poll_interval"),
+		Timeout:      viper.GetDuration("webhooks.timeout"),
+		MaxAttempts:  viper.GetInt("webhooks.max_attempts"),
+		DisableAfter: viper.GetInt("webhooks.disable_after"),
+	})
+
+	relay := service.NewOutboxRelay(repos, outboxSinks(), service.OutboxConfig{
+		PollInterval: viper.GetDuration("outbox.poll_interval"),
+		Retention:    viper.GetDuration("outbox.retention"),
+		Lease:        viper.GetDuration("outbox.lease"),
+	})
+
+	importer := service.NewImportRunner(repos, service.ImportConfig{
+		PollInterval: viper.GetDuration("imports.poll_interval"),
+		Lease:        viper.GetDuration("imports.lease"),
+	})
+
+	deleter := service.NewAccountDeleter(repos, service.AccountDeletionConfig{
+		PollInterval: viper.GetDuration("accounts.poll_interval"),
+	})
+
+	brokerCtx, stopBroker := context.WithCancel(context.Background())
+	brokerDone := make(chan struct{})
+	go func() {
+		broker.Run(brokerCtx)
+		close(brokerDone)
+	}()
+
+	dispatcherCtx, stopDispatcher := context.WithCancel(context.Background())
+	dispatcherDone := make(chan struct{})
+	go func() {
+		dispatcher.Run(dispatcherCtx)
+		close(dispatcherDone)
+	}()
+
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+	go func() {
+		relay.Run(relayCtx)
+		close(relayDone)
+	}()
+
+	importerCtx, stopImporter := context.WithCancel(context.Background())
+	importerDone := make(chan struct{})
+	go func() {
+		importer.Run(importerCtx)
+		close(importerDone)
+	}()
+
+	deleterCtx, stopDeleter := context.WithCancel(context.Background())
+	deleterDone := make(chan struct{})
+	go func() {
+		deleter.Run(deleterCtx)
+		close(deleterDone)
+	}()
 
 	// инициализируется экземпляр сервиса
 	srv := new(todo.Server)
@@ -83,7 +175,7 @@ func main() {
 	// все текущие обработки запросов и операции в БД
 	go func() {
 		if err := srv.Run(viper.GetString("port"), handlers.InitRoutes()); err != nil {
-			logrus.Fatal("error occured while running http server: %s", err.Error())
+			logrus.Fatalf("error occured while running http server: %s", err.Error())
 		}
 	}()
 
@@ -99,17 +191,64 @@ func main() {
 	// читаем из канала, блокирующего выполнение главной горутины
 	<-quit
 
+	// останавливаем брокер событий: подписки закрываются,
+	// и открытые потоки событий завершаются, не задерживая остановку сервера
+	stopBroker()
+	<-brokerDone
+
 	// при завершении работы остановим сервер
 	if err := srv.Shutdown(context.Background()); err != nil {
 		logrus.Errorf("error occured on server shutting down: %s", err.Error())
 	}
 
+	// останавливаем диспетчер вебхуков: прерванные доставки
+	// будут повторены после перезапуска
+	stopDispatcher()
+	<-dispatcherDone
+
+	// останавливаем ретранслятор outbox: неопубликованные сообщения
+	// останутся в БД и будут опубликованы после перезапуска
+	stopRelay()
+	<-relayDone
+
+	// останавливаем обработчик импорта: прерванное задание будет
+	// продолжено со следующего списка после истечения аренды
+	stopImporter()
+	<-importerDone
+
+	// останавливаем удаление аккаунтов: аккаунт удаляется в одной транзакции,
+	// незавершенное удаление откатывается и будет выполнено после перезапуска
+	stopDeleter()
+	<-deleterDone
+
 	// закрываем соединение с БД
 	if err := db.Close(); err != nil {
 		logrus.Errorf("error occured on db connection close: %s", err.Error())
 	}
 }
 
+// функция создает получателей сообщений outbox по секции outbox конфига:
+// HTTP и NATS подключаются, если задан адрес. Шина внутри приложения (service.OutboxBus)
+// добавляется сюда вместе с первым обработчиком, который на нее подпишется
+func outboxSinks() []service.OutboxSink {
+	timeout := viper.GetDuration("outbox.timeout")
+	sinks := make([]service.OutboxSink, 0)
+
+	if url := viper.GetString("outbox.http_url"); url != "" {
+		sinks = append(sinks, service.NewHTTPOutboxSink(url, timeout))
+	}
+
+	if url := viper.GetString("outbox.nats_url"); url != "" {
+		sink, err := service.NewNATSOutboxSink(url, viper.GetString("outbox.nats_subject"), timeout)
+		if err != nil {
+			logrus.Fatalf("failed to initialize nats outbox sink: %s", err.Error())
+		}
+		sinks = append(sinks, sink)
+	}
+
+	return sinks
+}
+
 // инициализируем конфигурационные файлы с помощью viper
 func initConfig() error {
 	viper.AddConfigPath("configs")
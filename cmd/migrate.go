@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"to-do-list/pkg/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Миграции схемы БД встроены в бинарный файл (пакет schema) и применяются
+// при запуске сервера, если в секции migrations конфига включен on_start,
+// или командой migrate:
+//
+//	migrate up [N]   - применение всех или N следующих миграций
+//	migrate down N   - откат N последних миграций, down -all - откат всех
+//	migrate version  - текущая версия схемы и последняя версия, известная приложению
+//	migrate force V  - установка версии V без применения миграций после ручного исправления схемы
+
+// функция проверяет схему БД перед запуском сервера и при migrate = true применяет новые миграции.
+// Сервер не запускается на схеме новее, чем известно приложению: при откате приложения
+// на предыдущую версию схему нужно сначала откатить командой migrate down новой версии
+func migrateOnStart(cfg repository.Config, migrate bool) error {
+	migrator, err := repository.NewMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeMigrator(migrator)
+
+	if err := migrator.Check(); err != nil {
+		return err
+	}
+
+	if migrate {
+		// миграции применяются под pg_advisory_lock, поэтому одновременно запущенные
+		// экземпляры приложения ждут первого и не применяют миграции повторно.
+		// Повторная проверка нужна, если за время ожидания схему обновила более новая версия
+		if err := migrator.Up(0); err != nil {
+			return err
+		}
+		return migrator.Check()
+	}
+
+	version, _, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	if version < migrator.Latest() {
+		logrus.Warnf("database schema version %d is behind the latest version %d, run migrate up", version, migrator.Latest())
+	}
+
+	return nil
+}
+
+func migrateCommand(cfg repository.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up [N] | down N | down -all | version | force V")
+	}
+
+	migrator, err := repository.NewMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeMigrator(migrator)
+
+	switch args[0] {
+	case "up":
+		steps := 0
+		if len(args) > 1 {
+			if steps, err = migrateSteps(args[1]); err != nil {
+				return err
+			}
+		}
+		if err := migrator.Up(steps); err != nil {
+			return err
+		}
+	case "down":
+		// откат без числа шагов не выполняется, чтобы случайно не удалить все данные
+		if len(args) < 2 {
+			return errors.New("usage: migrate down N | down -all")
+		}
+		steps := 0
+		if args[1] != "-all" {
+			if steps, err = migrateSteps(args[1]); err != nil {
+				return err
+			}
+		}
+		if err := migrator.Down(steps); err != nil {
+			return err
+		}
+	case "version":
+	case "force":
+		if len(args) < 2 {
+			return errors.New("usage: migrate force V")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := migrator.Force(version); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+
+	// после каждой команды выводится версия схемы
+	version, dirty, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(os.Stdout, "version: %d\nlatest: %d\ndirty: %t\n", version, migrator.Latest(), dirty)
+	return err
+}
+
+func migrateSteps(value string) (int, error) {
+	steps, err := strconv.Atoi(value)
+	if err != nil || steps <= 0 {
+		return 0, fmt.Errorf("invalid number of steps %q", value)
+	}
+
+	return steps, nil
+}
+
+func closeMigrator(migrator *repository.Migrator) {
+	if err := migrator.Close(); err != nil {
+		logrus.Errorf("error occured on migrations db connection close: %s", err.Error())
+	}
+}
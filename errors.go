@@ -0,0 +1,95 @@
+package todo
+
+import (
+	"errors"
+	"strings"
+)
+
+// Описываем ошибки предметной области, которые возвращаются
+// из репозиториев и сервисов и обрабатываются в хендлерах.
+// Хендлеры определяют по ним статус ответа и машиночитаемый код ошибки,
+// поэтому проверять их нужно через errors.Is.
+
+// общие виды ошибок:
+// ErrNotFound - запись не существует или недоступна пользователю,
+// ErrForbidden - у пользователя недостаточно прав на действие с записью,
+// ErrConflict - запись противоречит уже существующим (например, занятое имя пользователя),
+// ErrValidation - некорректные входные данные, подробности по полям - в ValidationError,
+// ErrUnauthorized - неверные учетные данные или токен
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrForbidden    = errors.New("forbidden")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+// ошибка возвращается, если версия записи не совпала с ожидаемой (заголовок If-Match)
+var ErrVersionConflict = errors.New("version conflict")
+
+// ошибки PATCH-запросов:
+// неподдерживаемый формат патча, некорректный патч, непрошедшая операция test
+// и документ, не соответствующий схеме после применения патча
+var (
+	ErrUnsupportedPatch = errors.New("unsupported patch media type")
+	ErrInvalidPatch     = errors.New("invalid patch")
+	ErrPatchTestFailed  = errors.New("patch test operation failed")
+	ErrInvalidDocument  = errors.New("patched document is invalid")
+)
+
+// ошибка валидации одного поля:
+// field - имя поля в json (пустое для запроса в целом), code - машиночитаемый код, message - описание
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// коды ошибок валидации полей
+const (
+	CodeRequired    = "required"
+	CodeInvalid     = "invalid"
+	CodeOutOfRange  = "out_of_range"
+	CodeUnsupported = "unsupported"
+)
+
+// ValidationError содержит ошибки валидации по полям,
+// errors.Is(err, ErrValidation) для нее возвращает true
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// конструктор ошибки валидации с одним полем
+func NewValidationError(field, code, message string) *ValidationError {
+	return &ValidationError{Fields: []FieldError{{Field: field, Code: code, Message: message}}}
+}
+
+// метод добавляет ошибку поля, используется для накопления ошибок в методах Validate
+func (e *ValidationError) Add(field, code, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Code: code, Message: message})
+}
+
+// метод возвращает nil, если ошибок не накоплено
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		if field.Field == "" {
+			messages = append(messages, field.Message)
+			continue
+		}
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+
+	return ErrValidation.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
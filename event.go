@@ -0,0 +1,72 @@
+package todo
+
+import "time"
+
+// Описываем события об изменении списков и задач.
+// События публикуются слоем service в одной транзакции с изменением
+// и доставляются клиентам в потоке /api/stream (Server-Sent Events).
+// Получатели события - участники списка на момент публикации,
+// поэтому удаленный список или исключенный участник тоже получают событие.
+const (
+	EventListCreated = "list.created"
+	EventListUpdated = "list.updated"
+	EventListDeleted = "list.deleted"
+	// изменился состав участников или их роли
+	EventListMembers = "list.members_updated"
+
+	EventItemCreated = "item.created"
+	EventItemUpdated = "item.updated"
+	EventItemDeleted = "item.deleted"
+	// задача переведена в выполненные, публикуется вместе с item.updated
+	EventItemCompleted = "item.completed"
+	// задача перенесена из списка FromListId в список ListId
+	EventItemMoved = "item.moved"
+)
+
+// все типы событий, используются для проверки фильтров вебхуков
+var AllEventTypes = []string{
+	EventListCreated, EventListUpdated, EventListDeleted, EventListMembers,
+	EventItemCreated, EventItemUpdated, EventItemDeleted, EventItemCompleted, EventItemMoved,
+}
+
+// UserId - пользователь, выполнивший изменение.
+// Recipients заполняется только при чтении события для рассылки
+type Event struct {
+	Id         int64     `json:"id" db:"id"`
+	Type       string    `json:"type" db:"type"`
+	ListId     int       `json:"list_id" db:"list_id"`
+	ItemId     *int      `json:"item_id,omitempty" db:"item_id"`
+	FromListId *int      `json:"from_list_id,omitempty" db:"from_list_id"`
+	UserId     int       `json:"user_id" db:"user_id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	Recipients []int     `json:"-" db:"-"`
+}
+
+// функция создает событие списка
+func NewListEvent(eventType string, userId, listId int) Event {
+	return Event{Type: eventType, UserId: userId, ListId: listId}
+}
+
+// функция создает событие задачи, listId - список, в котором находится задача
+func NewItemEvent(eventType string, userId, listId, itemId int) Event {
+	return Event{Type: eventType, UserId: userId, ListId: listId, ItemId: &itemId}
+}
+
+// метод проверяет, должен ли пользователь получить событие
+func (e Event) SentTo(userId int) bool {
+	for _, id := range e.Recipients {
+		if id == userId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// событие потока /api/stream с курсором - снимком транзакций (txid_current_snapshot()):
+// клиент, получивший событие, получил все свои события, транзакции которых видны в снимке Cursor.
+// Курсор передается клиенту в поле id события и возвращается им в Last-Event-ID
+type StreamEvent struct {
+	Event
+	Cursor string
+}
@@ -0,0 +1,45 @@
+package todo
+
+import "time"
+
+// Описываем выгрузку всех данных пользователя (GET /api/me/export):
+// списки, в которых пользователь участвует, с его ролью и задачами списков вместе с метками.
+// Выгрузка читается из БД потоком, формат документа задает обработчик (handler/export.go).
+// Выгрузка в json - документ ExportDocument, который можно загрузить обратно
+// через импорт с источником to_do_list (service/import_native.go).
+// Комментариев к задачам в сервисе нет, поэтому в выгрузке их тоже нет
+
+// форматы выгрузки
+const (
+	ExportJSON     = "json"
+	ExportCSV      = "csv"
+	ExportMarkdown = "markdown"
+)
+
+var ExportFormats = []string{ExportJSON, ExportCSV, ExportMarkdown}
+
+// признак и версия формата json-выгрузки, по ним импорт узнает документ
+const (
+	ExportDocumentFormat  = "to-do-list-export"
+	ExportDocumentVersion = 1
+)
+
+// список выгрузки вместе с ролью пользователя в нем
+type ExportList struct {
+	TodoList
+	Role string `json:"role" db:"role"`
+}
+
+// json-выгрузка. Документ пишется потоком, структуры описывают его целиком
+// и используются для чтения документа при импорте
+type ExportDocument struct {
+	Format     string               `json:"format"`
+	Version    int                  `json:"version"`
+	ExportedAt time.Time            `json:"exported_at"`
+	Lists      []ExportDocumentList `json:"lists"`
+}
+
+type ExportDocumentList struct {
+	ExportList
+	Items []TodoItem `json:"items"`
+}
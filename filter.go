@@ -0,0 +1,129 @@
+package todo
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Описываем структуры сохраненных фильтров ("умных списков").
+// Фильтр хранится в БД в колонке jsonb и применяется к задачам
+// из всех списков, к которым у пользователя есть доступ.
+type SavedFilter struct {
+	Id     int              `json:"id" db:"id"`
+	Name   string           `json:"name" db:"name" binding:"required"`
+	Filter FilterExpression `json:"filter" db:"filter"`
+}
+
+// метод валидации сохраненного фильтра при создании
+// используется в сервисе saved_filter.go
+func (f *SavedFilter) Validate() error {
+	errs := &ValidationError{}
+
+	errs.text("name", &f.Name, true, MaxTextLength)
+	var filterErrs *ValidationError
+	if errors.As(f.Filter.Validate(), &filterErrs) {
+		errs.Fields = append(errs.Fields, filterErrs.Fields...)
+	}
+
+	return errs.Err()
+}
+
+// выражение фильтра, все заданные условия объединяются через AND:
+// done - статус выполнения,
+// priority - точное значение приоритета (0-3),
+// labels - задача должна содержать все перечисленные метки,
+// due_within_days - срок не позднее чем через N дней (просроченные задачи включаются),
+// list_ids - ограничение набора списков,
+// title - подстрока в названии
+type FilterExpression struct {
+	Done          *bool    `json:"done,omitempty"`
+	Priority      *int     `json:"priority,omitempty"`
+	Labels        []string `json:"labels,omitempty"`
+	DueWithinDays *int     `json:"due_within_days,omitempty"`
+	ListIds       []int    `json:"list_ids,omitempty"`
+	Title         string   `json:"title,omitempty"`
+}
+
+const maxDueWithinDays = 3650
+
+// метод валидации выражения фильтра
+// используется в методах Validate сохраненного фильтра
+func (f FilterExpression) Validate() error {
+	if f.Done == nil && f.Priority == nil && len(f.Labels) == 0 && f.DueWithinDays == nil &&
+		len(f.ListIds) == 0 && f.Title == "" {
+		return NewValidationError("filter", CodeRequired, "filter has no conditions")
+	}
+
+	errs := &ValidationError{}
+
+	if f.Priority != nil && (*f.Priority < PriorityNone || *f.Priority > PriorityHigh) {
+		errs.Add("filter.priority", CodeOutOfRange, fmt.Sprintf("priority must be between %d and %d", PriorityNone, PriorityHigh))
+	}
+
+	for _, label := range f.Labels {
+		if strings.TrimSpace(label) == "" {
+			errs.Add("filter.labels", CodeInvalid, "labels must not be empty")
+			break
+		}
+	}
+
+	if f.DueWithinDays != nil && (*f.DueWithinDays < 0 || *f.DueWithinDays > maxDueWithinDays) {
+		errs.Add("filter.due_within_days", CodeOutOfRange, fmt.Sprintf("due_within_days must be between 0 and %d", maxDueWithinDays))
+	}
+
+	if utf8.RuneCountInString(f.Title) > MaxTextLength {
+		errs.Add("filter.title", CodeTooLong, fmt.Sprintf("must be at most %d characters", MaxTextLength))
+	}
+
+	for _, id := range f.ListIds {
+		if id <= 0 {
+			errs.Add("filter.list_ids", CodeInvalid, "list_ids must contain positive ids")
+			break
+		}
+	}
+
+	return errs.Err()
+}
+
+// методы Value и Scan позволяют хранить выражение фильтра в колонке jsonb
+func (f FilterExpression) Value() (driver.Value, error) {
+	return json.Marshal(f)
+}
+
+func (f *FilterExpression) Scan(src interface{}) error {
+	data, ok := src.([]byte)
+	if !ok {
+		return errors.New("filter expression must be jsonb")
+	}
+
+	return json.Unmarshal(data, f)
+}
+
+type UpdateSavedFilterInput struct {
+	Name   *string           `json:"name"`
+	Filter *FilterExpression `json:"filter"`
+}
+
+// метод валидации данных запроса на nil, названия и корректность выражения фильтра
+// используется в сервисе saved_filter.go
+func (i *UpdateSavedFilterInput) Validate() error {
+	if i.Name == nil && i.Filter == nil {
+		return NewValidationError("", CodeRequired, "update structure has no values")
+	}
+
+	errs := &ValidationError{}
+	if i.Name != nil {
+		errs.text("name", i.Name, true, MaxTextLength)
+	}
+
+	var filterErrs *ValidationError
+	if i.Filter != nil && errors.As(i.Filter.Validate(), &filterErrs) {
+		errs.Fields = append(errs.Fields, filterErrs.Fields...)
+	}
+
+	return errs.Err()
+}
@@ -0,0 +1,46 @@
+package todo
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Описываем структуру сохраненного ответа для ключа идемпотентности.
+// Первый ответ на POST-запрос с заголовком Idempotency-Key сохраняется
+// для пары (пользователь, ключ) и возвращается повторно на идентичные запросы.
+// StatusCode равен nil, пока первый запрос еще выполняется.
+type IdempotencyRecord struct {
+	Key             string          `db:"key"`
+	RequestHash     string          `db:"request_hash"`
+	StatusCode      *int            `db:"status_code"`
+	ResponseBody    []byte          `db:"response_body"`
+	ResponseHeaders ResponseHeaders `db:"response_headers"`
+	CreatedAt       time.Time       `db:"created_at"`
+}
+
+// заголовки сохраненного ответа, хранятся в колонке jsonb
+type ResponseHeaders map[string]string
+
+func (h ResponseHeaders) Value() (driver.Value, error) {
+	if h == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(map[string]string(h))
+	return string(data), err
+}
+
+func (h *ResponseHeaders) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("response headers must be jsonb")
+	}
+
+	return json.Unmarshal(data, (*map[string]string)(h))
+}
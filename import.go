@@ -0,0 +1,194 @@
+package todo
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Описываем импорт данных из других сервисов.
+// Файл экспорта загружается запросом POST /api/import и сохраняется в задании импорта,
+// которое выполняется в фоне (service/import_runner.go).
+// Импортер источника преобразует файл в списки ImportList:
+// проекты и доски становятся списками, задачи и карточки - задачами.
+// Пункты чек-листов становятся отдельными задачами сразу после своей задачи.
+// Ошибки отдельных задач не прерывают импорт и попадают в отчет задания.
+
+// источники импорта
+const (
+	ImportTodoist   = "todoist"
+	ImportTrello    = "trello"
+	ImportMicrosoft = "microsoft_todo"
+	// json-выгрузка этого сервиса (export.go)
+	ImportNative = "to_do_list"
+)
+
+var ImportSources = []string{ImportTodoist, ImportTrello, ImportMicrosoft, ImportNative}
+
+// статусы задания: succeeded - все списки обработаны (ошибки отдельных задач - в errors),
+// failed - файл не удалось разобрать или импорт прерван ошибкой, причина - в error
+const (
+	ImportPending   = "pending"
+	ImportRunning   = "running"
+	ImportSucceeded = "succeeded"
+	ImportFailed    = "failed"
+)
+
+// максимальный размер загружаемого файла
+const MaxImportFileSize = 20 << 20
+
+// задание импорта и отчет о его выполнении
+type ImportJob struct {
+	Id             int          `json:"id" db:"id"`
+	Source         string       `json:"source" db:"source"`
+	FileName       string       `json:"file_name" db:"file_name"`
+	Status         string       `json:"status" db:"status"`
+	TotalLists     int          `json:"total_lists" db:"total_lists"`
+	ProcessedLists int          `json:"processed_lists" db:"processed_lists"`
+	TotalItems     int          `json:"total_items" db:"total_items"`
+	ProcessedItems int          `json:"processed_items" db:"processed_items"`
+	ImportedItems  int          `json:"imported_items" db:"imported_items"`
+	Errors         ImportErrors `json:"errors" db:"errors"`
+	Error          *string      `json:"error" db:"error"`
+	CreatedAt      time.Time    `json:"created_at" db:"created_at"`
+	StartedAt      *time.Time   `json:"started_at" db:"started_at"`
+	FinishedAt     *time.Time   `json:"finished_at" db:"finished_at"`
+}
+
+// ошибка импорта задачи: List и Item - названия списка и задачи из файла
+type ImportError struct {
+	List    string `json:"list"`
+	Item    string `json:"item,omitempty"`
+	Message string `json:"message"`
+}
+
+// ошибки импорта хранятся в колонке jsonb, в json пустой отчет выводится как []
+type ImportErrors []ImportError
+
+func (e ImportErrors) Value() (driver.Value, error) {
+	if e == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal([]ImportError(e))
+	return string(data), err
+}
+
+func (e *ImportErrors) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("import errors must be jsonb")
+	}
+
+	return json.Unmarshal(data, (*[]ImportError)(e))
+}
+
+func (e ImportErrors) MarshalJSON() ([]byte, error) {
+	if e == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]ImportError(e))
+}
+
+// загруженный файл экспорта
+type ImportInput struct {
+	Source   string
+	FileName string
+	Data     []byte
+}
+
+// метод валидации загруженного файла, содержимое файла проверяется импортером
+// используется в сервисе import.go
+func (i *ImportInput) Validate() error {
+	errs := &ValidationError{}
+
+	if !contains(ImportSources, i.Source) {
+		errs.Add("source", CodeUnsupported, "source must be one of: "+strings.Join(ImportSources, ", "))
+	}
+	if len(i.Data) == 0 {
+		errs.Add("file", CodeRequired, "file is empty")
+	}
+	if len(i.Data) > MaxImportFileSize {
+		errs.Add("file", CodeTooLong, fmt.Sprintf("file must be at most %d bytes", MaxImportFileSize))
+	}
+
+	i.FileName = truncateText(strings.TrimSpace(i.FileName), MaxTextLength)
+
+	return errs.Err()
+}
+
+// список из файла экспорта
+type ImportList struct {
+	Title       string
+	Description string
+	Items       []ImportItem
+}
+
+// задача из файла экспорта, Checklist - пункты чек-листа задачи
+type ImportItem struct {
+	Title       string
+	Description string
+	Done        bool
+	DueDate     *time.Time
+	Priority    int
+	Labels      Labels
+	Checklist   []ImportChecklistItem
+}
+
+type ImportChecklistItem struct {
+	Title string
+	Done  bool
+}
+
+// метод возвращает список для создания, длинные тексты обрезаются до ограничений схемы БД
+func (l ImportList) TodoList() TodoList {
+	return TodoList{
+		Title:       truncateText(strings.TrimSpace(l.Title), MaxTextLength),
+		Description: truncateText(strings.TrimSpace(l.Description), MaxTextLength),
+	}
+}
+
+// метод возвращает задачу для создания: длинные тексты и метки обрезаются,
+// лишние метки отбрасываются. Остальные ошибки выявляются валидацией задачи
+func (i ImportItem) TodoItem() TodoItem {
+	labels := make(Labels, 0, len(i.Labels))
+	for _, label := range i.Labels {
+		if label = strings.TrimSpace(label); label != "" && len(labels) < MaxLabels {
+			labels = append(labels, truncateText(label, MaxLabelLength))
+		}
+	}
+
+	return TodoItem{
+		Title:       truncateText(strings.TrimSpace(i.Title), MaxTextLength),
+		Description: truncateText(strings.TrimSpace(i.Description), MaxTextLength),
+		DueDate:     i.DueDate,
+		Priority:    i.Priority,
+		Labels:      labels,
+	}
+}
+
+// число задач списка вместе с пунктами чек-листов
+func (l ImportList) ItemCount() int {
+	count := len(l.Items)
+	for _, item := range l.Items {
+		count += len(item.Checklist)
+	}
+	return count
+}
+
+// функция обрезает текст до max символов, используется для названий из файлов импорта,
+// которые в других сервисах могут быть длиннее, чем допускает схема БД
+func truncateText(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
@@ -0,0 +1,37 @@
+package todo
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+
+	"github.com/lib/pq"
+)
+
+// Метки задачи. Хранятся в БД в колонке типа text[],
+// для чтения и записи используется pq.StringArray.
+// В json пустой набор меток выводится как [], а не null.
+type Labels []string
+
+func (l Labels) Value() (driver.Value, error) {
+	if l == nil {
+		return "{}", nil
+	}
+	return pq.StringArray(l).Value()
+}
+
+func (l *Labels) Scan(src interface{}) error {
+	var array pq.StringArray
+	if err := array.Scan(src); err != nil {
+		return err
+	}
+
+	*l = Labels(array)
+	return nil
+}
+
+func (l Labels) MarshalJSON() ([]byte, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]string(l))
+}
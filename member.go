@@ -0,0 +1,65 @@
+package todo
+
+// Описываем роли участников списка и структуры для управления участниками.
+// Создатель списка получает роль owner, остальных участников
+// owner добавляет с ролью editor или viewer:
+// viewer - чтение списка и задач,
+// editor - дополнительно изменение списка и работа с задачами,
+// owner - дополнительно удаление списка и управление участниками.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// уровни ролей, более сильная роль включает права более слабых
+var roleLevels = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+// функция проверяет, что роли role достаточно для действия, требующего роль required
+func RoleAllows(role, required string) bool {
+	level, ok := roleLevels[role]
+	return ok && level >= roleLevels[required]
+}
+
+// функция возвращает роли, которым разрешено действие, требующее роль required,
+// используется в условиях запросов к БД
+func RolesAllowing(required string) []string {
+	roles := make([]string, 0, len(roleLevels))
+	for _, role := range []string{RoleViewer, RoleEditor, RoleOwner} {
+		if RoleAllows(role, required) {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
+
+type ListMember struct {
+	UserId   int    `json:"user_id" db:"user_id"`
+	Name     string `json:"name" db:"name"`
+	Username string `json:"username" db:"username"`
+	Role     string `json:"role" db:"role"`
+}
+
+// данные запроса на добавление участника или изменение его роли
+type ListMemberInput struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+// метод валидации данных запроса, роль owner не назначается
+// используется в сервисе list_member.go
+func (i *ListMemberInput) Validate() error {
+	errs := &ValidationError{}
+
+	errs.text("username", &i.Username, true, MaxTextLength)
+	if i.Role != RoleEditor && i.Role != RoleViewer {
+		errs.Add("role", CodeUnsupported, "role must be editor or viewer")
+	}
+
+	return errs.Err()
+}
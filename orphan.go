@@ -0,0 +1,16 @@
+package todo
+
+// Описываем отчет команды gc-orphans, которая находит и удаляет списки без участников,
+// оставшиеся без связей до появления триггеров удаления (schema/000016).
+// Задачи удаляемых списков удаляются вместе с ними и считаются отдельно.
+// Задач без списка не бывает: список задачи - обязательный внешний ключ (schema/000018)
+
+type OrphanReport struct {
+	Lists     []int `json:"lists"`
+	ListItems int   `json:"list_items"`
+}
+
+// метод проверяет, что записей без связей нет
+func (r OrphanReport) Empty() bool {
+	return len(r.Lists) == 0
+}
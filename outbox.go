@@ -0,0 +1,35 @@
+package todo
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Описываем сообщение исходящей очереди (outbox) для внешних получателей:
+// шины внутри приложения, HTTP-endpoint и NATS.
+// Сообщение создается вместе с событием (event.go) и содержит тот же json события.
+// Доставка выполняется не менее одного раза: получатель должен быть готов
+// к повторам и отбрасывать дубликаты по id.
+// Сообщения одного агрегата (списка или задачи) доставляются в порядке id
+type OutboxMessage struct {
+	Id            int64           `json:"id" db:"id"`
+	EventId       int64           `json:"event_id" db:"event_id"`
+	AggregateType string          `json:"aggregate_type" db:"aggregate_type"`
+	AggregateId   int             `json:"aggregate_id" db:"aggregate_id"`
+	Type          string          `json:"type" db:"type"`
+	Payload       json.RawMessage `json:"payload" db:"payload"`
+	Attempts      int             `json:"attempts" db:"attempts"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// типы агрегатов: события задач упорядочиваются по задаче, события списков - по списку
+const (
+	AggregateList = "list"
+	AggregateItem = "item"
+)
+
+// ключ агрегата сообщения, например "item:42"
+func (m OutboxMessage) AggregateKey() string {
+	return m.AggregateType + ":" + strconv.Itoa(m.AggregateId)
+}
@@ -0,0 +1,16 @@
+package todo
+
+// Описываем структуру PATCH-запроса к спискам и задачам.
+// Поддерживаются форматы JSON Merge Patch (RFC 7396) и JSON Patch (RFC 6902),
+// формат определяется по заголовку Content-Type.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// Body - тело патча, Version - ожидаемая версия записи из заголовка If-Match
+type Patch struct {
+	ContentType string
+	Body        []byte
+	Version     *int
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"net/http"
+	todo "to-do-list"
+
+	"github.com/gin-gonic/gin"
+)
+
+// обработчики удаления аккаунта, аккаунт удаляется после отсрочки (service/account_deleter.go)
+
+// описываем данные для swagger
+// @Summary      Delete Account
+// @Security ApiKeyAuth
+// @Description  schedule deletion of the account after the grace period, the deletion can be cancelled until then.
+// @Description  Lists owned solely by the user are deleted with their items, shared lists pass to another member
+// @Tags         account
+// ID delete-account
+// @Accept       json
+// @Produce      json
+// @Param        input body todo.DeleteAccountInput true "password confirmation"
+// @Success      202  {object}  todo.AccountDeletion
+// @Failure      400,403  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/me [delete]
+func (h *Handler) deleteAccount(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	var input todo.DeleteAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newBindErrorResponse(c, err)
+		return
+	}
+
+	deletion, err := h.servicesFrom(c).Account.ScheduleDeletion(userId, input)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, deletion)
+}
+
+// описываем данные для swagger
+// @Summary      Get Account Deletion
+// @Security ApiKeyAuth
+// @Description  get scheduled deletion of the account, null fields mean the deletion is not scheduled
+// @Tags         account
+// ID get-account-deletion
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  todo.AccountDeletion
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/me/deletion [get]
+func (h *Handler) getAccountDeletion(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	deletion, err := h.servicesFrom(c).Account.GetDeletion(userId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deletion)
+}
+
+// описываем данные для swagger
+// @Summary      Cancel Account Deletion
+// @Security ApiKeyAuth
+// @Description  cancel scheduled deletion of the account
+// @Tags         account
+// ID cancel-account-deletion
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  statusResponse
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/me/deletion [delete]
+func (h *Handler) cancelAccountDeletion(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	if err := h.servicesFrom(c).Account.CancelDeletion(userId); err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{
+		Status: "ok",
+	})
+}
@@ -22,28 +22,28 @@ import (
 // @Produce      json
 // @Param        input body todo.User true "account info"
 // @Success      200  {integer}  integer "id"
-// @Failure      400,404  {object}  errorResponse
-// @Failure      500  {object}  errorResponse
-// @Failure      default  {object}  errorResponse
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
 // @Router       /auth/sign-up [post]
 func (h *Handler) signUp(c *gin.Context) {
 	// структура инпута для парсинга из json
 	var input todo.User
 
 	// байндим входные данные в соответствии со структурой todo.User
-	if err := c.BindJSON(&input); err != nil {
+	if err := c.ShouldBindJSON(&input); err != nil {
 		// вызываем созданную нами функцию обработки ошибок (код статуса 400)
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		newBindErrorResponse(c, err)
 		return
 	}
 
 	// передаем данные на слой ниже, в сервис
 	// вызываем сервис авторизации
 	// передаем тело запрсоа. в ответ должен придти id
-	id, err := h.services.Authorization.CreateUser(input)
+	id, err := h.servicesFrom(c).Authorization.CreateUser(input)
 	if err != nil {
 		// обрабатываем ошибку, возвращаем код 500.
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newDomainErrorResponse(c, err)
 		return
 	}
 
@@ -56,8 +56,8 @@ func (h *Handler) signUp(c *gin.Context) {
 
 // структура для парсинга тела запроса из json
 type signInInput struct {
-	Username string `json: "username" binding:"required"`
-	Password string `json: "password" binding:"required"`
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
 }
 
 // метод signIn будет возвращать токен, если пользоватьель найден в БД
@@ -71,23 +71,23 @@ type signInInput struct {
 // @Produce      json
 // @Param        input body signInInput true "credential"
 // @Success      200  {string}  string "token"
-// @Failure      400,404  {object}  errorResponse
-// @Failure      500  {object}  errorResponse
-// @Failure      default  {object}  errorResponse
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
 // @Router       /auth/sign-in [post]
 func (h *Handler) signIn(c *gin.Context) {
 	var input signInInput
 
 	// байндим входные данные
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newBindErrorResponse(c, err)
 		return
 	}
 
 	// вызываем метод создания токена, если ошибка, пишем код 500
-	token, err := h.services.Authorization.GenerateToken(input.Username, input.Password)
+	token, err := h.servicesFrom(c).Authorization.GenerateToken(input.Username, input.Password)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newDomainErrorResponse(c, err)
 		return
 	}
 
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"sync"
+	"time"
+)
+
+// ограничение неудачных попыток Basic-авторизации клиентов CalDAV (мидлвара caldavIdentity):
+// после limit неудач за window запросы с тем же ключом отклоняются до конца окна.
+// Ключи - адрес соединения и логин: подбор пароля останавливается и с одного адреса,
+// и с многих адресов для одного логина. Ограничение логина блокирует только вход через CalDAV,
+// api с токеном остается доступным. Счетчики хранятся в памяти экземпляра приложения
+
+const (
+	// окно подсчета неудачных попыток
+	authFailureWindow = 15 * time.Minute
+	// число неудач с одного адреса и для одного логина
+	maxAuthFailuresPerIP   = 30
+	maxAuthFailuresPerUser = 10
+)
+
+type authLimiter struct {
+	mu        sync.Mutex
+	window    time.Duration
+	failures  map[string]*authFailures
+	lastSweep time.Time
+}
+
+// неудачные попытки ключа с начала окна since
+type authFailures struct {
+	count int
+	since time.Time
+}
+
+func newAuthLimiter(window time.Duration) *authLimiter {
+	return &authLimiter{window: window, failures: make(map[string]*authFailures)}
+}
+
+// метод возвращает время до снятия ограничения ключа, 0 - попытки не ограничены
+func (l *authLimiter) retryAfter(key string, limit int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.failures[key]
+	if !ok || f.count < limit {
+		return 0
+	}
+
+	wait := time.Until(f.since.Add(l.window))
+	if wait <= 0 {
+		delete(l.failures, key)
+		return 0
+	}
+
+	return wait
+}
+
+// метод учитывает неудачную попытку ключей
+func (l *authLimiter) fail(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	for _, key := range keys {
+		f, ok := l.failures[key]
+		if !ok || now.Sub(f.since) >= l.window {
+			f = &authFailures{since: now}
+			l.failures[key] = f
+		}
+		f.count++
+	}
+}
+
+// успешная авторизация сбрасывает счетчик ключа
+func (l *authLimiter) reset(key string) {
+	l.mu.Lock()
+	delete(l.failures, key)
+	l.mu.Unlock()
+}
+
+// удаление счетчиков с истекшим окном, не чаще раза за окно
+func (l *authLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+
+	for key, f := range l.failures {
+		if now.Sub(f.since) >= l.window {
+			delete(l.failures, key)
+		}
+	}
+}
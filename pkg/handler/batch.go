@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	todo "to-do-list"
+	"to-do-list/pkg/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// максимальное количество операций в одном batch-запросе
+const maxBatchOperations = 20
+
+// ошибка для отката транзакции, если одна из операций завершилась неуспешно
+var errBatchOperationFailed = errors.New("batch operation failed")
+
+// структура вложенного запроса
+// headers - дополнительные заголовки (Content-Type, If-Match и т.п.)
+type batchOperation struct {
+	Method  string            `json:"method" binding:"required"`
+	Path    string            `json:"path" binding:"required"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body" swaggertype:"object"`
+}
+
+// структура тела batch-запроса
+// atomic - выполнить все операции в одной транзакции БД
+type batchInput struct {
+	Atomic     bool             `json:"atomic"`
+	Operations []batchOperation `json:"operations" binding:"required"`
+}
+
+// результат вложенного запроса: статус и тело ответа
+type batchResult struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty" swaggertype:"object"`
+}
+
+// rolled_back - транзакция atomic-запроса была откачена,
+// в этом случае результаты содержат операции до первой неуспешной включительно
+type batchResponse struct {
+	Results    []batchResult `json:"results"`
+	RolledBack bool          `json:"rolled_back"`
+}
+
+// описываем данные для swagger
+// @Summary      Batch
+// @Security ApiKeyAuth
+// @Description  run several api requests in one call, optionally in one db transaction
+// @Tags         batch
+// ID batch
+// @Accept       json
+// @Produce      json
+// @Param        input body batchInput true "sub-requests"
+// @Param        Idempotency-Key header string false "key for safe retries of the request"
+// @Success      200  {object}  batchResponse
+// @Failure      400,404,409,422  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/batch [post]
+func (h *Handler) batch(c *gin.Context) {
+	var input batchInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newBindErrorResponse(c, err)
+		return
+	}
+
+	if err := validateBatch(input); err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	results := make([]batchResult, 0, len(input.Operations))
+
+	// без atomic операции выполняются независимо друг от друга
+	if !input.Atomic {
+		for _, op := range input.Operations {
+			results = append(results, h.runBatchOperation(c, c.Request.Context(), op))
+		}
+
+		c.JSON(http.StatusOK, batchResponse{Results: results})
+		return
+	}
+
+	// с atomic вложенные запросы получают через контекст сервисы, работающие в транзакции,
+	// первая неуспешная операция откатывает транзакцию
+	err := h.servicesFrom(c).Transaction(func(services *service.Service) error {
+		ctx := context.WithValue(c.Request.Context(), servicesCtxKey{}, services)
+
+		for _, op := range input.Operations {
+			result := h.runBatchOperation(c, ctx, op)
+			results = append(results, result)
+
+			if result.Status >= http.StatusBadRequest {
+				return errBatchOperationFailed
+			}
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBatchOperationFailed) {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, batchResponse{
+		Results:    results,
+		RolledBack: err != nil,
+	})
+}
+
+// функция проверяет количество операций, методы и пути вложенных запросов
+func validateBatch(input batchInput) error {
+	if len(input.Operations) == 0 {
+		return todo.NewValidationError("operations", todo.CodeRequired, "operations are empty")
+	}
+	if len(input.Operations) > maxBatchOperations {
+		return todo.NewValidationError("operations", todo.CodeOutOfRange, fmt.Sprintf("too many operations, max %d", maxBatchOperations))
+	}
+
+	errs := &todo.ValidationError{}
+	for i, op := range input.Operations {
+		switch op.Method {
+		case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			errs.Add(fmt.Sprintf("operations[%d].method", i), todo.CodeUnsupported, fmt.Sprintf("unsupported method %q", op.Method))
+		}
+
+		// вложенные запросы допускаются только к api, кроме самого batch и потока событий в любой версии
+		if !strings.HasPrefix(op.Path, apiV1Path+"/") || batchExcluded(op.Path) {
+			errs.Add(fmt.Sprintf("operations[%d].path", i), todo.CodeUnsupported, fmt.Sprintf("unsupported path %q", op.Path))
+		}
+	}
+
+	return errs.Err()
+}
+
+// функция проверяет, что путь ведет к endpoint, недоступному в batch
+func batchExcluded(path string) bool {
+	for _, prefix := range []string{apiV1Path, apiV2Path} {
+		if strings.HasPrefix(path, prefix+"/batch") || strings.HasPrefix(path, prefix+"/stream") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// метод выполняет вложенный запрос через роутер приложения,
+// заголовок авторизации копируется из исходного запроса,
+// поэтому вложенный запрос проходит мидлвару userIdentity
+func (h *Handler) runBatchOperation(c *gin.Context, ctx context.Context, op batchOperation) batchResult {
+	var body io.Reader
+	if len(op.Body) > 0 {
+		body = bytes.NewReader(op.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, op.Method, op.Path, body)
+	if err != nil {
+		data, _ := json.Marshal(problemResponse{
+			Type:   "about:blank",
+			Title:  http.StatusText(http.StatusBadRequest),
+			Status: http.StatusBadRequest,
+			Detail: err.Error(),
+			Code:   codeBadRequest,
+		})
+		return batchResult{Status: http.StatusBadRequest, Body: data}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range op.Headers {
+		req.Header.Set(name, value)
+	}
+	// авторизация всегда берется из исходного запроса
+	req.Header.Set(authorizationHeader, c.GetHeader(authorizationHeader))
+
+	recorder := httptest.NewRecorder()
+	h.router.ServeHTTP(recorder, req)
+
+	result := batchResult{Status: recorder.Code}
+	if data := recorder.Body.Bytes(); len(data) > 0 {
+		// тело, не являющееся json, передаем строкой
+		if !json.Valid(data) {
+			data, _ = json.Marshal(string(data))
+		}
+		result.Body = data
+	}
+
+	return result
+}
@@ -0,0 +1,390 @@
+package handler
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	todo "to-do-list"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Минимальный сервер CalDAV (RFC 4791) для клиентов календарей.
+// Клиенты авторизуются логином и паролем пользователя (Basic, мидлвара caldavIdentity).
+// Ресурсы:
+// /caldav/ - principal пользователя, указывает на домашнюю коллекцию календарей;
+// /caldav/lists/ - домашняя коллекция, календари - списки пользователя;
+// /caldav/lists/<id>/ - календарь списка, поддерживает REPORT calendar-query и calendar-multiget;
+// /caldav/lists/<id>/<name> - задача в виде VTODO: GET, PUT и DELETE.
+// ETag задачи - её версия, как в api. Свойства ресурсов изменять нельзя (PROPPATCH, MKCALENDAR не поддерживаются),
+// отчет sync-collection не поддерживается: клиенты определяют изменения календаря по getctag
+
+const (
+	caldavPath      = "/caldav"
+	caldavHomePath  = caldavPath + "/lists/"
+	caldavItemsType = "text/calendar; charset=utf-8; component=vtodo"
+
+	// максимальный размер тела PUT задачи
+	maxCalendarDataSize = 1 << 20
+)
+
+func calendarHref(listId int) string {
+	return caldavHomePath + strconv.Itoa(listId) + "/"
+}
+
+func calendarItemHref(listId int, name string) string {
+	return calendarHref(listId) + url.PathEscape(name)
+}
+
+// ответ на OPTIONS: поддерживаемые методы и возможности сервера,
+// запрос не требует авторизации
+func (h *Handler) caldavOptions(c *gin.Context) {
+	c.Header("DAV", "1, 3, calendar-access")
+	c.Header("Allow", "OPTIONS, GET, PUT, DELETE, PROPFIND, REPORT")
+	c.Status(http.StatusOK)
+}
+
+// обнаружение сервера по адресу /.well-known/caldav (RFC 6764)
+func (h *Handler) caldavWellKnown(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, caldavPath+"/")
+}
+
+// principal пользователя
+func (h *Handler) propfindPrincipal(c *gin.Context) {
+	var body davPropfind
+	if !readDavBody(c, &body) {
+		return
+	}
+
+	principal := davResource{href: caldavPath + "/"}
+	principal.add(davNS, "resourcetype", "<D:collection/><D:principal/>")
+	principal.add(davNS, "displayname", "Todo")
+	addPrincipalProps(&principal)
+
+	writeMultistatus(c, []davResource{principal}, body.Prop.names(), nil)
+}
+
+// домашняя коллекция, с Depth: 1 - вместе с календарями
+func (h *Handler) propfindHome(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	var body davPropfind
+	if !readDavBody(c, &body) {
+		return
+	}
+
+	home := davResource{href: caldavHomePath}
+	home.add(davNS, "resourcetype", "<D:collection/>")
+	home.add(davNS, "displayname", "Lists")
+	addPrincipalProps(&home)
+	resources := []davResource{home}
+
+	if !davDepthZero(c) {
+		calendars, err := h.servicesFrom(c).Calendar.GetCalendars(userId)
+		if err != nil {
+			newDomainErrorResponse(c, err)
+			return
+		}
+		for _, calendar := range calendars {
+			resources = append(resources, calendarResource(calendar))
+		}
+	}
+
+	writeMultistatus(c, resources, body.Prop.names(), nil)
+}
+
+// календарь списка, с Depth: 1 - вместе с задачами
+func (h *Handler) propfindCalendar(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var body davPropfind
+	if !readDavBody(c, &body) {
+		return
+	}
+
+	services := h.servicesFrom(c)
+	calendar, err := services.Calendar.GetCalendar(userId, listId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+	resources := []davResource{calendarResource(calendar)}
+
+	if !davDepthZero(c) {
+		items, err := services.Calendar.GetItems(userId, listId)
+		if err != nil {
+			newDomainErrorResponse(c, err)
+			return
+		}
+		for _, item := range items {
+			resources = append(resources, calendarItemResource(listId, item))
+		}
+	}
+
+	writeMultistatus(c, resources, body.Prop.names(), nil)
+}
+
+// свойства задачи
+func (h *Handler) propfindCalendarItem(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var body davPropfind
+	if !readDavBody(c, &body) {
+		return
+	}
+
+	item, err := h.servicesFrom(c).Calendar.GetItem(userId, listId, c.Param("name"))
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	writeMultistatus(c, []davResource{calendarItemResource(listId, item)}, body.Prop.names(), nil)
+}
+
+// отчеты календаря: calendar-query возвращает все задачи календаря,
+// calendar-multiget - задачи по списку адресов
+func (h *Handler) reportCalendar(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var body davReport
+	if !readDavBody(c, &body) {
+		return
+	}
+
+	query := body.XMLName == xml.Name{Space: caldavNS, Local: "calendar-query"}
+	multiget := body.XMLName == xml.Name{Space: caldavNS, Local: "calendar-multiget"}
+	if !query && !multiget {
+		unsupportedReport(c)
+		return
+	}
+
+	items, err := h.servicesFrom(c).Calendar.GetItems(userId, listId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	resources := make([]davResource, 0, len(items))
+	var missing []string
+	if query {
+		if body.Filter.matchesVTodo() {
+			for _, item := range items {
+				resources = append(resources, calendarItemResource(listId, item))
+			}
+		}
+	} else {
+		byName := make(map[string]todo.CalendarItem, len(items))
+		for _, item := range items {
+			byName[item.Name] = item
+		}
+
+		for _, href := range body.Hrefs {
+			href = strings.TrimSpace(href)
+			item, ok := byName[calendarItemName(listId, href)]
+			if !ok {
+				missing = append(missing, href)
+				continue
+			}
+			resources = append(resources, calendarItemResource(listId, item))
+		}
+	}
+
+	writeMultistatus(c, resources, body.Prop.names(), missing)
+}
+
+// функция возвращает имя ресурса задачи по адресу из calendar-multiget,
+// адрес может быть абсолютным URL. Пустая строка - адрес не из календаря listId
+func calendarItemName(listId int, href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+
+	dir, name := path.Split(u.Path)
+	if dir != calendarHref(listId) {
+		return ""
+	}
+
+	return name
+}
+
+func (h *Handler) getCalendarItem(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	item, err := h.servicesFrom(c).Calendar.GetItem(userId, listId, c.Param("name"))
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	if notModified(c, item.Version) {
+		return
+	}
+
+	c.Data(http.StatusOK, icalContentType, encodeVCalendar("", []todo.CalendarItem{item}))
+}
+
+// сохранение задачи: новый ресурс создает задачу, существующий - заменяет её поля.
+// Условия If-Match и If-None-Match: * проверяются в сервисе.
+// ETag в ответе не возвращается: сохраненная задача содержит не все свойства VTODO,
+// поэтому клиент должен перечитать её (RFC 4791, 5.3.4)
+func (h *Handler) putCalendarItem(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCalendarDataSize+1))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(data) > maxCalendarDataSize {
+		newErrorResponse(c, http.StatusRequestEntityTooLarge, "calendar data is too large")
+		return
+	}
+
+	item, err := decodeVTodo(data)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	input := todo.PutCalendarItemInput{
+		Name:        c.Param("name"),
+		Item:        item,
+		IfExists:    strings.TrimSpace(c.GetHeader("If-Match")) == "*",
+		IfNotExists: strings.TrimSpace(c.GetHeader("If-None-Match")) == "*",
+	}
+	var ok bool
+	if input.Version, ok = parseIfMatch(c); !ok {
+		return
+	}
+
+	_, created, err := h.servicesFrom(c).Calendar.PutItem(userId, listId, input)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	if created {
+		c.Status(http.StatusCreated)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) deleteCalendarItem(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	version, ok := parseIfMatch(c)
+	if !ok {
+		return
+	}
+
+	if err := h.servicesFrom(c).Calendar.DeleteItem(userId, listId, c.Param("name"), version); err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// свойства, по которым клиент находит principal и домашнюю коллекцию
+func addPrincipalProps(resource *davResource) {
+	resource.add(davNS, "current-user-principal", "<D:href>"+caldavPath+"/</D:href>")
+	resource.add(davNS, "principal-URL", "<D:href>"+caldavPath+"/</D:href>")
+	resource.add(caldavNS, "calendar-home-set", "<D:href>"+caldavHomePath+"</D:href>")
+}
+
+// свойства календаря, права пользователя определяются его ролью в списке:
+// viewer только читает задачи, editor и owner также создают, изменяют и удаляют их
+func calendarResource(calendar todo.Calendar) davResource {
+	resource := davResource{href: calendarHref(calendar.Id)}
+	resource.add(davNS, "resourcetype", "<D:collection/><C:calendar/>")
+	resource.add(davNS, "displayname", davText(calendar.Title))
+	resource.add(caldavNS, "calendar-description", davText(calendar.Description))
+	resource.add(caldavNS, "supported-calendar-component-set", `<C:comp name="VTODO"/>`)
+	resource.add(davNS, "supported-report-set", "<D:supported-report><D:report><C:calendar-query/></D:report></D:supported-report>"+
+		"<D:supported-report><D:report><C:calendar-multiget/></D:report></D:supported-report>")
+	resource.add(calendarServerNS, "getctag", strconv.FormatInt(calendar.CTag, 10))
+	addPrincipalProps(&resource)
+
+	privileges := "<D:privilege><D:read/></D:privilege>"
+	if todo.RoleAllows(calendar.Role, todo.RoleEditor) {
+		privileges += "<D:privilege><D:write-content/></D:privilege><D:privilege><D:bind/></D:privilege><D:privilege><D:unbind/></D:privilege>"
+	}
+	resource.add(davNS, "current-user-privilege-set", privileges)
+
+	return resource
+}
+
+func calendarItemResource(listId int, item todo.CalendarItem) davResource {
+	resource := davResource{href: calendarItemHref(listId, item.Name)}
+	resource.add(davNS, "resourcetype", "")
+	resource.add(davNS, "getetag", davText(etag(item.Version)))
+	resource.add(davNS, "getcontenttype", caldavItemsType)
+	resource.add(davNS, "getlastmodified", item.UpdatedAt.UTC().Format(http.TimeFormat))
+	resource.add(caldavNS, "calendar-data", davText(string(encodeVCalendar("", []todo.CalendarItem{item}))))
+
+	return resource
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	todo "to-do-list"
+
+	"github.com/gin-gonic/gin"
+)
+
+// обработчики секретных ссылок на ленты задач списков в формате iCalendar,
+// сервер CalDAV описан в caldav.go
+
+// путь ленты списка, токен передается в ссылке вместо авторизации
+const calendarFeedPath = "/calendar/feeds/"
+
+// описываем данные для swagger
+// @Summary      Create Calendar Feed
+// @Security ApiKeyAuth
+// @Description  create secret link to the iCalendar feed of the list, the previous link of the user to the list stops working
+// @Tags         calendar
+// ID create-calendar-feed
+// @Accept       json
+// @Produce      json
+// @Param        Idempotency-Key header string false "key for safe retries of the request"
+// @Success      201  {object}  todo.CalendarFeed
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/lists/:id/calendar-feed [post]
+func (h *Handler) createCalendarFeed(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	token, err := h.servicesFrom(c).Calendar.CreateFeed(userId, listId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, todo.CalendarFeed{
+		ListId: listId,
+		Token:  token,
+		Url:    requestBaseURL(c) + calendarFeedPath + token + ".ics",
+	})
+}
+
+// описываем данные для swagger
+// @Summary      Delete Calendar Feed
+// @Security ApiKeyAuth
+// @Description  revoke secret link to the iCalendar feed of the list
+// @Tags         calendar
+// ID delete-calendar-feed
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  statusResponse
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/lists/:id/calendar-feed [delete]
+func (h *Handler) deleteCalendarFeed(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.servicesFrom(c).Calendar.DeleteFeed(userId, listId); err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{
+		Status: "ok",
+	})
+}
+
+// описываем данные для swagger
+// @Summary      Get Calendar Feed
+// @Description  get items of the list as VTODO components of iCalendar, the token from the secret link replaces authorization
+// @Tags         calendar
+// ID get-calendar-feed
+// @Produce      text/calendar
+// @Success      200  {string}  string
+// @Failure      404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /calendar/feeds/:token [get]
+func (h *Handler) getCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	calendar, items, err := h.servicesFrom(c).Calendar.GetFeed(token)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	// ETag ленты - метка изменения календаря
+	if notModified(c, int(calendar.CTag)) {
+		return
+	}
+
+	c.Data(http.StatusOK, icalContentType, encodeVCalendar(calendar.Title, items))
+}
+
+// функция возвращает схему и адрес сервера из запроса,
+// за прокси схема берется из заголовка X-Forwarded-Proto
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	return scheme + "://" + c.Request.Host
+}
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Разбор запросов и формирование ответов WebDAV (RFC 4918) для сервера CalDAV (caldav.go):
+// тела PROPFIND и REPORT и ответ 207 Multi-Status.
+// Свойства ресурсов задаются готовым содержимым xml, в ответе свойства, запрошенные клиентом,
+// но отсутствующие у ресурса, возвращаются со статусом 404
+
+// пространства имен и их префиксы в ответах
+const (
+	davNS            = "DAV:"
+	caldavNS         = "urn:ietf:params:xml:ns:caldav"
+	calendarServerNS = "http://calendarserver.org/ns/"
+)
+
+var davPrefixes = map[string]string{
+	davNS:            "D",
+	caldavNS:         "C",
+	calendarServerNS: "CS",
+}
+
+// максимальный размер тела запроса PROPFIND и REPORT
+const maxDavBodySize = 1 << 20
+
+// свойство ресурса: имя и содержимое в виде xml
+type davProp struct {
+	name  xml.Name
+	inner string
+}
+
+// ресурс ответа Multi-Status
+type davResource struct {
+	href  string
+	props []davProp
+}
+
+func (r *davResource) add(space, local, inner string) {
+	r.props = append(r.props, davProp{name: xml.Name{Space: space, Local: local}, inner: inner})
+}
+
+func (r *davResource) find(name xml.Name) (davProp, bool) {
+	for _, prop := range r.props {
+		if prop.name == name {
+			return prop, true
+		}
+	}
+	return davProp{}, false
+}
+
+// имена элементов без содержимого
+type davAny struct {
+	XMLName xml.Name
+}
+
+type davPropNames struct {
+	Names []davAny `xml:",any"`
+}
+
+// тело PROPFIND: список запрошенных свойств или allprop
+type davPropfind struct {
+	XMLName xml.Name      `xml:"DAV: propfind"`
+	Prop    *davPropNames `xml:"DAV: prop"`
+}
+
+// тело REPORT: calendar-query (Filter) или calendar-multiget (Hrefs)
+type davReport struct {
+	XMLName xml.Name
+	Prop    *davPropNames `xml:"DAV: prop"`
+	Hrefs   []string      `xml:"DAV: href"`
+	Filter  *davFilter    `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+type davFilter struct {
+	Comps []davCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type davCompFilter struct {
+	Name  string          `xml:"name,attr"`
+	Comps []davCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// метод проверяет, что фильтр calendar-query выбирает компоненты VTODO.
+// Фильтры по свойствам и времени не применяются: ответ может содержать лишние задачи,
+// клиенты отбирают их сами
+func (f *davFilter) matchesVTodo() bool {
+	if f == nil || len(f.Comps) == 0 {
+		return true
+	}
+
+	for _, calendar := range f.Comps {
+		if !strings.EqualFold(calendar.Name, "VCALENDAR") {
+			continue
+		}
+		if len(calendar.Comps) == 0 {
+			return true
+		}
+		for _, comp := range calendar.Comps {
+			if strings.EqualFold(comp.Name, "VTODO") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// функция возвращает имена свойств из элемента prop, nil - запрошены все свойства
+func (p *davPropNames) names() []xml.Name {
+	if p == nil {
+		return nil
+	}
+
+	names := make([]xml.Name, len(p.Names))
+	for i, name := range p.Names {
+		names[i] = name.XMLName
+	}
+	return names
+}
+
+// функция читает тело запроса в dest, пустое тело оставляет dest без изменений.
+// В случае некорректного тела записывает в ответ статус 400
+func readDavBody(c *gin.Context, dest interface{}) bool {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxDavBodySize))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		return false
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return true
+	}
+
+	if err := xml.Unmarshal(body, dest); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid xml body: "+err.Error())
+		return false
+	}
+
+	return true
+}
+
+// функция возвращает true, если запрос PROPFIND касается только самого ресурса (Depth: 0),
+// без заголовка и при Depth: infinity возвращаются также дочерние ресурсы
+func davDepthZero(c *gin.Context) bool {
+	return c.GetHeader("Depth") == "0"
+}
+
+// функция записывает ответ 207 Multi-Status. requested - запрошенные свойства,
+// nil - все свойства ресурсов, кроме содержимого календаря (calendar-data).
+// missing - адреса ресурсов, которых нет (для calendar-multiget)
+func writeMultistatus(c *gin.Context, resources []davResource, requested []xml.Name, missing []string) {
+	var b strings.Builder
+
+	b.WriteString(xml.Header)
+	fmt.Fprintf(&b, `<D:multistatus xmlns:D="%s" xmlns:C="%s" xmlns:CS="%s">`, davNS, caldavNS, calendarServerNS)
+
+	for _, resource := range resources {
+		b.WriteString("<D:response><D:href>" + davText(resource.href) + "</D:href>")
+
+		var found []davProp
+		var notFound []xml.Name
+		if requested == nil {
+			for _, prop := range resource.props {
+				if prop.name != (xml.Name{Space: caldavNS, Local: "calendar-data"}) {
+					found = append(found, prop)
+				}
+			}
+		}
+		for _, name := range requested {
+			if prop, ok := resource.find(name); ok {
+				found = append(found, prop)
+			} else {
+				notFound = append(notFound, name)
+			}
+		}
+
+		if len(found) > 0 || len(notFound) == 0 {
+			b.WriteString("<D:propstat><D:prop>")
+			for _, prop := range found {
+				writeDavElement(&b, prop.name, prop.inner)
+			}
+			b.WriteString("</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat>")
+		}
+		if len(notFound) > 0 {
+			b.WriteString("<D:propstat><D:prop>")
+			for _, name := range notFound {
+				writeDavElement(&b, name, "")
+			}
+			b.WriteString("</D:prop><D:status>HTTP/1.1 404 Not Found</D:status></D:propstat>")
+		}
+
+		b.WriteString("</D:response>")
+	}
+
+	for _, href := range missing {
+		b.WriteString("<D:response><D:href>" + davText(href) + "</D:href><D:status>HTTP/1.1 404 Not Found</D:status></D:response>")
+	}
+
+	b.WriteString("</D:multistatus>")
+
+	c.Data(http.StatusMultiStatus, "application/xml; charset=utf-8", []byte(b.String()))
+}
+
+// элемент с префиксом известного пространства имен,
+// для остальных пространств имен объявляется префикс X
+func writeDavElement(b *strings.Builder, name xml.Name, inner string) {
+	tag := name.Local
+	attrs := ""
+	if prefix, ok := davPrefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+	} else if name.Space != "" {
+		tag = "X:" + name.Local
+		attrs = ` xmlns:X="` + davText(name.Space) + `"`
+	}
+
+	if inner == "" {
+		b.WriteString("<" + tag + attrs + "/>")
+		return
+	}
+	b.WriteString("<" + tag + attrs + ">" + inner + "</" + tag + ">")
+}
+
+// функция экранирует текст для содержимого элемента или атрибута
+func davText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// ответ на REPORT неподдерживаемого типа (RFC 3253, DAV:supported-report)
+func unsupportedReport(c *gin.Context) {
+	body := xml.Header + `<D:error xmlns:D="DAV:"><D:supported-report/></D:error>`
+	c.Data(http.StatusForbidden, "application/xml; charset=utf-8", []byte(body))
+}
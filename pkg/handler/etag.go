@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETag записи строится из её версии: "<version>".
+// Клиент передает его в If-Match при изменении записи (оптимистичная блокировка)
+// и в If-None-Match при чтении, чтобы не получать неизмененную запись повторно
+
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// функция проверяет If-None-Match при чтении записи:
+// если ETag совпадает, в ответ записывается 304 и возвращается true
+func notModified(c *gin.Context, version int) bool {
+	c.Header("ETag", etag(version))
+
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	current := etag(version)
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+		if value == "*" || value == current {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+// ключ контекста запроса: If-Match равен "*"
+const ifMatchAnyCtx = "ifMatchAny"
+
+// функция для чтения ожидаемой версии из If-Match.
+// Возвращает nil, если заголовок не задан или равен "*".
+// "*" означает любую текущую версию: версия не проверяется, но запись должна существовать,
+// поэтому ErrNotFound в ответе на такой запрос превращается в 412 (см. newDomainErrorResponse).
+// В случае некорректного значения записывает в ответ статус 400
+func parseIfMatch(c *gin.Context) (*int, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		return nil, true
+	}
+	if header == "*" {
+		c.Set(ifMatchAnyCtx, true)
+		return nil, true
+	}
+
+	value, err := strconv.Unquote(strings.TrimPrefix(header, "W/"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid If-Match header")
+		return nil, false
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid If-Match header")
+		return nil, false
+	}
+
+	return &version, true
+}
+
+// функция для ответа на конфликт версий: статус 412,
+// в теле - текущее состояние записи, в заголовке - её ETag
+func preconditionFailed(c *gin.Context, version int, current interface{}) {
+	c.Header("ETag", etag(version))
+	c.AbortWithStatusJSON(http.StatusPreconditionFailed, current)
+}
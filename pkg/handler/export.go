@@ -0,0 +1,350 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	todo "to-do-list"
+	"to-do-list/pkg/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// обработчик выгрузки всех данных пользователя и форматы выгрузки.
+// Документ пишется в ответ по мере чтения задач из БД, поэтому ответ
+// передается частями (chunked) и не собирается в памяти целиком
+
+const (
+	// размер буфера записи ответа
+	exportBufferSize = 32 << 10
+	// длительность выгрузки, если в конфиге не задано export.timeout
+	defaultExportTimeout = 5 * time.Minute
+)
+
+// формат выгрузки: писатель документа, тип содержимого и расширение файла
+type exportFormat struct {
+	newWriter   func(w io.Writer, exportedAt time.Time) exportWriter
+	contentType string
+	extension   string
+}
+
+// писатель документа выгрузки, Close дописывает окончание документа
+type exportWriter interface {
+	service.ExportWriter
+	Close() error
+}
+
+var exportFormats = map[string]exportFormat{
+	todo.ExportJSON:     {newJSONExportWriter, "application/json; charset=utf-8", "json"},
+	todo.ExportCSV:      {newCSVExportWriter, "text/csv; charset=utf-8", "csv"},
+	todo.ExportMarkdown: {newMarkdownExportWriter, "text/markdown; charset=utf-8", "md"},
+}
+
+// описываем данные для swagger
+// @Summary      Export Account Data
+// @Security ApiKeyAuth
+// @Description  download all lists of the user with the role of the user and all items of the lists with labels as a single document.
+// @Description  The json document can be imported back with POST /api/import?source=to_do_list
+// @Description  The download is interrupted after export.timeout, a client that reads slower gets a truncated document.
+// @Tags         export
+// ID export-account
+// @Produce      json
+// @Produce      text/csv
+// @Produce      text/markdown
+// @Param        format query string false "format of the document, json by default" Enums(json, csv, markdown)
+// @Success      200  {object}  todo.ExportDocument
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/me/export [get]
+func (h *Handler) exportAccount(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	name := c.DefaultQuery("format", todo.ExportJSON)
+	format, ok := exportFormats[name]
+	if !ok {
+		newErrorResponse(c, http.StatusBadRequest, "format must be one of: "+strings.Join(todo.ExportFormats, ", "))
+		return
+	}
+
+	exportedAt := time.Now().UTC()
+	c.Header("Content-Type", format.contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="to-do-list-export-%s.%s"`,
+		exportedAt.Format("20060102-150405"), format.extension))
+
+	// выгрузка читается в одном снимке БД, поэтому ее длительность ограничена:
+	// после срока запись в медленного клиента завершается ошибкой,
+	// выгрузка прерывается и транзакция снимка откатывается
+	timeout := h.cfg.ExportTimeout
+	if timeout <= 0 {
+		timeout = defaultExportTimeout
+	}
+	setWriteDeadline(c, exportedAt.Add(timeout))
+
+	buffer := bufio.NewWriterSize(c.Writer, exportBufferSize)
+	writer := format.newWriter(buffer, exportedAt)
+
+	err = h.servicesFrom(c).Export.Export(userId, writer)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		err = buffer.Flush()
+	}
+	if err == nil {
+		return
+	}
+
+	// пока в ответ ничего не записано, вместо документа отправляется ошибка
+	if !c.Writer.Written() {
+		c.Header("Content-Type", "")
+		c.Header("Content-Disposition", "")
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	// документ уже передается, клиент получит оборванный документ
+	logrus.Errorf("export for user %d: %s", userId, err.Error())
+	c.Abort()
+}
+
+// json-документ todo.ExportDocument. Списки и задачи сериализуются по одной записи,
+// обрамление документа и массивов пишется вручную
+type jsonExportWriter struct {
+	w          io.Writer
+	exportedAt time.Time
+	lists      int
+	items      int
+}
+
+func newJSONExportWriter(w io.Writer, exportedAt time.Time) exportWriter {
+	return &jsonExportWriter{w: w, exportedAt: exportedAt}
+}
+
+func (e *jsonExportWriter) header() error {
+	exportedAt, err := json.Marshal(e.exportedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(e.w, `{"format":%q,"version":%d,"exported_at":%s,"lists":[`,
+		todo.ExportDocumentFormat, todo.ExportDocumentVersion, exportedAt)
+	return err
+}
+
+// список пишется без закрывающей скобки, за ним следует массив его задач
+func (e *jsonExportWriter) WriteList(list todo.ExportList) error {
+	separator := "]},"
+	if e.lists == 0 {
+		if err := e.header(); err != nil {
+			return err
+		}
+		separator = ""
+	}
+	e.lists++
+	e.items = 0
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(e.w, `%s%s,"items":[`, separator, data[:len(data)-1])
+	return err
+}
+
+func (e *jsonExportWriter) WriteItem(item todo.TodoItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	if e.items > 0 {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	e.items++
+
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonExportWriter) Close() error {
+	end := "]}]}\n"
+	if e.lists == 0 {
+		if err := e.header(); err != nil {
+			return err
+		}
+		end = "]}\n"
+	}
+
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// csv-таблица: строка списка (type = list), за ней строки его задач (type = item).
+// У задачи в list_id, list и role - список задачи и роль пользователя в нем,
+// метки перечисляются через запятую
+type csvExportWriter struct {
+	w      *csv.Writer
+	header bool
+	list   todo.ExportList
+}
+
+var csvExportColumns = []string{"type", "list_id", "list", "role", "id", "title", "description", "done", "position",
+	"due_date", "priority", "labels", "completed_at", "created_at", "updated_at"}
+
+func newCSVExportWriter(w io.Writer, _ time.Time) exportWriter {
+	return &csvExportWriter{w: csv.NewWriter(w)}
+}
+
+// строка заголовка пишется перед первой записью
+func (e *csvExportWriter) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+
+	return e.w.Write(csvExportColumns)
+}
+
+func (e *csvExportWriter) write(record []string) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	return e.w.Write(record)
+}
+
+func (e *csvExportWriter) WriteList(list todo.ExportList) error {
+	e.list = list
+	id := strconv.Itoa(list.Id)
+
+	return e.write([]string{"list", id, csvText(list.Title), list.Role, id, csvText(list.Title), csvText(list.Description), "",
+		strconv.Itoa(list.Position), "", "", "", "", exportTime(&list.CreatedAt), exportTime(&list.UpdatedAt)})
+}
+
+func (e *csvExportWriter) WriteItem(item todo.TodoItem) error {
+	return e.write([]string{"item", strconv.Itoa(e.list.Id), csvText(e.list.Title), e.list.Role, strconv.Itoa(item.Id),
+		csvText(item.Title), csvText(item.Description), item.Done, strconv.Itoa(item.Position), exportTime(item.DueDate),
+		strconv.Itoa(item.Priority), csvText(strings.Join(item.Labels, ", ")), exportTime(item.CompletedAt),
+		exportTime(&item.CreatedAt), exportTime(&item.UpdatedAt)})
+}
+
+func (e *csvExportWriter) Close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// текст пользователя в ячейке csv: значение, которое начинается с =, +, -, @, табуляции
+// или возврата каретки, табличный редактор выполнит как формулу, поэтому перед ним ставится '
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
+
+// markdown-документ: раздел на каждый список, задачи - списком с отметками выполнения
+type markdownExportWriter struct {
+	w          io.Writer
+	exportedAt time.Time
+	started    bool
+}
+
+var priorityNames = map[int]string{
+	todo.PriorityLow:    "low",
+	todo.PriorityMedium: "medium",
+	todo.PriorityHigh:   "high",
+}
+
+func newMarkdownExportWriter(w io.Writer, exportedAt time.Time) exportWriter {
+	return &markdownExportWriter{w: w, exportedAt: exportedAt}
+}
+
+// заголовок документа пишется перед первой записью
+func (e *markdownExportWriter) write(text string) error {
+	if !e.started {
+		e.started = true
+		text = fmt.Sprintf("# To-do lists export\n\nExported at %s\n", e.exportedAt.Format(time.RFC3339)) + text
+	}
+
+	_, err := io.WriteString(e.w, text)
+	return err
+}
+
+func (e *markdownExportWriter) WriteList(list todo.ExportList) error {
+	text := fmt.Sprintf("\n## %s\n\nRole: %s\n", markdownText(list.Title), list.Role)
+	if list.Description != "" {
+		text += "\n" + markdownText(list.Description) + "\n"
+	}
+
+	return e.write(text + "\n")
+}
+
+func (e *markdownExportWriter) WriteItem(item todo.TodoItem) error {
+	mark := " "
+	if done, _ := strconv.ParseBool(item.Done); done {
+		mark = "x"
+	}
+	text := fmt.Sprintf("- [%s] %s\n", mark, markdownText(item.Title))
+
+	var details []string
+	if item.DueDate != nil {
+		details = append(details, "due "+item.DueDate.Format("2006-01-02"))
+	}
+	if name, ok := priorityNames[item.Priority]; ok {
+		details = append(details, "priority "+name)
+	}
+	if len(item.Labels) > 0 {
+		details = append(details, "labels: "+markdownText(strings.Join(item.Labels, ", ")))
+	}
+	if len(details) > 0 {
+		text += "  _" + strings.Join(details, "; ") + "_\n"
+	}
+
+	// описание - абзацем внутри пункта списка
+	if item.Description != "" {
+		for _, line := range strings.Split(item.Description, "\n") {
+			text += "  " + markdownText(line) + "\n"
+		}
+	}
+
+	return e.write(text)
+}
+
+func (e *markdownExportWriter) Close() error {
+	return e.write("")
+}
+
+// экранирование символов разметки markdown в тексте пользователя
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "#", `\#`, "\r", "")
+
+func markdownText(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// время в выгрузке в формате RFC 3339, пустая строка для nil
+func exportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	todo "to-do-list"
+
+	"github.com/gin-gonic/gin"
+)
+
+// обработчики сохраненных фильтров ("умных списков") построены
+// по тому же принципу, что и обработчики списков в list.go
+
+// описываем данные для swagger
+// @Summary      Create Saved Filter
+// @Security ApiKeyAuth
+// @Description  create saved filter (smart list)
+// @Tags         filters
+// ID create-filter
+// @Accept       json
+// @Produce      json
+// @Param        input body todo.SavedFilter true "filter data"
+// @Param        Idempotency-Key header string false "key for safe retries of the request"
+// @Success      200  {integer}  integer "id"
+// @Failure      400,404,409,422  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/filters [post]
+func (h *Handler) createFilter(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	var input todo.SavedFilter
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newBindErrorResponse(c, err)
+		return
+	}
+
+	id, err := h.servicesFrom(c).SavedFilter.Create(userId, input)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
+
+// дополнительная структура для ответа
+type getAllFiltersResponse struct {
+	Data []todo.SavedFilter `json:"data"`
+}
+
+// описываем данные для swagger
+// @Summary      Get All Saved Filters
+// @Security ApiKeyAuth
+// @Description  get all saved filters of the user
+// @Tags         filters
+// ID get-all-filters
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  getAllFiltersResponse
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/filters [get]
+func (h *Handler) getAllFilters(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	filters, err := h.servicesFrom(c).SavedFilter.GetAll(userId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getAllFiltersResponse{
+		Data: filters,
+	})
+}
+
+// описываем данные для swagger
+// @Summary      Get Saved Filter By Id
+// @Security ApiKeyAuth
+// @Description  get saved filter by id
+// @Tags         filters
+// ID get-filter-by-id
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  todo.SavedFilter
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/filters/:id [get]
+func (h *Handler) getFilterById(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	filterId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	filter, err := h.servicesFrom(c).SavedFilter.GetById(userId, filterId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, filter)
+}
+
+// описываем данные для swagger
+// @Summary      Update Saved Filter
+// @Security ApiKeyAuth
+// @Description  update saved filter
+// @Tags         filters
+// ID update-filter
+// @Accept       json
+// @Produce      json
+// @Param        input body todo.UpdateSavedFilterInput true "filter data"
+// @Success      200  {string}  string
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/filters/:id [put]
+func (h *Handler) updateFilter(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	filterId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.UpdateSavedFilterInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newBindErrorResponse(c, err)
+		return
+	}
+
+	if err := h.servicesFrom(c).SavedFilter.Update(userId, filterId, input); err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{
+		Status: "ok",
+	})
+}
+
+// описываем данные для swagger
+// @Summary      Delete Saved Filter
+// @Security ApiKeyAuth
+// @Description  delete saved filter
+// @Tags         filters
+// ID delete-filter
+// @Accept       json
+// @Produce      json
+// @Success      200  {string}  string
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/filters/:id [delete]
+func (h *Handler) deleteFilter(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	filterId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.servicesFrom(c).SavedFilter.Delete(userId, filterId); err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{
+		Status: "ok",
+	})
+}
+
+// описываем данные для swagger
+// @Summary      Get Saved Filter Items
+// @Security ApiKeyAuth
+// @Description  evaluate saved filter over items of all lists available to the user
+// @Tags         filters
+// ID get-filter-items
+// @Accept       json
+// @Produce      json
+// @Param        limit   query  int     false  "page size (1-100, default 50)"
+// @Param        cursor  query  string  false  "next_cursor from previous page"
+// @Param        sort    query  string  false  "sort field"  Enums(created, updated, title, due_date, position, created_at, updated_at, completed_at)
+// @Param        order   query  string  false  "sort order"  Enums(asc, desc)
+// @Success      200  {object}  getAllItemsResponse
+// @Header       200  {string}  Link  "next page link"
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/filters/:id/items [get]
+func (h *Handler) getFilterItems(c *gin.Context) {
+	h.getFilterItemsFor(c, apiV1{})
+}
+
+// общий для версий api обработчик получения страницы задач по фильтру
+func (h *Handler) getFilterItemsFor(c *gin.Context, v apiVersion) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	filterId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	query, ok := parsePageQuery(c, todo.ItemSortFields)
+	if !ok {
+		return
+	}
+
+	items, next, err := h.servicesFrom(c).SavedFilter.GetItems(userId, filterId, query)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	setNextPageLink(c, next)
+	c.JSON(http.StatusOK, v.itemsPage(items, query.Limit, next))
+}
@@ -12,14 +12,31 @@ import (
 )
 
 // Структура handlers использует указатель на service (внедрение зависимостей)
-// В конструкторе мы внедряем зависимость от service
+// В конструкторе мы внедряем зависимость от service.
+// router сохраняется при инициализации для выполнения вложенных запросов batch
+// caldavLimiter ограничивает подбор пароля клиентами CalDAV (auth_limiter.go)
 type Handler struct {
-	services *service.Service
+	services      *service.Service
+	router        *gin.Engine
+	cfg           Config
+	caldavLimiter *authLimiter
 }
 
 // метод для инициализации, используется в main.go
-func NewHandler(services *service.Service) *Handler {
-	return &Handler{services: services}
+func NewHandler(services *service.Service, cfg Config) *Handler {
+	return &Handler{services: services, cfg: cfg, caldavLimiter: newAuthLimiter(authFailureWindow)}
+}
+
+// ключ контекста запроса, в котором batch передает сервисы, работающие в транзакции
+type servicesCtxKey struct{}
+
+// метод возвращает сервисы для обработки запроса:
+// внутри транзакционного batch - сервисы из контекста, иначе - общие
+func (h *Handler) servicesFrom(c *gin.Context) *service.Service {
+	if services, ok := c.Request.Context().Value(servicesCtxKey{}).(*service.Service); ok {
+		return services
+	}
+	return h.services
 }
 
 // Метод запускается из main.go и инициализирует все endpoints.
@@ -27,13 +44,18 @@ func NewHandler(services *service.Service) *Handler {
 func (h *Handler) InitRoutes() *gin.Engine {
 	// инициализация роутера
 	router := gin.New()
+	h.router = router
+
+	// ошибки валидации возвращаются с именами полей из json
+	registerJSONFieldNames()
 
 	// подключаем swagger к роутеру
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// Прописываем endpoints к обработчикам текущего модуля handler:
 	// в файлах с соответствующими именами в текущей папке:
-	// auth.go, item.go, list.go.
+	// auth.go, item.go, list.go, member.go, filter.go, search.go, batch.go, idempotency.go, stream.go, webhook.go, sync.go,
+	// account.go, import.go, export.go, calendar.go и caldav.go, v2_list.go и v2_item.go для второй версии api.
 	// Обработчики во фреймворке gin приниают в качестве параметра
 	// указатель - *gin.Context.
 	auth := router.Group("/auth")
@@ -42,9 +64,36 @@ func (h *Handler) InitRoutes() *gin.Engine {
 		auth.POST("/sign-in", h.signIn)
 	}
 
+	// лента задач списка в формате iCalendar доступна по секретной ссылке без авторизации
+	router.GET(calendarFeedPath+":token", h.getCalendarFeed)
+
+	// сервер CalDAV: методы WebDAV регистрируются через Handle,
+	// OPTIONS и обнаружение сервера не требуют авторизации
+	router.GET("/.well-known/caldav", h.caldavWellKnown)
+	router.Handle("PROPFIND", "/.well-known/caldav", h.caldavWellKnown)
+	router.OPTIONS(caldavPath+"/*path", h.caldavOptions)
+	caldav := router.Group(caldavPath, h.caldavIdentity)
+	{
+		caldav.Handle("PROPFIND", "/", h.propfindPrincipal)
+		caldav.Handle("PROPFIND", "/lists/", h.propfindHome)
+		caldav.Handle("PROPFIND", "/lists/:id/", h.propfindCalendar)
+		caldav.Handle("REPORT", "/lists/:id/", h.reportCalendar)
+		caldav.Handle("PROPFIND", "/lists/:id/:name", h.propfindCalendarItem)
+		caldav.GET("/lists/:id/:name", h.getCalendarItem)
+		caldav.PUT("/lists/:id/:name", h.putCalendarItem)
+		caldav.DELETE("/lists/:id/:name", h.deleteCalendarItem)
+	}
+
+	// поток событий регистрируется вне групп api: кроме заголовка авторизации
+	// он принимает токен потока из параметра запроса (мидлвара streamIdentity)
+	router.GET(apiV1Path+"/stream", h.deprecated, h.streamIdentity, h.stream)
+	router.GET(apiV2Path+"/stream", h.streamIdentity, h.stream)
+
 	// используем мидлвару для проверки аутентификации
-	// и добавления id пользователя в контекст запроса
-	api := router.Group("/api", h.userIdentity)
+	// и добавления id пользователя в контекст запроса,
+	// мидлвара idempotency обрабатывает заголовок Idempotency-Key в POST-запросах.
+	// v1 дополнительно помечается устаревшей мидлварой deprecated (version.go)
+	api := router.Group(apiV1Path, h.deprecated, h.userIdentity, h.idempotency)
 	{
 		lists := api.Group("/lists")
 		{
@@ -52,8 +101,14 @@ func (h *Handler) InitRoutes() *gin.Engine {
 			lists.GET("/", h.getAllLists)
 			lists.GET("/:id", h.getListById)
 			lists.PUT("/:id", h.updateList)
+			lists.PATCH("/:id", h.patchList)
 			lists.DELETE("/:id", h.deleteList)
 
+			// участники списка и их роли
+			lists.GET("/:id/members", h.getListMembers)
+			lists.PUT("/:id/members", h.setListMember)
+			lists.DELETE("/:id/members/:userId", h.deleteListMember)
+
 			items := lists.Group(":id/items")
 			{
 				items.POST("/", h.createItem)
@@ -64,9 +119,97 @@ func (h *Handler) InitRoutes() *gin.Engine {
 		{
 			items.GET("/:id", h.getItemById)
 			items.PUT("/:id", h.updateItem)
+			items.PATCH("/:id", h.patchItem)
 			items.DELETE("/:id", h.deleteItem)
+			items.POST("/bulk", h.bulkItems)
+		}
+
+		h.initCommonRoutes(api, h.getFilterItems)
+	}
+
+	// v2: списки и задачи возвращаются через DTO из v2_dto.go,
+	// обработчики списков и задач общие с v1 (version.go), остальные endpoints совпадают с v1
+	apiV2 := router.Group(apiV2Path, h.userIdentity, h.idempotency)
+	{
+		lists := apiV2.Group("/lists")
+		{
+			lists.POST("/", h.createListV2)
+			lists.GET("/", h.getAllListsV2)
+			lists.GET("/:id", h.getListByIdV2)
+			lists.PUT("/:id", h.updateListV2)
+			lists.PATCH("/:id", h.patchListV2)
+			lists.DELETE("/:id", h.deleteListV2)
+
+			lists.GET("/:id/members", h.getListMembers)
+			lists.PUT("/:id/members", h.setListMember)
+			lists.DELETE("/:id/members/:userId", h.deleteListMember)
+
+			items := lists.Group(":id/items")
+			{
+				items.POST("/", h.createItemV2)
+				items.GET("/", h.getAllItemsV2)
+			}
 		}
+		items := apiV2.Group("items")
+		{
+			items.GET("/:id", h.getItemByIdV2)
+			items.PUT("/:id", h.updateItemV2)
+			items.PATCH("/:id", h.patchItemV2)
+			items.DELETE("/:id", h.deleteItemV2)
+			items.POST("/bulk", h.bulkItems)
+		}
+
+		h.initCommonRoutes(apiV2, h.getFilterItemsV2)
 	}
 
 	return router
 }
+
+// метод регистрирует endpoints, одинаковые во всех версиях api,
+// filterItems - обработчик задач по фильтру, ответ которого зависит от версии
+func (h *Handler) initCommonRoutes(api *gin.RouterGroup, filterItems gin.HandlerFunc) {
+	filters := api.Group("/filters")
+	{
+		filters.POST("/", h.createFilter)
+		filters.GET("/", h.getAllFilters)
+		filters.GET("/:id", h.getFilterById)
+		filters.PUT("/:id", h.updateFilter)
+		filters.DELETE("/:id", h.deleteFilter)
+		filters.GET("/:id/items", filterItems)
+	}
+
+	webhooks := api.Group("/webhooks")
+	{
+		webhooks.POST("/", h.createWebhook)
+		webhooks.GET("/", h.getAllWebhooks)
+		webhooks.GET("/:id", h.getWebhookById)
+		webhooks.PUT("/:id", h.updateWebhook)
+		webhooks.DELETE("/:id", h.deleteWebhook)
+		webhooks.GET("/:id/deliveries", h.getWebhookDeliveries)
+		webhooks.POST("/:id/deliveries/:deliveryId/redeliver", h.redeliverWebhook)
+	}
+
+	imports := api.Group("/import")
+	{
+		imports.POST("/", h.createImport)
+		imports.GET("/", h.getAllImports)
+		imports.GET("/:id", h.getImportById)
+	}
+
+	api.POST("/lists/:id/calendar-feed", h.createCalendarFeed)
+	api.DELETE("/lists/:id/calendar-feed", h.deleteCalendarFeed)
+
+	me := api.Group("/me")
+	{
+		me.DELETE("", h.deleteAccount)
+		me.GET("/deletion", h.getAccountDeletion)
+		me.DELETE("/deletion", h.cancelAccountDeletion)
+		me.GET("/export", h.exportAccount)
+	}
+
+	api.GET("/search", h.search)
+	api.POST("/batch", h.batch)
+	api.POST("/stream/token", h.createStreamToken)
+	api.GET("/sync", h.getSyncChanges)
+	api.POST("/sync", h.pushSyncMutations)
+}
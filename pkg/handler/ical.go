@@ -0,0 +1,349 @@
+package handler
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+	todo "to-do-list"
+	"unicode/utf8"
+)
+
+// Преобразование задач в компоненты VTODO формата iCalendar (RFC 5545) и обратно.
+// Соответствие свойств VTODO полям задачи:
+// UID - uid, SUMMARY - title, DESCRIPTION - description,
+// STATUS:COMPLETED (или свойство COMPLETED без STATUS) - done, DUE - due_date,
+// PRIORITY - priority, CATEGORIES - labels.
+// Срок без времени (DUE;VALUE=DATE) хранится как полночь UTC и так же выводится.
+// Остальные свойства VTODO при сохранении задачи не сохраняются
+
+const (
+	icalContentType = "text/calendar; charset=utf-8"
+	icalProdId      = "-//to-do-list//Todo Service//EN"
+
+	icalDateTimeUTC = "20060102T150405Z"
+	icalDateTime    = "20060102T150405"
+	icalDate        = "20060102"
+
+	// максимальная длина строки iCalendar в байтах без CRLF
+	icalLineLength = 75
+)
+
+// приоритеты iCalendar: 1 - наивысший, 9 - наименьший, 0 - не задан
+var icalPriorities = map[int]int{
+	todo.PriorityHigh:   1,
+	todo.PriorityMedium: 5,
+	todo.PriorityLow:    9,
+}
+
+func todoPriority(icalPriority int) int {
+	switch {
+	case icalPriority == 0:
+		return todo.PriorityNone
+	case icalPriority < 5:
+		return todo.PriorityHigh
+	case icalPriority == 5:
+		return todo.PriorityMedium
+	default:
+		return todo.PriorityLow
+	}
+}
+
+// функция возвращает календарь с задачами, name - имя календаря (X-WR-CALNAME)
+func encodeVCalendar(name string, items []todo.CalendarItem) []byte {
+	w := &icalWriter{}
+
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + icalProdId)
+	w.line("CALSCALE:GREGORIAN")
+	if name != "" {
+		w.text("X-WR-CALNAME", name)
+	}
+	for _, item := range items {
+		w.vtodo(item)
+	}
+	w.line("END:VCALENDAR")
+
+	return w.buf.Bytes()
+}
+
+type icalWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *icalWriter) vtodo(item todo.CalendarItem) {
+	w.line("BEGIN:VTODO")
+	w.text("UID", item.Uid)
+	w.time("DTSTAMP", item.UpdatedAt)
+	w.time("CREATED", item.CreatedAt)
+	w.time("LAST-MODIFIED", item.UpdatedAt)
+	w.line("SEQUENCE:" + strconv.Itoa(item.Version))
+	w.text("SUMMARY", item.Title)
+	if item.Description != "" {
+		w.text("DESCRIPTION", item.Description)
+	}
+
+	if done, _ := strconv.ParseBool(item.Done); done {
+		w.line("STATUS:COMPLETED")
+		w.line("PERCENT-COMPLETE:100")
+	} else {
+		w.line("STATUS:NEEDS-ACTION")
+	}
+	if item.CompletedAt != nil {
+		w.time("COMPLETED", *item.CompletedAt)
+	}
+
+	if item.DueDate != nil {
+		due := item.DueDate.UTC()
+		if due.Equal(due.Truncate(24 * time.Hour)) {
+			w.line("DUE;VALUE=DATE:" + due.Format(icalDate))
+		} else {
+			w.time("DUE", due)
+		}
+	}
+	if priority, ok := icalPriorities[item.Priority]; ok {
+		w.line("PRIORITY:" + strconv.Itoa(priority))
+	}
+	if len(item.Labels) > 0 {
+		labels := make([]string, len(item.Labels))
+		for i, label := range item.Labels {
+			labels[i] = escapeICalText(label)
+		}
+		w.line("CATEGORIES:" + strings.Join(labels, ","))
+	}
+	w.line("END:VTODO")
+}
+
+func (w *icalWriter) text(name, value string) {
+	w.line(name + ":" + escapeICalText(value))
+}
+
+func (w *icalWriter) time(name string, t time.Time) {
+	w.line(name + ":" + t.UTC().Format(icalDateTimeUTC))
+}
+
+// строки длиннее icalLineLength байт переносятся: продолжение начинается с пробела,
+// символы utf-8 не разрываются
+func (w *icalWriter) line(s string) {
+	limit := icalLineLength
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.buf.WriteString(s[:cut])
+		w.buf.WriteString("\r\n ")
+		s = s[cut:]
+		// пробел в начале продолжения входит в длину строки
+		limit = icalLineLength - 1
+	}
+	w.buf.WriteString(s)
+	w.buf.WriteString("\r\n")
+}
+
+var icalTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeICalText(s string) string {
+	return icalTextEscaper.Replace(s)
+}
+
+func unescapeICalText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if s[i] == 'n' || s[i] == 'N' {
+			b.WriteByte('\n')
+		} else {
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String()
+}
+
+// функция разделяет значение-список по запятым, экранированные запятые не разделяют значения
+func splitICalList(s string) []string {
+	var values []string
+
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, unescapeICalText(s[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(values, unescapeICalText(s[start:]))
+}
+
+// свойство компонента: имя, параметры и значение
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// функция разбирает строку свойства NAME;PARAM=value:VALUE,
+// значения параметров в кавычках могут содержать ':' и ';'
+func parseICalProperty(line string) (icalProperty, bool) {
+	prop := icalProperty{params: make(map[string]string)}
+
+	var parts []string
+	quoted, start, colon := false, 0, -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				parts = append(parts, line[start:i])
+				start = i + 1
+			}
+		case ':':
+			if !quoted {
+				parts = append(parts, line[start:i])
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return prop, false
+	}
+
+	prop.name = strings.ToUpper(parts[0])
+	prop.value = line[colon+1:]
+	for _, param := range parts[1:] {
+		if eq := strings.IndexByte(param, '='); eq > 0 {
+			prop.params[strings.ToUpper(param[:eq])] = strings.Trim(param[eq+1:], `"`)
+		}
+	}
+
+	return prop, true
+}
+
+// функция разбирает календарь с единственным компонентом VTODO в задачу календаря.
+// Вложенные компоненты VTODO (например, VALARM) пропускаются
+func decodeVTodo(data []byte) (todo.CalendarItem, error) {
+	var item todo.CalendarItem
+
+	// объединяем перенесенные строки
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.NewReplacer("\n ", "", "\n\t", "").Replace(text)
+
+	var props []icalProperty
+	var stack []string
+	todos := 0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		prop, ok := parseICalProperty(line)
+		if !ok {
+			return item, invalidCalendarData("invalid content line")
+		}
+
+		switch prop.name {
+		case "BEGIN":
+			component := strings.ToUpper(prop.value)
+			if len(stack) == 1 {
+				switch component {
+				case "VTODO":
+					todos++
+				case "VEVENT", "VJOURNAL":
+					return item, todo.NewValidationError("calendar-data", todo.CodeUnsupported, "only VTODO components are supported")
+				}
+			}
+			stack = append(stack, component)
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(prop.value) {
+				return item, invalidCalendarData("unbalanced END:" + prop.value)
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		if len(stack) == 2 && stack[0] == "VCALENDAR" && stack[1] == "VTODO" {
+			props = append(props, prop)
+		}
+	}
+
+	switch {
+	case len(stack) != 0:
+		return item, invalidCalendarData("unterminated component")
+	case todos == 0:
+		return item, invalidCalendarData("VTODO component is required")
+	case todos > 1:
+		return item, todo.NewValidationError("calendar-data", todo.CodeUnsupported, "only one VTODO component per resource is supported")
+	}
+
+	// выполнение определяется после разбора всех свойств: STATUS важнее свойства COMPLETED,
+	// которое клиенты могут оставить после снятия отметки
+	status, completed := "", false
+	item.Labels = todo.Labels{}
+	for _, prop := range props {
+		switch prop.name {
+		case "UID":
+			item.Uid = unescapeICalText(prop.value)
+		case "SUMMARY":
+			item.Title = unescapeICalText(prop.value)
+		case "DESCRIPTION":
+			item.Description = unescapeICalText(prop.value)
+		case "STATUS":
+			status = strings.ToUpper(prop.value)
+		case "COMPLETED":
+			completed = true
+		case "DUE":
+			due, err := parseICalTime(prop)
+			if err != nil {
+				return item, invalidCalendarData("invalid DUE value")
+			}
+			item.DueDate = &due
+		case "PRIORITY":
+			priority, err := strconv.Atoi(prop.value)
+			if err != nil || priority < 0 || priority > 9 {
+				return item, invalidCalendarData("PRIORITY must be between 0 and 9")
+			}
+			item.Priority = todoPriority(priority)
+		case "CATEGORIES":
+			item.Labels = append(item.Labels, splitICalList(prop.value)...)
+		}
+	}
+	item.Done = strconv.FormatBool(status == "COMPLETED" || status == "" && completed)
+
+	return item, nil
+}
+
+// значение DATE или DATE-TIME: в UTC, с часовым поясом TZID или "плавающее" (считается UTC).
+// Неизвестный часовой пояс считается UTC
+func parseICalTime(prop icalProperty) (time.Time, error) {
+	if strings.EqualFold(prop.params["VALUE"], "DATE") || len(prop.value) == len(icalDate) {
+		return time.Parse(icalDate, prop.value)
+	}
+	if strings.HasSuffix(prop.value, "Z") {
+		return time.Parse(icalDateTimeUTC, prop.value)
+	}
+
+	location := time.UTC
+	if tzid := prop.params["TZID"]; tzid != "" {
+		if loaded, err := time.LoadLocation(tzid); err == nil {
+			location = loaded
+		}
+	}
+
+	return time.ParseInLocation(icalDateTime, prop.value, location)
+}
+
+func invalidCalendarData(message string) error {
+	return todo.NewValidationError("calendar-data", todo.CodeInvalid, message)
+}
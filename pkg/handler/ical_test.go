@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	todo "to-do-list"
+)
+
+// календарь с одним компонентом VTODO из переданных свойств
+func vcalendar(lines ...string) []byte {
+	all := append([]string{"BEGIN:VCALENDAR", "VERSION:2.0", "BEGIN:VTODO"}, lines...)
+	all = append(all, "END:VTODO", "END:VCALENDAR", "")
+
+	return []byte(strings.Join(all, "\r\n"))
+}
+
+func date(year int, month time.Month, day, hour, min int) *time.Time {
+	t := time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	return &t
+}
+
+// задача, записанная encodeVCalendar, читается decodeVTodo без изменений
+func TestICalRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		item todo.CalendarItem
+	}{
+		{"plain", todo.CalendarItem{
+			TodoItem: todo.TodoItem{Title: "Buy milk", Done: "false", Labels: todo.Labels{}},
+			Uid:      "todo-item-1",
+		}},
+		{"escaped text", todo.CalendarItem{
+			TodoItem: todo.TodoItem{
+				Title:       `a, b; c\d`,
+				Description: "first line\nsecond line, with; separators",
+				Done:        "true",
+				Labels:      todo.Labels{"home, garden", "a;b", `back\slash`},
+			},
+			Uid: `uid,with;special\chars`,
+		}},
+		{"long utf-8 lines are folded", todo.CalendarItem{
+			TodoItem: todo.TodoItem{
+				Title:       strings.Repeat("Купить молоко ", 12),
+				Description: strings.Repeat("ё", 100),
+				Done:        "false",
+				Labels:      todo.Labels{strings.Repeat("метка", 20)},
+			},
+			Uid: strings.Repeat("x", 200),
+		}},
+		{"due date without time", todo.CalendarItem{
+			TodoItem: todo.TodoItem{Title: "a", Done: "false", DueDate: date(2024, 3, 1, 0, 0), Labels: todo.Labels{}},
+			Uid:      "u",
+		}},
+		{"due date with time", todo.CalendarItem{
+			TodoItem: todo.TodoItem{Title: "a", Done: "false", DueDate: date(2024, 3, 1, 14, 30), Labels: todo.Labels{}},
+			Uid:      "u",
+		}},
+		{"high priority", todo.CalendarItem{
+			TodoItem: todo.TodoItem{Title: "a", Done: "false", Priority: todo.PriorityHigh, Labels: todo.Labels{}},
+			Uid:      "u",
+		}},
+		{"medium priority", todo.CalendarItem{
+			TodoItem: todo.TodoItem{Title: "a", Done: "false", Priority: todo.PriorityMedium, Labels: todo.Labels{}},
+			Uid:      "u",
+		}},
+		{"low priority", todo.CalendarItem{
+			TodoItem: todo.TodoItem{Title: "a", Done: "false", Priority: todo.PriorityLow, Labels: todo.Labels{}},
+			Uid:      "u",
+		}},
+		{"completed", todo.CalendarItem{
+			TodoItem: todo.TodoItem{Title: "a", Done: "true", CompletedAt: date(2024, 3, 2, 10, 0), Labels: todo.Labels{}},
+			Uid:      "u",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeVCalendar("list", []todo.CalendarItem{tt.item})
+			for _, line := range strings.Split(string(data), "\r\n") {
+				if len(line) > icalLineLength {
+					t.Errorf("line longer than %d bytes: %q", icalLineLength, line)
+				}
+			}
+
+			got, err := decodeVTodo(data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n%s", err, data)
+			}
+
+			want := tt.item
+			// поля, которые не читаются из VTODO
+			want.CompletedAt = nil
+			if got.Uid != want.Uid || got.Title != want.Title || got.Description != want.Description ||
+				got.Done != want.Done || got.Priority != want.Priority || !reflect.DeepEqual(got.Labels, want.Labels) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+			if (got.DueDate == nil) != (want.DueDate == nil) || got.DueDate != nil && !got.DueDate.Equal(*want.DueDate) {
+				t.Errorf("due date: got %v, want %v", got.DueDate, want.DueDate)
+			}
+		})
+	}
+}
+
+// выполнение не зависит от порядка свойств STATUS и COMPLETED
+func TestDecodeVTodoDone(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"status completed", []string{"UID:u", "SUMMARY:a", "STATUS:COMPLETED"}, "true"},
+		{"completed without status", []string{"UID:u", "SUMMARY:a", "COMPLETED:20240301T100000Z"}, "true"},
+		{"status after completed", []string{"UID:u", "SUMMARY:a", "COMPLETED:20240301T100000Z", "STATUS:NEEDS-ACTION"}, "false"},
+		{"status before completed", []string{"UID:u", "SUMMARY:a", "STATUS:NEEDS-ACTION", "COMPLETED:20240301T100000Z"}, "false"},
+		{"status in lower case", []string{"UID:u", "SUMMARY:a", "STATUS:completed"}, "true"},
+		{"no status", []string{"UID:u", "SUMMARY:a"}, "false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, err := decodeVTodo(vcalendar(tt.lines...))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if item.Done != tt.want {
+				t.Errorf("got done %s, want %s", item.Done, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeVTodo(t *testing.T) {
+	data := vcalendar(
+		`UID:a\,b`,
+		"SUMMARY:first",
+		"  continued",
+		"DUE;TZID=Europe/Moscow:20240301T120000",
+		"PRIORITY:2",
+		`CATEGORIES:x\,y,z`,
+		"CATEGORIES:w",
+		"BEGIN:VALARM",
+		"SUMMARY:alarm",
+		"END:VALARM",
+	)
+
+	item, err := decodeVTodo(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if item.Uid != "a,b" {
+		t.Errorf("uid: got %q", item.Uid)
+	}
+	// продолжение строки начинается с пробела, который не входит в значение
+	if item.Title != "first continued" {
+		t.Errorf("title: got %q", item.Title)
+	}
+	if item.DueDate == nil || !item.DueDate.Equal(*date(2024, 3, 1, 9, 0)) {
+		t.Errorf("due date: got %v", item.DueDate)
+	}
+	if item.Priority != todo.PriorityHigh {
+		t.Errorf("priority: got %d", item.Priority)
+	}
+	if !reflect.DeepEqual(item.Labels, todo.Labels{"x,y", "z", "w"}) {
+		t.Errorf("labels: got %v", item.Labels)
+	}
+}
+
+func TestDecodeVTodoErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		code string
+	}{
+		{"no vtodo", "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n", todo.CodeInvalid},
+		{"two vtodo", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nEND:VTODO\r\nBEGIN:VTODO\r\nEND:VTODO\r\nEND:VCALENDAR\r\n", todo.CodeUnsupported},
+		{"vevent", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", todo.CodeUnsupported},
+		{"unbalanced end", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nEND:VCALENDAR\r\n", todo.CodeInvalid},
+		{"unterminated", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nEND:VTODO\r\n", todo.CodeInvalid},
+		{"line without colon", string(vcalendar("SUMMARY")), todo.CodeInvalid},
+		{"invalid due", string(vcalendar("DUE:tomorrow")), todo.CodeInvalid},
+		{"priority out of range", string(vcalendar("PRIORITY:10")), todo.CodeInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeVTodo([]byte(tt.data))
+
+			var validationErr *todo.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("got %v, want validation error", err)
+			}
+			if len(validationErr.Fields) != 1 || validationErr.Fields[0].Code != tt.code {
+				t.Errorf("got %+v, want code %s", validationErr.Fields, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	todo "to-do-list"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+)
+
+// заголовки ответа, которые сохраняются вместе с телом и возвращаются при повторе:
+// v2 отвечает на создание заголовками Location и ETag
+var idempotencyHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// обертка над gin.ResponseWriter, сохраняющая тело ответа
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// метод мидлвары для POST-запросов с заголовком Idempotency-Key.
+// Первый ответ для пары (пользователь, ключ) сохраняется и возвращается
+// на повторные запросы с тем же телом. Повтор ключа с другим телом - 422,
+// повтор ключа, пока первый запрос еще выполняется - 409
+// (по истечении аренды idempotency.lease ключ прерванного запроса можно занять снова).
+// Ответы с ошибкой сервера не сохраняются, чтобы запрос можно было повторить
+func (h *Handler) idempotency(c *gin.Context) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if key == "" || c.Request.Method != http.MethodPost {
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		newErrorResponse(c, http.StatusBadRequest, "idempotency key is too long")
+		return
+	}
+
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	// читаем тело для вычисления хэша и возвращаем его в запрос для обработчика
+	body, err := c.GetRawData()
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	hash := sha256.New()
+	// в хэш входит и строка запроса: например, параметры импорта передаются в ней
+	hash.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	requestHash := hex.EncodeToString(hash.Sum(nil))
+
+	services := h.servicesFrom(c)
+	record, created, err := services.Idempotency.Begin(userId, key, requestHash)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	if !created {
+		switch {
+		case record.RequestHash != requestHash:
+			newProblemResponse(c, http.StatusUnprocessableEntity, codeIdempotencyMismatch, "idempotency key was used with a different request", nil)
+		case record.StatusCode == nil:
+			newProblemResponse(c, http.StatusConflict, codeIdempotencyInFlight, "request with this idempotency key is in progress", nil)
+		default:
+			c.Header(idempotencyReplayedHeader, "true")
+			for name, value := range record.ResponseHeaders {
+				c.Header(name, value)
+			}
+			// у ответов, сохраненных без заголовков, тип содержимого определяется по статусу
+			contentType := record.ResponseHeaders["Content-Type"]
+			if contentType == "" {
+				contentType = "application/json; charset=utf-8"
+				if *record.StatusCode >= http.StatusBadRequest {
+					contentType = problemContentType
+				}
+			}
+			c.Data(*record.StatusCode, contentType, record.ResponseBody)
+			c.Abort()
+		}
+		return
+	}
+
+	// выполняем обработчик, сохраняя ответ
+	recorder := &bodyRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	c.Next()
+
+	if status := recorder.Status(); status >= http.StatusInternalServerError {
+		err = services.Idempotency.Release(userId, key, record.CreatedAt)
+	} else {
+		headers := make(todo.ResponseHeaders)
+		for _, name := range idempotencyHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		err = services.Idempotency.Complete(userId, key, record.CreatedAt, status, recorder.body.Bytes(), headers)
+	}
+	if err != nil {
+		logrus.Error(err)
+	}
+}
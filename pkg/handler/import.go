@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	todo "to-do-list"
+
+	"github.com/gin-gonic/gin"
+)
+
+// обработчики заданий импорта, сам импорт выполняется в фоне (service/import_runner.go)
+
+// запас размера запроса на заголовки multipart-формы сверх размера файла
+const importFormOverhead = 1 << 20
+
+// описываем данные для swagger
+// @Summary      Create Import
+// @Security ApiKeyAuth
+// @Description  upload export file of another service and start background import.
+// @Description  Sources: todoist (backup zip, project csv or Sync API json), trello (board json),
+// @Description  microsoft_todo (Microsoft Graph todoTaskList json with tasks).
+// @Description  The file is sent as multipart field "file" or as the request body with file_name in query
+// @Tags         import
+// ID create-import
+// @Accept       mpfd
+// @Produce      json
+// @Param        source query string true "source of the file" Enums(todoist, trello, microsoft_todo)
+// @Param        file_name query string false "name of the file sent as the request body"
+// @Param        file formData file false "export file"
+// @Param        Idempotency-Key header string false "key for safe retries of the request"
+// @Success      202  {object}  todo.ImportJob
+// @Header       202  {string}  Location  "url of the import job"
+// @Failure      400,413  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/import [post]
+func (h *Handler) createImport(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, todo.MaxImportFileSize+importFormOverhead)
+
+	input := todo.ImportInput{
+		Source:   c.Query("source"),
+		FileName: c.Query("file_name"),
+	}
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		if input.Source == "" {
+			input.Source = c.PostForm("source")
+		}
+		input.FileName, input.Data, err = readImportFile(c)
+	} else {
+		input.Data, err = io.ReadAll(c.Request.Body)
+	}
+	if err != nil {
+		// ошибка http.MaxBytesReader
+		if strings.Contains(err.Error(), "request body too large") {
+			newErrorResponse(c, http.StatusRequestEntityTooLarge, "file is too large")
+			return
+		}
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	job, err := h.servicesFrom(c).Import.Create(userId, input)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+strconv.Itoa(job.Id))
+	c.JSON(http.StatusAccepted, job)
+}
+
+// файл из поля file multipart-формы
+func readImportFile(c *gin.Context) (string, []byte, error) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			return "", nil, nil
+		}
+		return "", nil, err
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, todo.MaxImportFileSize+1))
+	return header.Filename, data, err
+}
+
+// дополнительная структура для ответа
+type getAllImportsResponse struct {
+	Data []todo.ImportJob `json:"data"`
+}
+
+// описываем данные для swagger
+// @Summary      Get All Imports
+// @Security ApiKeyAuth
+// @Description  get import jobs of the user with progress and error reports, newest first
+// @Tags         import
+// ID get-all-imports
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  getAllImportsResponse
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/import [get]
+func (h *Handler) getAllImports(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	jobs, err := h.servicesFrom(c).Import.GetAll(userId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getAllImportsResponse{
+		Data: jobs,
+	})
+}
+
+// описываем данные для swagger
+// @Summary      Get Import By Id
+// @Security ApiKeyAuth
+// @Description  get import job with progress and error report
+// @Tags         import
+// ID get-import-by-id
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  todo.ImportJob
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/import/:id [get]
+func (h *Handler) getImportById(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	job, err := h.servicesFrom(c).Import.GetById(userId, id)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
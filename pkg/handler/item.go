@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	todo "to-do-list"
@@ -23,12 +25,20 @@ import (
 // @Accept       json
 // @Produce      json
 // @Param        input body todo.TodoItem true "item data"
+// @Param        Idempotency-Key header string false "key for safe retries of the request"
 // @Success      200  {integer}  integer "id"
-// @Failure      400,404  {object}  errorResponse
-// @Failure      500  {object}  errorResponse
-// @Failure      default  {object}  errorResponse
+// @Failure      400,409,422  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "editor role in the list is required"
+// @Failure      404  {object}  problemResponse  "list not found or not accessible"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
 // @Router       /api/lists/:id/items [post]
 func (h *Handler) createItem(c *gin.Context) {
+	h.createItemFor(c, apiV1{})
+}
+
+// общий для версий api обработчик создания задачи
+func (h *Handler) createItemFor(c *gin.Context, v apiVersion) {
 	userId, err := GetUserId(c)
 	if err != nil {
 		return
@@ -41,37 +51,63 @@ func (h *Handler) createItem(c *gin.Context) {
 		return
 	}
 
-	var input todo.TodoItem
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	// тело запроса читается в структуру, которую задает версия api
+	input, err := v.bindItem(c)
+	if err != nil {
+		newBindErrorResponse(c, err)
 		return
 	}
 
-	id, err := h.services.TodoItem.CreateItem(userId, listId, input)
+	services := h.servicesFrom(c)
+	id, err := services.TodoItem.CreateItem(userId, listId, input)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newDomainErrorResponse(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"id": id,
-	})
+	item, err := services.TodoItem.GetItemById(userId, id)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	v.created(c, id, fmt.Sprintf("/items/%d", id), item.Version, v.item(item))
 }
 
 // описываем данные для swagger
 // @Summary      Get All Items
 // @Security ApiKeyAuth
-// @Description  get all items
+// @Description  get items page of the list, sorted and filtered
 // @Tags         lists
 // ID get-all-items
 // @Accept       json
 // @Produce      json
-// @Success      200  {object}  []todo.TodoItem
-// @Failure      400,404  {object}  errorResponse
-// @Failure      500  {object}  errorResponse
-// @Failure      default  {object}  errorResponse
+// @Param        limit   query  int     false  "page size (1-100, default 50)"
+// @Param        cursor  query  string  false  "next_cursor from previous page"
+// @Param        sort    query  string  false  "sort field"  Enums(created, updated, title, due_date, position, created_at, updated_at, completed_at)
+// @Param        order   query  string  false  "sort order"  Enums(asc, desc)
+// @Param        title   query  string  false  "title substring filter"
+// @Param        done    query  bool    false  "done filter"
+// @Param        completed_after   query  string  false  "completed at or after (RFC 3339)"
+// @Param        completed_before  query  string  false  "completed before (RFC 3339)"
+// @Param        created_after   query  string  false  "created at or after (RFC 3339)"
+// @Param        created_before  query  string  false  "created before (RFC 3339)"
+// @Param        updated_after   query  string  false  "updated at or after (RFC 3339)"
+// @Param        updated_before  query  string  false  "updated before (RFC 3339)"
+// @Param        created_by      query  int     false  "id of the user who created the record"
+// @Param        updated_by      query  int     false  "id of the user who last updated the record"
+// @Success      200  {object}  getAllItemsResponse
+// @Header       200  {string}  Link  "next page link"
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
 // @Router       /api/lists/:id/items [get]
 func (h *Handler) getAllItems(c *gin.Context) {
+	h.getAllItemsFor(c, apiV1{})
+}
+
+// общий для версий api обработчик получения страницы задач списка
+func (h *Handler) getAllItemsFor(c *gin.Context, v apiVersion) {
 	userId, err := GetUserId(c)
 	if err != nil {
 		return
@@ -84,29 +120,44 @@ func (h *Handler) getAllItems(c *gin.Context) {
 		return
 	}
 
-	items, err := h.services.TodoItem.GetAllItems(userId, listId)
+	// получаем параметры пагинации, сортировки и фильтрации
+	query, ok := parsePageQuery(c, todo.ItemSortFields)
+	if !ok {
+		return
+	}
+
+	items, next, err := h.servicesFrom(c).TodoItem.GetAllItems(userId, listId, query)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newDomainErrorResponse(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, items)
+	setNextPageLink(c, next)
+	c.JSON(http.StatusOK, v.itemsPage(items, query.Limit, next))
 }
 
 // описываем данные для swagger
 // @Summary      Get Item By Id
 // @Security ApiKeyAuth
-// @Description  get item by id
+// @Description  get item by id, supports conditional request with If-None-Match
 // @Tags         items
 // ID get-item-by-id
 // @Accept       json
 // @Produce      json
-// @Success      200  {object}  todo.TodoItem
-// @Failure      400,404  {object}  errorResponse
-// @Failure      500  {object}  errorResponse
-// @Failure      default  {object}  errorResponse
+// @Param        If-None-Match  header  string  false  "ETag of cached item"
+// @Success      200  {object}  itemV1
+// @Header       200  {string}  ETag  "item version"
+// @Success      304  {string}  string  "not modified"
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
 // @Router       /api/items/:id [get]
 func (h *Handler) getItemById(c *gin.Context) {
+	h.getItemByIdFor(c, apiV1{})
+}
+
+// общий для версий api обработчик получения задачи
+func (h *Handler) getItemByIdFor(c *gin.Context, v apiVersion) {
 	userId, err := GetUserId(c)
 	if err != nil {
 		return
@@ -119,29 +170,55 @@ func (h *Handler) getItemById(c *gin.Context) {
 		return
 	}
 
-	item, err := h.services.TodoItem.GetItemById(userId, itemId)
+	item, err := h.servicesFrom(c).TodoItem.GetItemById(userId, itemId)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	// если у клиента актуальная версия, отвечаем 304 без тела
+	if notModified(c, item.Version) {
 		return
 	}
 
-	c.JSON(http.StatusOK, item)
+	c.JSON(http.StatusOK, v.item(item))
+}
+
+// функция для ответа 412 с текущим состоянием задачи
+func (h *Handler) itemVersionConflict(c *gin.Context, v apiVersion, userId, itemId int) {
+	item, err := h.servicesFrom(c).TodoItem.GetItemById(userId, itemId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	v.conflict(c, item.Version, v.item(item))
 }
 
 // описываем данные для swagger
 // @Summary      Update Item
 // @Security ApiKeyAuth
-// @Description  update item
+// @Description  update item, with If-Match the update is applied only to the given version
 // @Tags         items
 // ID update-item
 // @Accept       json
 // @Produce      json
+// @Param        If-Match  header  string  false  "expected item ETag"
+// @Param        input body todo.UpdateItemInput true "item data"
 // @Success      200  {string}  string
-// @Failure      400,404  {object}  errorResponse
-// @Failure      500  {object}  errorResponse
-// @Failure      default  {object}  errorResponse
+// @Failure      412  {object}  itemV1  "version conflict, current item"
+// @Failure      400  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "editor role in the list is required"
+// @Failure      404  {object}  problemResponse  "item not found or not accessible"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
 // @Router       /api/items/:id [put]
 func (h *Handler) updateItem(c *gin.Context) {
+	h.updateItemFor(c, apiV1{})
+}
+
+// общий для версий api обработчик изменения задачи
+func (h *Handler) updateItemFor(c *gin.Context, v apiVersion) {
 	userId, err := GetUserId(c)
 	if err != nil {
 		return
@@ -155,36 +232,117 @@ func (h *Handler) updateItem(c *gin.Context) {
 	}
 
 	var input todo.UpdateItemInput
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newBindErrorResponse(c, err)
+		return
+	}
+
+	// ожидаемая версия задачи из заголовка If-Match
+	version, ok := parseIfMatch(c)
+	if !ok {
 		return
 	}
+	input.Version = version
 
-	if err := h.services.TodoItem.UpdateItem(userId, itemId, input); err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	services := h.servicesFrom(c)
+	if err := services.TodoItem.UpdateItem(userId, itemId, input); err != nil {
+		if errors.Is(err, todo.ErrVersionConflict) {
+			h.itemVersionConflict(c, v, userId, itemId)
+			return
+		}
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	item, err := services.TodoItem.GetItemById(userId, itemId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
 		return
 	}
 
 	// добавляем ответ
-	c.JSON(http.StatusOK, statusResponse{
-		Status: "ok",
-	})
+	v.updated(c, item.Version, v.item(item))
+}
+
+// описываем данные для swagger
+// @Summary      Patch Item
+// @Security ApiKeyAuth
+// @Description  partially update item with JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902), done is boolean, id, version, completed_at and audit fields are read-only
+// @Tags         items
+// ID patch-item
+// @Accept       application/merge-patch+json,application/json-patch+json
+// @Produce      json
+// @Param        If-Match  header  string  false  "expected item ETag"
+// @Param        input body object true "merge patch object or json patch operations"
+// @Success      200  {object}  itemV1
+// @Header       200  {string}  ETag  "new item version"
+// @Failure      400,409,415,422  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "editor role in the list is required"
+// @Failure      404  {object}  problemResponse  "item not found or not accessible"
+// @Failure      412  {object}  itemV1  "version conflict, current item"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/items/:id [patch]
+func (h *Handler) patchItem(c *gin.Context) {
+	h.patchItemFor(c, apiV1{})
+}
+
+// общий для версий api обработчик изменения задачи по патчу
+func (h *Handler) patchItemFor(c *gin.Context, v apiVersion) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	patch, ok := readPatch(c)
+	if !ok {
+		return
+	}
+
+	item, err := h.servicesFrom(c).TodoItem.PatchItem(userId, itemId, patch)
+	if err != nil {
+		// при конфликте версий сервис возвращает текущее состояние задачи
+		if errors.Is(err, todo.ErrVersionConflict) {
+			v.conflict(c, item.Version, v.item(item))
+			return
+		}
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(item.Version))
+	c.JSON(http.StatusOK, v.item(item))
 }
 
 // описываем данные для swagger
 // @Summary      Delete Item
 // @Security ApiKeyAuth
-// @Description  delete item
+// @Description  delete item, with If-Match the item is deleted only in the given version
 // @Tags         items
 // ID delete-item
 // @Accept       json
 // @Produce      json
+// @Param        If-Match  header  string  false  "expected item ETag"
 // @Success      200  {string}  string
-// @Failure      400,404  {object}  errorResponse
-// @Failure      500  {object}  errorResponse
-// @Failure      default  {object}  errorResponse
+// @Failure      412  {object}  itemV1  "version conflict, current item"
+// @Failure      400  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "editor role in the list is required"
+// @Failure      404  {object}  problemResponse  "item not found or not accessible"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
 // @Router       /api/items/:id [delete]
 func (h *Handler) deleteItem(c *gin.Context) {
+	h.deleteItemFor(c, apiV1{})
+}
+
+// общий для версий api обработчик удаления задачи
+func (h *Handler) deleteItemFor(c *gin.Context, v apiVersion) {
 	userId, err := GetUserId(c)
 	if err != nil {
 		return
@@ -197,11 +355,70 @@ func (h *Handler) deleteItem(c *gin.Context) {
 		return
 	}
 
-	err = h.services.TodoItem.DeleteItem(userId, itemId)
+	version, ok := parseIfMatch(c)
+	if !ok {
+		return
+	}
+
+	err = h.servicesFrom(c).TodoItem.DeleteItem(userId, itemId, version)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		if errors.Is(err, todo.ErrVersionConflict) {
+			h.itemVersionConflict(c, v, userId, itemId)
+			return
+		}
+		newDomainErrorResponse(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, statusResponse{"ok"})
+	v.deleted(c)
+}
+
+// дополнительная структура для ответа
+type bulkItemsResponse struct {
+	Results []todo.BulkItemResult `json:"results"`
+}
+
+// описываем данные для swagger
+// @Summary      Bulk Items
+// @Security ApiKeyAuth
+// @Description  apply complete, uncomplete, delete, move, label or set_due_date to a set of items in one transaction
+// @Tags         items
+// ID bulk-items
+// @Accept       json
+// @Produce      json
+// @Param        input body todo.BulkItemsInput true "bulk operation"
+// @Param        Idempotency-Key header string false "key for safe retries of the request"
+// @Success      200  {object}  bulkItemsResponse
+// @Failure      400,409,422  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "editor role in the list is required"
+// @Failure      404  {object}  problemResponse  "target list not found or not accessible"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/items/bulk [post]
+func (h *Handler) bulkItems(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	var input todo.BulkItemsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newBindErrorResponse(c, err)
+		return
+	}
+
+	if err := input.Validate(); err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	results, err := h.servicesFrom(c).TodoItem.BulkItems(userId, input)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, bulkItemsResponse{
+		Results: results,
+	})
 }
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	todo "to-do-list"
@@ -23,87 +25,121 @@ import (
 // @Accept       json
 // @Produce      json
 // @Param        input body todo.TodoList true "list data"
+// @Param        Idempotency-Key header string false "key for safe retries of the request"
 // @Success      200  {integer}  integer "id"
-// @Failure      400,404  {object}  errorResponse
-// @Failure      500  {object}  errorResponse
-// @Failure      default  {object}  errorResponse
+// @Failure      400,404,409,422  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
 // @Router       /api/lists [post]
 func (h *Handler) createList(c *gin.Context) {
+	h.createListFor(c, apiV1{})
+}
+
+// общий для версий api обработчик создания списка
+func (h *Handler) createListFor(c *gin.Context, v apiVersion) {
 	userId, err := GetUserId(c)
 	if err != nil {
 		return
 	}
 
-	// структурные теги в todo.go позволят создать json,
-	// так как в полях структур названия полей должны быть с заглавной буквы,
-	// иначе будут неэкспортируемы
-	var input todo.TodoList
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	// тело запроса читается в структуру, которую задает версия api
+	input, err := v.bindList(c)
+	if err != nil {
+		newBindErrorResponse(c, err)
 		return
 	}
 
-	id, err := h.services.TodoList.Create(userId, input)
+	services := h.servicesFrom(c)
+	id, err := services.TodoList.Create(userId, input)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newDomainErrorResponse(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"id": id,
-	})
-}
+	list, err := services.TodoList.GetById(userId, id)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
 
-// дополнительная структура для ответа
-type getAllListsResponse struct {
-	Data []todo.TodoList `json:"data"`
+	v.created(c, id, fmt.Sprintf("/lists/%d", id), list.Version, v.list(list))
 }
 
 // описываем данные для swagger
 // @Summary      Get All Lists
 // @Security ApiKeyAuth
-// @Description  get all lists
+// @Description  get lists page, sorted and filtered
 // @Tags         lists
 // ID get-all-lists
 // @Accept       json
 // @Produce      json
+// @Param        limit   query  int     false  "page size (1-100, default 50)"
+// @Param        cursor  query  string  false  "next_cursor from previous page"
+// @Param        sort    query  string  false  "sort field"  Enums(created, updated, title, position, created_at, updated_at)
+// @Param        order   query  string  false  "sort order"  Enums(asc, desc)
+// @Param        title   query  string  false  "title substring filter"
+// @Param        created_after   query  string  false  "created at or after (RFC 3339)"
+// @Param        created_before  query  string  false  "created before (RFC 3339)"
+// @Param        updated_after   query  string  false  "updated at or after (RFC 3339)"
+// @Param        updated_before  query  string  false  "updated before (RFC 3339)"
+// @Param        created_by      query  int     false  "id of the user who created the record"
+// @Param        updated_by      query  int     false  "id of the user who last updated the record"
 // @Success      200  {object}  getAllListsResponse
-// @Failure      400,404  {object}  errorResponse
-// @Failure      500  {object}  errorResponse
-// @Failure      default  {object}  errorResponse
+// @Header       200  {string}  Link  "next page link"
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
 // @Router       /api/lists [get]
 func (h *Handler) getAllLists(c *gin.Context) {
+	h.getAllListsFor(c, apiV1{})
+}
+
+// общий для версий api обработчик получения страницы списков
+func (h *Handler) getAllListsFor(c *gin.Context, v apiVersion) {
 	userId, err := GetUserId(c)
 	if err != nil {
 		return
 	}
 
-	lists, err := h.services.TodoList.GetAll(userId)
+	// получаем параметры пагинации, сортировки и фильтрации
+	query, ok := parsePageQuery(c, todo.ListSortFields)
+	if !ok {
+		return
+	}
+
+	lists, next, err := h.servicesFrom(c).TodoList.GetAll(userId, query)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newDomainErrorResponse(c, err)
 		return
 	}
 
 	// добавляем ответ
-	c.JSON(http.StatusOK, getAllListsResponse{
-		Data: lists,
-	})
+	setNextPageLink(c, next)
+	c.JSON(http.StatusOK, v.listsPage(lists, query.Limit, next))
 }
 
 // описываем данные для swagger
 // @Summary      Get List By Id
 // @Security ApiKeyAuth
-// @Description  get list by id
+// @Description  get list by id, supports conditional request with If-None-Match
 // @Tags         lists
 // ID get-list-by-id
 // @Accept       json
 // @Produce      json
-// @Success      200  {object}  todo.ListsItem
-// @Failure      400,404  {object}  errorResponse
-// @Failure      500  {object}  errorResponse
-// @Failure      default  {object}  errorResponse
+// @Param        If-None-Match  header  string  false  "ETag of cached list"
+// @Success      200  {object}  listV1
+// @Header       200  {string}  ETag  "list version"
+// @Success      304  {string}  string  "not modified"
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
 // @Router       /api/lists/:id [get]
 func (h *Handler) getListById(c *gin.Context) {
+	h.getListByIdFor(c, apiV1{})
+}
+
+// общий для версий api обработчик получения списка
+func (h *Handler) getListByIdFor(c *gin.Context, v apiVersion) {
 	userId, err := GetUserId(c)
 	if err != nil {
 		return
@@ -118,30 +154,56 @@ func (h *Handler) getListById(c *gin.Context) {
 		return
 	}
 
-	list, err := h.services.TodoList.GetById(userId, listId)
+	list, err := h.servicesFrom(c).TodoList.GetById(userId, listId)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	// если у клиента актуальная версия, отвечаем 304 без тела
+	if notModified(c, list.Version) {
 		return
 	}
 
 	// добавляем ответ
-	c.JSON(http.StatusOK, list)
+	c.JSON(http.StatusOK, v.list(list))
+}
+
+// функция для ответа 412 с текущим состоянием списка
+func (h *Handler) listVersionConflict(c *gin.Context, v apiVersion, userId, listId int) {
+	list, err := h.servicesFrom(c).TodoList.GetById(userId, listId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	v.conflict(c, list.Version, v.list(list))
 }
 
 // описываем данные для swagger
 // @Summary      Update List
 // @Security ApiKeyAuth
-// @Description  update list
+// @Description  update list, with If-Match the update is applied only to the given version
 // @Tags         lists
 // ID update-list
 // @Accept       json
 // @Produce      json
+// @Param        If-Match  header  string  false  "expected list ETag"
+// @Param        input body todo.UpdateListInput true "list data"
 // @Success      200  {string}  string
-// @Failure      400,404  {object}  errorResponse
-// @Failure      500  {object}  errorResponse
-// @Failure      default  {object}  errorResponse
+// @Failure      412  {object}  listV1  "version conflict, current list"
+// @Failure      400  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "editor role in the list is required"
+// @Failure      404  {object}  problemResponse  "list not found or not accessible"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
 // @Router       /api/lists/:id [put]
 func (h *Handler) updateList(c *gin.Context) {
+	h.updateListFor(c, apiV1{})
+}
+
+// общий для версий api обработчик изменения списка
+func (h *Handler) updateListFor(c *gin.Context, v apiVersion) {
 	userId, err := GetUserId(c)
 	if err != nil {
 		return
@@ -155,36 +217,117 @@ func (h *Handler) updateList(c *gin.Context) {
 	}
 
 	var input todo.UpdateListInput
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newBindErrorResponse(c, err)
+		return
+	}
+
+	// ожидаемая версия списка из заголовка If-Match
+	version, ok := parseIfMatch(c)
+	if !ok {
 		return
 	}
+	input.Version = version
 
-	if err := h.services.TodoList.UpdateList(userId, listId, input); err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	services := h.servicesFrom(c)
+	if err := services.TodoList.UpdateList(userId, listId, input); err != nil {
+		if errors.Is(err, todo.ErrVersionConflict) {
+			h.listVersionConflict(c, v, userId, listId)
+			return
+		}
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	list, err := services.TodoList.GetById(userId, listId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
 		return
 	}
 
 	// добавляем ответ
-	c.JSON(http.StatusOK, statusResponse{
-		Status: "ok",
-	})
+	v.updated(c, list.Version, v.list(list))
+}
+
+// описываем данные для swagger
+// @Summary      Patch List
+// @Security ApiKeyAuth
+// @Description  partially update list with JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902), id, version and audit fields are read-only
+// @Tags         lists
+// ID patch-list
+// @Accept       application/merge-patch+json,application/json-patch+json
+// @Produce      json
+// @Param        If-Match  header  string  false  "expected list ETag"
+// @Param        input body object true "merge patch object or json patch operations"
+// @Success      200  {object}  listV1
+// @Header       200  {string}  ETag  "new list version"
+// @Failure      400,409,415,422  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "editor role in the list is required"
+// @Failure      404  {object}  problemResponse  "list not found or not accessible"
+// @Failure      412  {object}  listV1  "version conflict, current list"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/lists/:id [patch]
+func (h *Handler) patchList(c *gin.Context) {
+	h.patchListFor(c, apiV1{})
+}
+
+// общий для версий api обработчик изменения списка по патчу
+func (h *Handler) patchListFor(c *gin.Context, v apiVersion) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	patch, ok := readPatch(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.servicesFrom(c).TodoList.PatchList(userId, listId, patch)
+	if err != nil {
+		// при конфликте версий сервис возвращает текущее состояние списка
+		if errors.Is(err, todo.ErrVersionConflict) {
+			v.conflict(c, list.Version, v.list(list))
+			return
+		}
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(list.Version))
+	c.JSON(http.StatusOK, v.list(list))
 }
 
 // описываем данные для swagger
 // @Summary      Delete List
 // @Security ApiKeyAuth
-// @Description  delete list
+// @Description  delete list, with If-Match the list is deleted only in the given version
 // @Tags         lists
 // ID delete-list
 // @Accept       json
 // @Produce      json
+// @Param        If-Match  header  string  false  "expected list ETag"
 // @Success      200  {string}  string
-// @Failure      400,404  {object}  errorResponse
-// @Failure      500  {object}  errorResponse
-// @Failure      default  {object}  errorResponse
+// @Failure      412  {object}  listV1  "version conflict, current list"
+// @Failure      400  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "owner role in the list is required"
+// @Failure      404  {object}  problemResponse  "list not found or not accessible"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
 // @Router       /api/lists/:id [delete]
 func (h *Handler) deleteList(c *gin.Context) {
+	h.deleteListFor(c, apiV1{})
+}
+
+// общий для версий api обработчик удаления списка
+func (h *Handler) deleteListFor(c *gin.Context, v apiVersion) {
 	userId, err := GetUserId(c)
 	if err != nil {
 		return
@@ -197,14 +340,21 @@ func (h *Handler) deleteList(c *gin.Context) {
 		return
 	}
 
-	err = h.services.TodoList.DeleteList(userId, listId)
+	version, ok := parseIfMatch(c)
+	if !ok {
+		return
+	}
+
+	err = h.servicesFrom(c).TodoList.DeleteList(userId, listId, version)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		if errors.Is(err, todo.ErrVersionConflict) {
+			h.listVersionConflict(c, v, userId, listId)
+			return
+		}
+		newDomainErrorResponse(c, err)
 		return
 	}
 
 	// добавляем ответ
-	c.JSON(http.StatusOK, statusResponse{
-		Status: "ok",
-	})
+	v.deleted(c)
 }
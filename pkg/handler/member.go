@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	todo "to-do-list"
+
+	"github.com/gin-gonic/gin"
+)
+
+// дополнительная структура для ответа
+type getMembersResponse struct {
+	Data []todo.ListMember `json:"data"`
+}
+
+// описываем данные для swagger
+// @Summary      Get List Members
+// @Security ApiKeyAuth
+// @Description  get members of the list with their roles (owner, editor, viewer)
+// @Tags         members
+// ID get-list-members
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  getMembersResponse
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/lists/:id/members [get]
+func (h *Handler) getListMembers(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	members, err := h.servicesFrom(c).ListMember.GetMembers(userId, listId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getMembersResponse{
+		Data: members,
+	})
+}
+
+// описываем данные для swagger
+// @Summary      Set List Member
+// @Security ApiKeyAuth
+// @Description  share the list with a user or change the member's role, only the owner can manage members
+// @Tags         members
+// ID set-list-member
+// @Accept       json
+// @Produce      json
+// @Param        input body todo.ListMemberInput true "username and role (editor or viewer)"
+// @Success      200  {object}  todo.ListMember
+// @Failure      400,404,409  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "caller is not the owner"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/lists/:id/members [put]
+func (h *Handler) setListMember(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.ListMemberInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newBindErrorResponse(c, err)
+		return
+	}
+
+	member, err := h.servicesFrom(c).ListMember.SetMember(userId, listId, input)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// описываем данные для swagger
+// @Summary      Delete List Member
+// @Security ApiKeyAuth
+// @Description  remove a member from the list: the owner can remove any member, other members can only leave the list
+// @Tags         members
+// ID delete-list-member
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  statusResponse
+// @Failure      400,404,409  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "caller is not the owner"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/lists/:id/members/:userId [delete]
+func (h *Handler) deleteListMember(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	memberId, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid user id param")
+		return
+	}
+
+	if err := h.servicesFrom(c).ListMember.DeleteMember(userId, listId, memberId); err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{
+		Status: "ok",
+	})
+}
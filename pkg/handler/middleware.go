@@ -3,7 +3,9 @@ package handler
 import (
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	todo "to-do-list"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,6 +13,9 @@ import (
 const (
 	authorizationHeader = "Authorization"
 	userCtx             = "userId"
+
+	// схема авторизации клиентов CalDAV
+	caldavRealm = `Basic realm="todo", charset="UTF-8"`
 )
 
 // метод мидлвары для идентификация user и записи его id в контекст
@@ -32,10 +37,10 @@ func (h *Handler) userIdentity(c *gin.Context) {
 	}
 
 	// используем функцию сервиса для парсинга токена и получения UserId
-	UserId, err := h.services.Authorization.ParseToken(headerParts[1])
+	UserId, err := h.servicesFrom(c).Authorization.ParseToken(headerParts[1])
 	if err != nil {
 		// возвращаем статус 401, пользователь не авторизован
-		newErrorResponse(c, http.StatusUnauthorized, err.Error())
+		newDomainErrorResponse(c, err)
 		return
 	}
 
@@ -43,6 +48,25 @@ func (h *Handler) userIdentity(c *gin.Context) {
 	c.Set(userCtx, UserId)
 }
 
+// метод мидлвары для потока событий: EventSource в браузере не может
+// передать заголовок Authorization, поэтому поток принимает также
+// короткоживущий токен из параметра token (выдается createStreamToken, stream.go)
+func (h *Handler) streamIdentity(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		h.userIdentity(c)
+		return
+	}
+
+	userId, err := h.servicesFrom(c).Authorization.ParseStreamToken(token)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.Set(userCtx, userId)
+}
+
 // метод для приведения id пользователя к типу Int
 func GetUserId(c *gin.Context) (int, error) {
 	id, ok := c.Get(userCtx)
@@ -58,3 +82,42 @@ func GetUserId(c *gin.Context) (int, error) {
 	}
 	return idInt, nil
 }
+
+// метод мидлвары для клиентов CalDAV (caldav.go): клиенты календарей
+// не поддерживают токены, поэтому пользователь авторизуется логином и паролем (Basic).
+// Неудачные попытки ограничиваются caldavLimiter, при превышении - статус 429
+func (h *Handler) caldavIdentity(c *gin.Context) {
+	username, password, ok := c.Request.BasicAuth()
+	if !ok {
+		c.Header("WWW-Authenticate", caldavRealm)
+		newErrorResponse(c, http.StatusUnauthorized, "basic auth required")
+		return
+	}
+
+	// адрес берется из соединения: заголовок X-Forwarded-For задает сам клиент
+	remoteIP, _ := c.RemoteIP()
+	ipKey := "ip:" + remoteIP.String()
+	userKey := "user:" + strings.ToLower(username)
+	wait := h.caldavLimiter.retryAfter(ipKey, maxAuthFailuresPerIP)
+	if userWait := h.caldavLimiter.retryAfter(userKey, maxAuthFailuresPerUser); userWait > wait {
+		wait = userWait
+	}
+	if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		newErrorResponse(c, http.StatusTooManyRequests, "too many failed login attempts")
+		return
+	}
+
+	userId, err := h.servicesFrom(c).Authorization.Authenticate(username, password)
+	if err != nil {
+		if errors.Is(err, todo.ErrUnauthorized) {
+			h.caldavLimiter.fail(ipKey, userKey)
+			c.Header("WWW-Authenticate", caldavRealm)
+		}
+		newDomainErrorResponse(c, err)
+		return
+	}
+	h.caldavLimiter.reset(userKey)
+
+	c.Set(userCtx, userId)
+}
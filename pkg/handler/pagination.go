@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"fmt"
+	todo "to-do-list"
+
+	"github.com/gin-gonic/gin"
+)
+
+// функция для чтения и валидации параметров выборки коллекции из строки запроса
+// в случае ошибки записывает в ответ статус 400 с ошибками по полям
+func parsePageQuery(c *gin.Context, sortFields []string) (todo.PageQuery, bool) {
+	var query todo.PageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		newBindErrorResponse(c, err)
+		return query, false
+	}
+
+	if err := query.Validate(sortFields); err != nil {
+		newDomainErrorResponse(c, err)
+		return query, false
+	}
+
+	return query, true
+}
+
+// функция добавляет заголовок Link со ссылкой на следующую страницу
+func setNextPageLink(c *gin.Context, nextCursor string) {
+	if nextCursor == "" {
+		return
+	}
+
+	next := *c.Request.URL
+	values := next.Query()
+	values.Set("cursor", nextCursor)
+	next.RawQuery = values.Encode()
+
+	c.Header("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+	todo "to-do-list"
+
+	"github.com/gin-gonic/gin"
+)
+
+// функция для чтения PATCH-запроса: формат патча из Content-Type,
+// тело и ожидаемая версия из If-Match.
+// В случае ошибки записывает в ответ статус 400 или 415
+func readPatch(c *gin.Context) (todo.Patch, bool) {
+	patch := todo.Patch{ContentType: c.ContentType()}
+
+	if patch.ContentType != todo.MergePatchType && patch.ContentType != todo.JSONPatchType {
+		newDomainErrorResponse(c, todo.ErrUnsupportedPatch)
+		return patch, false
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return patch, false
+	}
+	patch.Body = body
+
+	version, ok := parseIfMatch(c)
+	if !ok {
+		return patch, false
+	}
+	patch.Version = version
+
+	return patch, true
+}
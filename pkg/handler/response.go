@@ -1,13 +1,36 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+	todo "to-do-list"
+
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 	"github.com/sirupsen/logrus"
 )
 
-// объявляем структуру ошибки
-type errorResponse struct {
-	Message string `json:"message"`
+// тип содержимого ответов с ошибкой (RFC 7807)
+const problemContentType = "application/problem+json"
+
+// объявляем структуру ошибки в формате RFC 7807:
+// type - about:blank, title - текст статуса, detail - описание ошибки,
+// instance - путь запроса, code - стабильный машиночитаемый код ошибки,
+// errors - ошибки валидации по полям, current - текущее состояние записи при конфликте версий
+type problemResponse struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     string            `json:"code"`
+	Errors   []todo.FieldError `json:"errors,omitempty"`
+	Current  interface{}       `json:"current,omitempty" swaggertype:"object"`
 }
 
 // структура статуса ответа
@@ -15,10 +38,188 @@ type statusResponse struct {
 	Status string `json:"status"`
 }
 
-// функция для обработки ошибок
+// машиночитаемые коды ошибок, на них могут опираться клиенты
+const (
+	codeBadRequest           = "bad_request"
+	codeValidationFailed     = "validation_failed"
+	codeUnauthorized         = "unauthorized"
+	codeForbidden            = "forbidden"
+	codeNotFound             = "not_found"
+	codeConflict             = "conflict"
+	codeVersionConflict      = "version_conflict"
+	codeInvalidCursor        = "invalid_cursor"
+	codeInvalidPatch         = "invalid_patch"
+	codePatchTestFailed      = "patch_test_failed"
+	codeInvalidDocument      = "invalid_document"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeIdempotencyMismatch  = "idempotency_key_mismatch"
+	codeIdempotencyInFlight  = "idempotency_key_in_progress"
+	codeTooManyRequests      = "too_many_requests"
+	codeInternal             = "internal_error"
+)
+
+// коды ошибок по умолчанию для статусов ответа
+var statusCodes = map[int]string{
+	http.StatusBadRequest:           codeBadRequest,
+	http.StatusUnauthorized:         codeUnauthorized,
+	http.StatusForbidden:            codeForbidden,
+	http.StatusNotFound:             codeNotFound,
+	http.StatusConflict:             codeConflict,
+	http.StatusPreconditionFailed:   codeVersionConflict,
+	http.StatusUnsupportedMediaType: codeUnsupportedMediaType,
+	http.StatusInternalServerError:  codeInternal,
+	http.StatusUnprocessableEntity:  codeValidationFailed,
+	http.StatusTooManyRequests:      codeTooManyRequests,
+}
+
+// соответствие ошибок предметной области статусам ответа и кодам ошибок,
+// проверяется по порядку: частные ошибки идут раньше общих
+var domainErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{todo.ErrInvalidCursor, http.StatusBadRequest, codeInvalidCursor},
+	{todo.ErrUnsupportedPatch, http.StatusUnsupportedMediaType, codeUnsupportedMediaType},
+	{todo.ErrInvalidPatch, http.StatusBadRequest, codeInvalidPatch},
+	{todo.ErrPatchTestFailed, http.StatusConflict, codePatchTestFailed},
+	{todo.ErrInvalidDocument, http.StatusUnprocessableEntity, codeInvalidDocument},
+	{todo.ErrVersionConflict, http.StatusPreconditionFailed, codeVersionConflict},
+	{todo.ErrValidation, http.StatusBadRequest, codeValidationFailed},
+	{todo.ErrUnauthorized, http.StatusUnauthorized, codeUnauthorized},
+	{todo.ErrForbidden, http.StatusForbidden, codeForbidden},
+	{todo.ErrNotFound, http.StatusNotFound, codeNotFound},
+	{todo.ErrConflict, http.StatusConflict, codeConflict},
+}
+
+// функция записывает в ответ ошибку в формате problem+json
+// и блокирует выполнение следующих обработчиков
+func newProblemResponse(c *gin.Context, statusCode int, code, detail string, fields []todo.FieldError) {
+	writeProblem(c, newProblem(c, statusCode, code, detail, fields))
+}
+
+func newProblem(c *gin.Context, statusCode int, code, detail string, fields []todo.FieldError) problemResponse {
+	return problemResponse{
+		Type:     "about:blank",
+		Title:    http.StatusText(statusCode),
+		Status:   statusCode,
+		Detail:   detail,
+		Instance: problemInstance(c),
+		Code:     code,
+		Errors:   fields,
+	}
+}
+
+func writeProblem(c *gin.Context, problem problemResponse) {
+	data, err := json.Marshal(problem)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.Abort()
+	c.Data(problem.Status, problemContentType, data)
+}
+
+// путь запроса для поля instance. Токен ленты календаря в пути заменяется на :token,
+// чтобы секретная ссылка не попадала в журналы вместе с ответами об ошибках
+func problemInstance(c *gin.Context) string {
+	if strings.HasPrefix(c.Request.URL.Path, calendarFeedPath) {
+		return calendarFeedPath + ":token"
+	}
+
+	return c.Request.URL.Path
+}
+
+// функция для обработки ошибок хендлера, код ошибки определяется по статусу
 func newErrorResponse(c *gin.Context, statusCode int, message string) {
 	logrus.Error(message)
 
-	// метод блокирует выполнение следующих обработчик и записывает в ответ статус и сообщение
-	c.AbortWithStatusJSON(statusCode, errorResponse{message})
+	code, ok := statusCodes[statusCode]
+	if !ok {
+		code = codeBadRequest
+	}
+
+	newProblemResponse(c, statusCode, code, message, nil)
+}
+
+// функция для обработки ошибок сервисов: ошибки предметной области
+// переводятся в статус и код ответа, ошибки валидации - в ошибки по полям.
+// Текст остальных ошибок (например, ошибок БД) клиенту не передается
+func newDomainErrorResponse(c *gin.Context, err error) {
+	// при If-Match: * отсутствие записи - невыполненное предусловие, а не 404
+	if errors.Is(err, todo.ErrNotFound) && c.GetBool(ifMatchAnyCtx) {
+		newProblemResponse(c, http.StatusPreconditionFailed, codeVersionConflict, "precondition failed: resource does not exist", nil)
+		return
+	}
+
+	for _, known := range domainErrors {
+		if !errors.Is(err, known.err) {
+			continue
+		}
+
+		var fields []todo.FieldError
+		var validationErr *todo.ValidationError
+		if errors.As(err, &validationErr) {
+			fields = validationErr.Fields
+		}
+
+		newProblemResponse(c, known.status, known.code, err.Error(), fields)
+		return
+	}
+
+	logrus.Error(err.Error())
+	newProblemResponse(c, http.StatusInternalServerError, codeInternal, "internal server error", nil)
+}
+
+// функция для обработки ошибок чтения json-тела и строки запроса:
+// ошибки тегов binding и несоответствие типов полей возвращаются как ошибки по полям
+func newBindErrorResponse(c *gin.Context, err error) {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		errs := &todo.ValidationError{}
+		for _, fieldErr := range validationErrs {
+			if fieldErr.Tag() == "required" {
+				errs.Add(fieldErr.Field(), todo.CodeRequired, "field is required")
+				continue
+			}
+			errs.Add(fieldErr.Field(), todo.CodeInvalid, "field failed on the '"+fieldErr.Tag()+"' rule")
+		}
+		newDomainErrorResponse(c, errs)
+		return
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		newDomainErrorResponse(c, todo.NewValidationError(typeErr.Field, todo.CodeInvalid, "field must be of type "+typeErr.Type.String()))
+		return
+	}
+
+	newErrorResponse(c, http.StatusBadRequest, "invalid request: "+err.Error())
+}
+
+// функция настраивает валидатор gin так, чтобы в ошибках
+// использовались имена полей из json-тегов, а не из структур
+func registerJSONFieldNames() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
+}
+
+// функция переносит срок записи ответа (WriteTimeout сервера) для долгих ответов:
+// потока событий и выгрузки данных. Соединение может отсутствовать в контексте
+// (сервер запущен не через todo.Server), тогда действует WriteTimeout сервера
+func setWriteDeadline(c *gin.Context, deadline time.Time) {
+	if err := todo.SetWriteDeadline(c.Request.Context(), deadline); err != nil {
+		logrus.Debugf("write deadline: %s", err.Error())
+	}
 }
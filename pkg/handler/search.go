@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+	todo "to-do-list"
+
+	"github.com/gin-gonic/gin"
+)
+
+// дополнительная структура для ответа
+type searchResponse struct {
+	Data []todo.SearchResult `json:"data"`
+}
+
+// описываем данные для swagger
+// @Summary      Search
+// @Security ApiKeyAuth
+// @Description  full-text search in titles and descriptions of user's lists and items, results are ranked and contain highlighted snippets
+// @Tags         search
+// ID search
+// @Accept       json
+// @Produce      json
+// @Param        q      query  string  true   "search query (websearch syntax)"
+// @Param        limit  query  int     false  "max results (1-100, default 20)"
+// @Success      200  {object}  searchResponse
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/search [get]
+func (h *Handler) search(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	var query todo.SearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		newBindErrorResponse(c, err)
+		return
+	}
+
+	if err := query.Validate(); err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	results, err := h.servicesFrom(c).Search.Search(userId, query)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, searchResponse{
+		Data: results,
+	})
+}
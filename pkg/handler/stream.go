@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	todo "to-do-list"
+	"to-do-list/pkg/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// длительность потока, если в конфиге не задано events.stream_timeout
+	defaultStreamTimeout = time.Hour
+	// интервал комментариев, поддерживающих соединение через прокси
+	streamHeartbeat = 15 * time.Second
+	// размер страницы повтора пропущенных событий
+	replayPageSize = 500
+	// задержка переподключения клиента в миллисекундах
+	streamRetry = 3000
+	// время на запись в поток: поток длится дольше WriteTimeout сервера,
+	// поэтому срок записи продлевается перед каждой записью
+	streamWriteTimeout = 10 * time.Second
+)
+
+// описываем данные для swagger
+// @Summary      Event Stream
+// @Security ApiKeyAuth
+// @Description  Server-Sent Events stream of create, update and delete events for lists and items available to the user.
+// @Description  Each event has a stream cursor in id, event type (list.created, list.updated, list.deleted, list.members_updated, item.created, item.updated, item.deleted, item.moved) and json data with the event id.
+// @Description  On reconnect pass the last received cursor in Last-Event-ID header (or last_event_id param) to replay missed events, the cursor may also arrive without event data.
+// @Description  Events are delivered at least once, a replayed event may repeat an already received one with the same data id.
+// @Description  The stream is closed after events.stream_timeout, the client should reconnect.
+// @Description  Browser clients (EventSource can not set the Authorization header) pass a stream token from POST /api/stream/token in token param.
+// @Description  The token expires in a minute and is checked only on connect: to reconnect request a new token and pass the last cursor in last_event_id param.
+// @Tags         events
+// ID stream
+// @Produce      text/event-stream
+// @Param        token          query   string  false  "stream token, instead of the Authorization header"
+// @Param        Last-Event-ID  header  string  false  "cursor of the last received event"
+// @Param        last_event_id  query   string  false  "cursor of the last received event, for clients that can not set headers"
+// @Success      200  {object}  todo.Event  "event data"
+// @Failure      400,401  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/stream [get]
+func (h *Handler) stream(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	cursor, afterId, replay, ok := parseLastEventId(c)
+	if !ok {
+		return
+	}
+
+	// подписываемся до повтора, чтобы не пропустить события, опубликованные во время повтора
+	events := h.services.Events
+	sub := events.Subscribe(userId)
+	defer events.Unsubscribe(sub)
+
+	// первая страница запрашивается до начала потока, чтобы вернуть ошибку обычным ответом
+	var page []todo.Event
+	var snapshot string
+	if replay {
+		if page, snapshot, err = events.Replay(userId, cursor.snapshot, afterId, replayPageSize); err != nil {
+			newDomainErrorResponse(c, err)
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// отключаем буферизацию ответа в nginx
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	setWriteDeadline(c, time.Now().Add(streamWriteTimeout))
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetry)
+
+	// повтор пропущенных событий постранично. События повтора получают курсор клиента:
+	// если поток оборвется во время повтора, повтор начнется заново.
+	// После повтора клиент получает снимок первой страницы - все видимые в нем события повторены
+	replayed := make(map[int64]bool)
+	for len(page) > 0 {
+		setWriteDeadline(c, time.Now().Add(streamWriteTimeout))
+		for _, event := range page {
+			writeEvent(c, event, cursor.value)
+			replayed[event.Id] = true
+			afterId = event.Id
+		}
+		c.Writer.Flush()
+
+		if len(page) < replayPageSize {
+			break
+		}
+		if page, _, err = events.Replay(userId, cursor.snapshot, afterId, replayPageSize); err != nil {
+			// клиент переподключится и повторит события со своего курсора
+			return
+		}
+	}
+	setWriteDeadline(c, time.Now().Add(streamWriteTimeout))
+	if replay {
+		writeCursor(c, snapshot)
+	}
+	c.Writer.Flush()
+
+	timeout := h.cfg.StreamTimeout
+	if timeout <= 0 {
+		timeout = defaultStreamTimeout
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-deadline.C:
+			return
+		case event, ok := <-sub.Events():
+			// подписка закрыта брокером, клиент переподключится с Last-Event-ID
+			if !ok {
+				return
+			}
+			// событие уже отправлено при повторе
+			if replayed[event.Id] {
+				continue
+			}
+			setWriteDeadline(c, time.Now().Add(streamWriteTimeout))
+			writeEvent(c, event.Event, event.Cursor)
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			setWriteDeadline(c, time.Now().Add(streamWriteTimeout))
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+// дополнительная структура для ответа, expires_in - время жизни токена в секундах
+type streamTokenResponse struct {
+	Token     string `json:"token"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// описываем данные для swagger
+// @Summary      Create Stream Token
+// @Security ApiKeyAuth
+// @Description  short-lived token for GET /api/stream, passed in token param by clients that can not set the Authorization header (EventSource).
+// @Description  The token is valid only for the event stream.
+// @Tags         events
+// ID create-stream-token
+// @Produce      json
+// @Success      200  {object}  streamTokenResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/stream/token [post]
+func (h *Handler) createStreamToken(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	token, err := h.servicesFrom(c).Authorization.GenerateStreamToken(userId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, streamTokenResponse{
+		Token:     token,
+		ExpiresIn: int(service.StreamTokenTTL / time.Second),
+	})
+}
+
+// курсор потока из Last-Event-ID: value - значение, полученное от клиента,
+// snapshot - снимок транзакций, пустой для id события (формат курсора до перехода на снимки)
+type streamCursor struct {
+	value    string
+	snapshot string
+}
+
+// функция читает курсор последнего полученного клиентом события:
+// из заголовка Last-Event-ID (отправляется EventSource при переподключении) или параметра last_event_id.
+// Курсор - снимок транзакций "xmin:xmax:xip,...", число - id события в прежнем формате,
+// повтор для него выполняется по id, начиная с afterId.
+// replay - нужно ли повторить пропущенные события
+func parseLastEventId(c *gin.Context) (streamCursor, int64, bool, bool) {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	if value == "" {
+		return streamCursor{}, 0, false, true
+	}
+
+	if afterId, err := strconv.ParseInt(value, 10, 64); err == nil && afterId >= 0 {
+		return streamCursor{value: value}, afterId, true, true
+	}
+
+	if !validSnapshot(value) {
+		newErrorResponse(c, http.StatusBadRequest, "invalid Last-Event-ID")
+		return streamCursor{}, 0, false, false
+	}
+
+	return streamCursor{value: value, snapshot: value}, 0, true, true
+}
+
+// функция проверяет формат снимка транзакций, как его проверяет PostgreSQL:
+// xmin <= xmax, id активных транзакций не убывают и лежат в [xmin, xmax)
+func validSnapshot(value string) bool {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return false
+	}
+
+	xmin, err := strconv.ParseUint(parts[0], 10, 63)
+	if err != nil {
+		return false
+	}
+	xmax, err := strconv.ParseUint(parts[1], 10, 63)
+	if err != nil || xmin > xmax {
+		return false
+	}
+	if parts[2] == "" {
+		return true
+	}
+
+	last := xmin
+	for _, part := range strings.Split(parts[2], ",") {
+		xip, err := strconv.ParseUint(part, 10, 63)
+		if err != nil || xip < last || xip >= xmax {
+			return false
+		}
+		last = xip
+	}
+
+	return true
+}
+
+// функция записывает событие в формате text/event-stream, в поле id - курсор потока
+func writeEvent(c *gin.Context, event todo.Event, cursor string) {
+	data, _ := json.Marshal(event)
+	fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", cursor, event.Type, data)
+}
+
+// функция передает клиенту курсор без события:
+// сообщение без data не доставляется, но EventSource запоминает его id для Last-Event-ID
+func writeCursor(c *gin.Context, cursor string) {
+	fmt.Fprintf(c.Writer, "id: %s\n\n", cursor)
+}
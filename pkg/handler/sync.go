@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"net/http"
+	todo "to-do-list"
+
+	"github.com/gin-gonic/gin"
+)
+
+// обработчики протокола синхронизации офлайн-клиентов,
+// протокол и правила разрешения конфликтов описаны в sync.go корневого пакета
+
+// описываем данные для swagger
+// @Summary      Get Sync Changes
+// @Security ApiKeyAuth
+// @Description  get lists, items, memberships and tombstones changed since the cursor, without since - full snapshot
+// @Tags         sync
+// ID get-sync-changes
+// @Accept       json
+// @Produce      json
+// @Param        since  query  string  false  "cursor from previous sync response"
+// @Success      200  {object}  todo.SyncChanges
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/sync [get]
+func (h *Handler) getSyncChanges(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	changes, err := h.servicesFrom(c).Sync.Changes(userId, c.Query("since"))
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
+
+// дополнительная структура для ответа
+type syncPushResponse struct {
+	Results []todo.SyncResult `json:"results"`
+}
+
+// описываем данные для swagger
+// @Summary      Push Sync Mutations
+// @Security ApiKeyAuth
+// @Description  apply offline mutations in order, conflicts are resolved per field (last writer wins by changed_at)
+// @Tags         sync
+// ID push-sync-mutations
+// @Accept       json
+// @Produce      json
+// @Param        input body todo.SyncPushInput true "mutations"
+// @Param        Idempotency-Key header string false "key for safe retries of the request"
+// @Success      200  {object}  syncPushResponse
+// @Failure      400,404,422  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/sync [post]
+func (h *Handler) pushSyncMutations(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	var input todo.SyncPushInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newBindErrorResponse(c, err)
+		return
+	}
+
+	results, err := h.servicesFrom(c).Sync.Push(userId, input)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, syncPushResponse{
+		Results: results,
+	})
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+	todo "to-do-list"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Представление ответов первой версии api. Поля записей зафиксированы
+// в том виде, в котором v1 была выпущена: поля, добавленные в структуры todo.go
+// позже (поля аудита, completed_at), в ответы v1 не попадают.
+
+// список в ответах v1
+type listV1 struct {
+	Id          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Position    int    `json:"position"`
+	Version     int    `json:"version"`
+}
+
+// задача в ответах v1, done - строка "true" или "false"
+type itemV1 struct {
+	Id          int         `json:"id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Done        string      `json:"done"`
+	Position    int         `json:"position"`
+	DueDate     *time.Time  `json:"due_date"`
+	Priority    int         `json:"priority"`
+	Labels      todo.Labels `json:"labels"`
+	Version     int         `json:"version"`
+}
+
+// дополнительная структура для ответа
+// next_cursor пустой, если следующей страницы нет
+type getAllListsResponse struct {
+	Data       []listV1 `json:"data"`
+	NextCursor string   `json:"next_cursor"`
+}
+
+type getAllItemsResponse struct {
+	Data       []itemV1 `json:"data"`
+	NextCursor string   `json:"next_cursor"`
+}
+
+type apiV1 struct{}
+
+// в v1 тело запроса на создание читается в структуры todo.go
+func (apiV1) bindList(c *gin.Context) (todo.TodoList, error) {
+	var input todo.TodoList
+	err := c.ShouldBindJSON(&input)
+	return input, err
+}
+
+func (apiV1) bindItem(c *gin.Context) (todo.TodoItem, error) {
+	var input todo.TodoItem
+	err := c.ShouldBindJSON(&input)
+	return input, err
+}
+
+func (apiV1) list(list todo.TodoList) interface{} {
+	return newListV1(list)
+}
+
+func (apiV1) item(item todo.TodoItem) interface{} {
+	return newItemV1(item)
+}
+
+func (apiV1) listsPage(lists []todo.TodoList, limit int, next string) interface{} {
+	page := getAllListsResponse{Data: make([]listV1, 0, len(lists)), NextCursor: next}
+	for _, list := range lists {
+		page.Data = append(page.Data, newListV1(list))
+	}
+
+	return page
+}
+
+func (apiV1) itemsPage(items []todo.TodoItem, limit int, next string) interface{} {
+	page := getAllItemsResponse{Data: make([]itemV1, 0, len(items)), NextCursor: next}
+	for _, item := range items {
+		page.Data = append(page.Data, newItemV1(item))
+	}
+
+	return page
+}
+
+// создание отвечает id записи, изменение и удаление - статусом
+func (apiV1) created(c *gin.Context, id int, location string, version int, body interface{}) {
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
+
+func (apiV1) updated(c *gin.Context, version int, body interface{}) {
+	c.JSON(http.StatusOK, statusResponse{
+		Status: "ok",
+	})
+}
+
+func (apiV1) deleted(c *gin.Context) {
+	c.JSON(http.StatusOK, statusResponse{
+		Status: "ok",
+	})
+}
+
+// при конфликте версий в теле ответа - текущее состояние записи
+func (apiV1) conflict(c *gin.Context, version int, body interface{}) {
+	preconditionFailed(c, version, body)
+}
+
+func newListV1(list todo.TodoList) listV1 {
+	return listV1{
+		Id:          list.Id,
+		Title:       list.Title,
+		Description: list.Description,
+		Position:    list.Position,
+		Version:     list.Version,
+	}
+}
+
+func newItemV1(item todo.TodoItem) itemV1 {
+	return itemV1{
+		Id:          item.Id,
+		Title:       item.Title,
+		Description: item.Description,
+		Done:        item.Done,
+		Position:    item.Position,
+		DueDate:     item.DueDate,
+		Priority:    item.Priority,
+		Labels:      item.Labels,
+		Version:     item.Version,
+	}
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	todo "to-do-list"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DTO второй версии api. Отделены от структур БД из todo.go,
+// поэтому схема БД может меняться без изменения ответов v2.
+
+// список в ответах v2
+type listV2 struct {
+	Id          int       `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Position    int       `json:"position"`
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	CreatedBy   *int      `json:"created_by"`
+	UpdatedBy   *int      `json:"updated_by"`
+}
+
+// задача в ответах v2, done - логическое значение
+type itemV2 struct {
+	Id          int        `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Done        bool       `json:"done"`
+	Position    int        `json:"position"`
+	DueDate     *time.Time `json:"due_date"`
+	Priority    int        `json:"priority"`
+	Labels      []string   `json:"labels"`
+	Version     int        `json:"version"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CreatedBy   *int       `json:"created_by"`
+	UpdatedBy   *int       `json:"updated_by"`
+}
+
+// параметры страницы коллекции:
+// has_more - есть следующая страница, next_cursor - курсор для её получения
+type pageInfoV2 struct {
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type listsPageV2 struct {
+	Data       []listV2   `json:"data"`
+	Pagination pageInfoV2 `json:"pagination"`
+}
+
+type itemsPageV2 struct {
+	Data       []itemV2   `json:"data"`
+	Pagination pageInfoV2 `json:"pagination"`
+}
+
+// данные запроса на создание списка
+type createListInputV2 struct {
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	Position    int    `json:"position"`
+}
+
+// данные запроса на создание задачи
+type createItemInputV2 struct {
+	Title       string     `json:"title" binding:"required"`
+	Description string     `json:"description"`
+	Position    int        `json:"position"`
+	DueDate     *time.Time `json:"due_date"`
+	Priority    int        `json:"priority"`
+	Labels      []string   `json:"labels"`
+}
+
+func (i createListInputV2) toList() todo.TodoList {
+	return todo.TodoList{
+		Title:       i.Title,
+		Description: i.Description,
+		Position:    i.Position,
+	}
+}
+
+func (i createItemInputV2) toItem() todo.TodoItem {
+	return todo.TodoItem{
+		Title:       i.Title,
+		Description: i.Description,
+		Position:    i.Position,
+		DueDate:     i.DueDate,
+		Priority:    i.Priority,
+		Labels:      i.Labels,
+	}
+}
+
+func newListV2(list todo.TodoList) listV2 {
+	return listV2{
+		Id:          list.Id,
+		Title:       list.Title,
+		Description: list.Description,
+		Position:    list.Position,
+		Version:     list.Version,
+		CreatedAt:   list.CreatedAt,
+		UpdatedAt:   list.UpdatedAt,
+		CreatedBy:   list.CreatedBy,
+		UpdatedBy:   list.UpdatedBy,
+	}
+}
+
+func newItemV2(item todo.TodoItem) itemV2 {
+	done, _ := strconv.ParseBool(item.Done)
+
+	labels := []string(item.Labels)
+	if labels == nil {
+		labels = []string{}
+	}
+
+	return itemV2{
+		Id:          item.Id,
+		Title:       item.Title,
+		Description: item.Description,
+		Done:        done,
+		Position:    item.Position,
+		DueDate:     item.DueDate,
+		Priority:    item.Priority,
+		Labels:      labels,
+		Version:     item.Version,
+		CompletedAt: item.CompletedAt,
+		CreatedAt:   item.CreatedAt,
+		UpdatedAt:   item.UpdatedAt,
+		CreatedBy:   item.CreatedBy,
+		UpdatedBy:   item.UpdatedBy,
+	}
+}
+
+func newListsPageV2(lists []todo.TodoList, limit int, next string) listsPageV2 {
+	page := listsPageV2{
+		Data:       make([]listV2, 0, len(lists)),
+		Pagination: pageInfoV2{Limit: limit, HasMore: next != "", NextCursor: next},
+	}
+	for _, list := range lists {
+		page.Data = append(page.Data, newListV2(list))
+	}
+
+	return page
+}
+
+func newItemsPageV2(items []todo.TodoItem, limit int, next string) itemsPageV2 {
+	page := itemsPageV2{
+		Data:       make([]itemV2, 0, len(items)),
+		Pagination: pageInfoV2{Limit: limit, HasMore: next != "", NextCursor: next},
+	}
+	for _, item := range items {
+		page.Data = append(page.Data, newItemV2(item))
+	}
+
+	return page
+}
+
+type apiV2 struct{}
+
+func (apiV2) bindList(c *gin.Context) (todo.TodoList, error) {
+	var input createListInputV2
+	err := c.ShouldBindJSON(&input)
+	return input.toList(), err
+}
+
+func (apiV2) bindItem(c *gin.Context) (todo.TodoItem, error) {
+	var input createItemInputV2
+	err := c.ShouldBindJSON(&input)
+	return input.toItem(), err
+}
+
+func (apiV2) list(list todo.TodoList) interface{} {
+	return newListV2(list)
+}
+
+func (apiV2) item(item todo.TodoItem) interface{} {
+	return newItemV2(item)
+}
+
+func (apiV2) listsPage(lists []todo.TodoList, limit int, next string) interface{} {
+	return newListsPageV2(lists, limit, next)
+}
+
+func (apiV2) itemsPage(items []todo.TodoItem, limit int, next string) interface{} {
+	return newItemsPageV2(items, limit, next)
+}
+
+// изменяющие запросы возвращают актуальную запись с её ETag,
+// создание отвечает 201 с заголовком Location, удаление - 204 без тела
+func (apiV2) created(c *gin.Context, id int, location string, version int, body interface{}) {
+	c.Header("Location", apiV2Path+location)
+	c.Header("ETag", etag(version))
+	c.JSON(http.StatusCreated, body)
+}
+
+func (apiV2) updated(c *gin.Context, version int, body interface{}) {
+	c.Header("ETag", etag(version))
+	c.JSON(http.StatusOK, body)
+}
+
+func (apiV2) deleted(c *gin.Context) {
+	c.Status(http.StatusNoContent)
+}
+
+// конфликт версий - 412 в формате problem+json с ETag текущей версии,
+// текущее состояние записи передается в поле current
+func (apiV2) conflict(c *gin.Context, version int, body interface{}) {
+	c.Header("ETag", etag(version))
+
+	problem := newProblem(c, http.StatusPreconditionFailed, codeVersionConflict, todo.ErrVersionConflict.Error(), nil)
+	problem.Current = body
+	writeProblem(c, problem)
+}
@@ -0,0 +1,170 @@
+package handler
+
+import "github.com/gin-gonic/gin"
+
+// обработчики задач второй версии api, устроены так же, как v2_list.go:
+// используются общие обработчики из item.go и filter.go
+
+// описываем данные для swagger
+// @Summary      Create Item (v2)
+// @Security ApiKeyAuth
+// @Description  create item, returns the created item
+// @Tags         v2 lists
+// ID create-item-v2
+// @Accept       json
+// @Produce      json
+// @Param        input body createItemInputV2 true "item data"
+// @Param        Idempotency-Key header string false "key for safe retries of the request"
+// @Success      201  {object}  itemV2
+// @Header       201  {string}  Location  "url of the created item"
+// @Header       201  {string}  ETag  "item version"
+// @Failure      400,409,422  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "editor role in the list is required"
+// @Failure      404  {object}  problemResponse  "list not found or not accessible"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/v2/lists/:id/items [post]
+func (h *Handler) createItemV2(c *gin.Context) {
+	h.createItemFor(c, apiV2{})
+}
+
+// описываем данные для swagger
+// @Summary      Get All Items (v2)
+// @Security ApiKeyAuth
+// @Description  get items page of the list, sorted and filtered
+// @Tags         v2 lists
+// ID get-all-items-v2
+// @Accept       json
+// @Produce      json
+// @Param        limit   query  int     false  "page size (1-100, default 50)"
+// @Param        cursor  query  string  false  "next_cursor from previous page"
+// @Param        sort    query  string  false  "sort field"  Enums(created, updated, title, due_date, position, created_at, updated_at, completed_at)
+// @Param        order   query  string  false  "sort order"  Enums(asc, desc)
+// @Param        title   query  string  false  "title substring filter"
+// @Param        done    query  bool    false  "done filter"
+// @Param        completed_after   query  string  false  "completed at or after (RFC 3339)"
+// @Param        completed_before  query  string  false  "completed before (RFC 3339)"
+// @Param        created_after   query  string  false  "created at or after (RFC 3339)"
+// @Param        created_before  query  string  false  "created before (RFC 3339)"
+// @Param        updated_after   query  string  false  "updated at or after (RFC 3339)"
+// @Param        updated_before  query  string  false  "updated before (RFC 3339)"
+// @Param        created_by      query  int     false  "id of the user who created the record"
+// @Param        updated_by      query  int     false  "id of the user who last updated the record"
+// @Success      200  {object}  itemsPageV2
+// @Header       200  {string}  Link  "next page link"
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/v2/lists/:id/items [get]
+func (h *Handler) getAllItemsV2(c *gin.Context) {
+	h.getAllItemsFor(c, apiV2{})
+}
+
+// описываем данные для swagger
+// @Summary      Get Item By Id (v2)
+// @Security ApiKeyAuth
+// @Description  get item by id, supports conditional request with If-None-Match
+// @Tags         v2 items
+// ID get-item-by-id-v2
+// @Accept       json
+// @Produce      json
+// @Param        If-None-Match  header  string  false  "ETag of cached item"
+// @Success      200  {object}  itemV2
+// @Header       200  {string}  ETag  "item version"
+// @Success      304  {string}  string  "not modified"
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/v2/items/:id [get]
+func (h *Handler) getItemByIdV2(c *gin.Context) {
+	h.getItemByIdFor(c, apiV2{})
+}
+
+// описываем данные для swagger
+// @Summary      Update Item (v2)
+// @Security ApiKeyAuth
+// @Description  update item and return it, with If-Match the update is applied only to the given version
+// @Tags         v2 items
+// ID update-item-v2
+// @Accept       json
+// @Produce      json
+// @Param        If-Match  header  string  false  "expected item ETag"
+// @Param        input body todo.UpdateItemInput true "item data"
+// @Success      200  {object}  itemV2
+// @Header       200  {string}  ETag  "new item version"
+// @Failure      400  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "editor role in the list is required"
+// @Failure      404  {object}  problemResponse  "item not found or not accessible"
+// @Failure      412  {object}  problemResponse  "version conflict, current item in the current member"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/v2/items/:id [put]
+func (h *Handler) updateItemV2(c *gin.Context) {
+	h.updateItemFor(c, apiV2{})
+}
+
+// описываем данные для swagger
+// @Summary      Patch Item (v2)
+// @Security ApiKeyAuth
+// @Description  partially update item with JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902), done is boolean, id, version, completed_at and audit fields are read-only
+// @Tags         v2 items
+// ID patch-item-v2
+// @Accept       application/merge-patch+json,application/json-patch+json
+// @Produce      json
+// @Param        If-Match  header  string  false  "expected item ETag"
+// @Param        input body object true "merge patch object or json patch operations"
+// @Success      200  {object}  itemV2
+// @Header       200  {string}  ETag  "new item version"
+// @Failure      400,409,415,422  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "editor role in the list is required"
+// @Failure      404  {object}  problemResponse  "item not found or not accessible"
+// @Failure      412  {object}  problemResponse  "version conflict, current item in the current member"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/v2/items/:id [patch]
+func (h *Handler) patchItemV2(c *gin.Context) {
+	h.patchItemFor(c, apiV2{})
+}
+
+// описываем данные для swagger
+// @Summary      Delete Item (v2)
+// @Security ApiKeyAuth
+// @Description  delete item, with If-Match the item is deleted only in the given version
+// @Tags         v2 items
+// ID delete-item-v2
+// @Accept       json
+// @Produce      json
+// @Param        If-Match  header  string  false  "expected item ETag"
+// @Success      204  "item deleted"
+// @Failure      400  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "editor role in the list is required"
+// @Failure      404  {object}  problemResponse  "item not found or not accessible"
+// @Failure      412  {object}  problemResponse  "version conflict, current item in the current member"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/v2/items/:id [delete]
+func (h *Handler) deleteItemV2(c *gin.Context) {
+	h.deleteItemFor(c, apiV2{})
+}
+
+// описываем данные для swagger
+// @Summary      Get Filter Items (v2)
+// @Security ApiKeyAuth
+// @Description  evaluate saved filter over items of all lists available to the user
+// @Tags         v2 filters
+// ID get-filter-items-v2
+// @Accept       json
+// @Produce      json
+// @Param        limit   query  int     false  "page size (1-100, default 50)"
+// @Param        cursor  query  string  false  "next_cursor from previous page"
+// @Param        sort    query  string  false  "sort field"  Enums(created, updated, title, due_date, position, created_at, updated_at, completed_at)
+// @Param        order   query  string  false  "sort order"  Enums(asc, desc)
+// @Success      200  {object}  itemsPageV2
+// @Header       200  {string}  Link  "next page link"
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/v2/filters/:id/items [get]
+func (h *Handler) getFilterItemsV2(c *gin.Context) {
+	h.getFilterItemsFor(c, apiV2{})
+}
@@ -0,0 +1,146 @@
+package handler
+
+import "github.com/gin-gonic/gin"
+
+// обработчики списков второй версии api: используются общие обработчики из list.go,
+// в ответ записываются DTO из v2_dto.go (apiV2).
+// В отличие от v1, изменяющие запросы возвращают актуальную запись,
+// создание отвечает 201 с заголовком Location, удаление - 204 без тела,
+// а конфликт версий - 412 в формате problem+json с текущей записью в поле current
+
+// описываем данные для swagger
+// @Summary      Create Todo List (v2)
+// @Security ApiKeyAuth
+// @Description  create todo list, returns the created list
+// @Tags         v2 lists
+// ID create-list-v2
+// @Accept       json
+// @Produce      json
+// @Param        input body createListInputV2 true "list data"
+// @Param        Idempotency-Key header string false "key for safe retries of the request"
+// @Success      201  {object}  listV2
+// @Header       201  {string}  Location  "url of the created list"
+// @Header       201  {string}  ETag  "list version"
+// @Failure      400,404,409,422  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/v2/lists [post]
+func (h *Handler) createListV2(c *gin.Context) {
+	h.createListFor(c, apiV2{})
+}
+
+// описываем данные для swagger
+// @Summary      Get All Lists (v2)
+// @Security ApiKeyAuth
+// @Description  get lists page, sorted and filtered
+// @Tags         v2 lists
+// ID get-all-lists-v2
+// @Accept       json
+// @Produce      json
+// @Param        limit   query  int     false  "page size (1-100, default 50)"
+// @Param        cursor  query  string  false  "next_cursor from previous page"
+// @Param        sort    query  string  false  "sort field"  Enums(created, updated, title, position, created_at, updated_at)
+// @Param        order   query  string  false  "sort order"  Enums(asc, desc)
+// @Param        title   query  string  false  "title substring filter"
+// @Param        created_after   query  string  false  "created at or after (RFC 3339)"
+// @Param        created_before  query  string  false  "created before (RFC 3339)"
+// @Param        updated_after   query  string  false  "updated at or after (RFC 3339)"
+// @Param        updated_before  query  string  false  "updated before (RFC 3339)"
+// @Param        created_by      query  int     false  "id of the user who created the record"
+// @Param        updated_by      query  int     false  "id of the user who last updated the record"
+// @Success      200  {object}  listsPageV2
+// @Header       200  {string}  Link  "next page link"
+// @Failure      400  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/v2/lists [get]
+func (h *Handler) getAllListsV2(c *gin.Context) {
+	h.getAllListsFor(c, apiV2{})
+}
+
+// описываем данные для swagger
+// @Summary      Get List By Id (v2)
+// @Security ApiKeyAuth
+// @Description  get list by id, supports conditional request with If-None-Match
+// @Tags         v2 lists
+// ID get-list-by-id-v2
+// @Accept       json
+// @Produce      json
+// @Param        If-None-Match  header  string  false  "ETag of cached list"
+// @Success      200  {object}  listV2
+// @Header       200  {string}  ETag  "list version"
+// @Success      304  {string}  string  "not modified"
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/v2/lists/:id [get]
+func (h *Handler) getListByIdV2(c *gin.Context) {
+	h.getListByIdFor(c, apiV2{})
+}
+
+// описываем данные для swagger
+// @Summary      Update List (v2)
+// @Security ApiKeyAuth
+// @Description  update list and return it, with If-Match the update is applied only to the given version
+// @Tags         v2 lists
+// ID update-list-v2
+// @Accept       json
+// @Produce      json
+// @Param        If-Match  header  string  false  "expected list ETag"
+// @Param        input body todo.UpdateListInput true "list data"
+// @Success      200  {object}  listV2
+// @Header       200  {string}  ETag  "new list version"
+// @Failure      400  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "editor role in the list is required"
+// @Failure      404  {object}  problemResponse  "list not found or not accessible"
+// @Failure      412  {object}  problemResponse  "version conflict, current list in the current member"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/v2/lists/:id [put]
+func (h *Handler) updateListV2(c *gin.Context) {
+	h.updateListFor(c, apiV2{})
+}
+
+// описываем данные для swagger
+// @Summary      Patch List (v2)
+// @Security ApiKeyAuth
+// @Description  partially update list with JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902), id, version and audit fields are read-only
+// @Tags         v2 lists
+// ID patch-list-v2
+// @Accept       application/merge-patch+json,application/json-patch+json
+// @Produce      json
+// @Param        If-Match  header  string  false  "expected list ETag"
+// @Param        input body object true "merge patch object or json patch operations"
+// @Success      200  {object}  listV2
+// @Header       200  {string}  ETag  "new list version"
+// @Failure      400,409,415,422  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "editor role in the list is required"
+// @Failure      404  {object}  problemResponse  "list not found or not accessible"
+// @Failure      412  {object}  problemResponse  "version conflict, current list in the current member"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/v2/lists/:id [patch]
+func (h *Handler) patchListV2(c *gin.Context) {
+	h.patchListFor(c, apiV2{})
+}
+
+// описываем данные для swagger
+// @Summary      Delete List (v2)
+// @Security ApiKeyAuth
+// @Description  delete list, with If-Match the list is deleted only in the given version
+// @Tags         v2 lists
+// ID delete-list-v2
+// @Accept       json
+// @Produce      json
+// @Param        If-Match  header  string  false  "expected list ETag"
+// @Success      204  "list deleted"
+// @Failure      400  {object}  problemResponse
+// @Failure      403  {object}  problemResponse  "owner role in the list is required"
+// @Failure      404  {object}  problemResponse  "list not found or not accessible"
+// @Failure      412  {object}  problemResponse  "version conflict, current list in the current member"
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/v2/lists/:id [delete]
+func (h *Handler) deleteListV2(c *gin.Context) {
+	h.deleteListFor(c, apiV2{})
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	todo "to-do-list"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Версии api:
+// v1 (/api) - исходная версия, сохраняется без изменений для существующих клиентов
+// и помечается устаревшей заголовками Deprecation (RFC 9745) и Sunset (RFC 8594);
+// v2 (/api/v2) - ответы строятся из отдельных DTO (v2_dto.go), а не из структур БД,
+// создание возвращает 201, удаление - 204, коллекции - конверт с параметрами пагинации.
+const (
+	apiV1Path = "/api"
+	apiV2Path = "/api/v2"
+)
+
+// параметры транспортного слоя, задаются в configs/config.yml:
+// V1DeprecatedAt - дата, с которой v1 считается устаревшей,
+// V1Sunset - дата, после которой v1 может быть отключена,
+// StreamTimeout - максимальная длительность потока событий (stream.go),
+// ExportTimeout - максимальная длительность выгрузки данных (export.go)
+type Config struct {
+	V1DeprecatedAt time.Time
+	V1Sunset       time.Time
+	StreamTimeout  time.Duration
+	ExportTimeout  time.Duration
+}
+
+// Обработчики списков и задач (list.go, item.go, filter.go) общие для всех версий api,
+// версия определяет только чтение тела запроса на создание и представление ответов:
+// apiV1 (v1_dto.go) сохраняет ответы v1 неизменными, apiV2 (v2_dto.go) строит ответы из DTO v2
+type apiVersion interface {
+	// чтение тела запроса на создание записи
+	bindList(c *gin.Context) (todo.TodoList, error)
+	bindItem(c *gin.Context) (todo.TodoItem, error)
+
+	// представление записей и страниц коллекций
+	list(list todo.TodoList) interface{}
+	item(item todo.TodoItem) interface{}
+	listsPage(lists []todo.TodoList, limit int, next string) interface{}
+	itemsPage(items []todo.TodoItem, limit int, next string) interface{}
+
+	// ответы на изменяющие запросы: location - путь созданной записи в версии,
+	// version - версия записи, body - запись в представлении версии
+	created(c *gin.Context, id int, location string, version int, body interface{})
+	updated(c *gin.Context, version int, body interface{})
+	deleted(c *gin.Context)
+	// ответ на конфликт версий с текущим состоянием записи
+	conflict(c *gin.Context, version int, body interface{})
+}
+
+// метод мидлвары для ответов v1: добавляет заголовки устаревания
+// и ссылку на следующую версию api. В ответах со страницами коллекций
+// заголовок Link заменяется ссылкой на следующую страницу, как и до появления v2
+func (h *Handler) deprecated(c *gin.Context) {
+	if h.cfg.V1DeprecatedAt.IsZero() {
+		return
+	}
+
+	c.Header("Deprecation", fmt.Sprintf("@%d", h.cfg.V1DeprecatedAt.Unix()))
+	if !h.cfg.V1Sunset.IsZero() {
+		c.Header("Sunset", h.cfg.V1Sunset.UTC().Format(http.TimeFormat))
+	}
+	c.Writer.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, apiV2Path))
+}
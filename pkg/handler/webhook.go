@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	todo "to-do-list"
+
+	"github.com/gin-gonic/gin"
+)
+
+// обработчики вебхуков построены по тому же принципу, что и обработчики фильтров в filter.go.
+// Вебхуки одинаковы в обеих версиях api
+
+// описываем данные для swagger
+// @Summary      Create Webhook
+// @Security ApiKeyAuth
+// @Description  create webhook, the response contains the signing secret which is not returned again.
+// @Description  The url must point to a public address, redirects of the receiver are not followed
+// @Tags         webhooks
+// ID create-webhook
+// @Accept       json
+// @Produce      json
+// @Param        input body todo.WebhookInput true "webhook data"
+// @Param        Idempotency-Key header string false "key for safe retries of the request"
+// @Success      201  {object}  todo.Webhook
+// @Failure      400,404,422  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/webhooks [post]
+func (h *Handler) createWebhook(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	var input todo.WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newBindErrorResponse(c, err)
+		return
+	}
+
+	webhook, err := h.servicesFrom(c).Webhook.Create(userId, input)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// дополнительная структура для ответа
+type getAllWebhooksResponse struct {
+	Data []todo.Webhook `json:"data"`
+}
+
+// описываем данные для swagger
+// @Summary      Get All Webhooks
+// @Security ApiKeyAuth
+// @Description  get all webhooks of the user
+// @Tags         webhooks
+// ID get-all-webhooks
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  getAllWebhooksResponse
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/webhooks [get]
+func (h *Handler) getAllWebhooks(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	webhooks, err := h.servicesFrom(c).Webhook.GetAll(userId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getAllWebhooksResponse{
+		Data: webhooks,
+	})
+}
+
+// описываем данные для swagger
+// @Summary      Get Webhook By Id
+// @Security ApiKeyAuth
+// @Description  get webhook by id
+// @Tags         webhooks
+// ID get-webhook-by-id
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  todo.Webhook
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/webhooks/:id [get]
+func (h *Handler) getWebhookById(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	webhookId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	webhook, err := h.servicesFrom(c).Webhook.GetById(userId, webhookId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// описываем данные для swagger
+// @Summary      Update Webhook
+// @Security ApiKeyAuth
+// @Description  update webhook, active=true re-enables a disabled webhook
+// @Tags         webhooks
+// ID update-webhook
+// @Accept       json
+// @Produce      json
+// @Param        input body todo.UpdateWebhookInput true "webhook data"
+// @Success      200  {string}  string
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/webhooks/:id [put]
+func (h *Handler) updateWebhook(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	webhookId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.UpdateWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newBindErrorResponse(c, err)
+		return
+	}
+
+	if err := h.servicesFrom(c).Webhook.Update(userId, webhookId, input); err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{
+		Status: "ok",
+	})
+}
+
+// описываем данные для swagger
+// @Summary      Delete Webhook
+// @Security ApiKeyAuth
+// @Description  delete webhook with its delivery log
+// @Tags         webhooks
+// ID delete-webhook
+// @Accept       json
+// @Produce      json
+// @Success      200  {string}  string
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/webhooks/:id [delete]
+func (h *Handler) deleteWebhook(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	webhookId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.servicesFrom(c).Webhook.Delete(userId, webhookId); err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{
+		Status: "ok",
+	})
+}
+
+// дополнительная структура для ответа
+type getDeliveriesResponse struct {
+	Data       []todo.WebhookDelivery `json:"data"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+// описываем данные для swagger
+// @Summary      Get Webhook Deliveries
+// @Security ApiKeyAuth
+// @Description  get delivery log of the webhook, newest first
+// @Tags         webhooks
+// ID get-webhook-deliveries
+// @Accept       json
+// @Produce      json
+// @Param        limit   query  int     false  "page size (1-100, default 50)"
+// @Param        cursor  query  string  false  "next_cursor from previous page"
+// @Param        status  query  string  false  "delivery status"  Enums(pending, succeeded, failed)
+// @Success      200  {object}  getDeliveriesResponse
+// @Header       200  {string}  Link  "next page link"
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/webhooks/:id/deliveries [get]
+func (h *Handler) getWebhookDeliveries(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	webhookId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var query todo.DeliveryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		newBindErrorResponse(c, err)
+		return
+	}
+
+	deliveries, next, err := h.servicesFrom(c).Webhook.GetDeliveries(userId, webhookId, query)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	setNextPageLink(c, next)
+	c.JSON(http.StatusOK, getDeliveriesResponse{
+		Data:       deliveries,
+		NextCursor: next,
+	})
+}
+
+// описываем данные для swagger
+// @Summary      Redeliver Webhook Delivery
+// @Security ApiKeyAuth
+// @Description  queue a new delivery with the payload of the given one
+// @Tags         webhooks
+// ID redeliver-webhook-delivery
+// @Accept       json
+// @Produce      json
+// @Param        Idempotency-Key header string false "key for safe retries of the request"
+// @Success      202  {object}  todo.WebhookDelivery
+// @Failure      400,404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Failure      default  {object}  problemResponse
+// @Router       /api/webhooks/:id/deliveries/:deliveryId/redeliver [post]
+func (h *Handler) redeliverWebhook(c *gin.Context) {
+	userId, err := GetUserId(c)
+	if err != nil {
+		return
+	}
+
+	webhookId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	deliveryId, err := strconv.ParseInt(c.Param("deliveryId"), 10, 64)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid delivery id param")
+		return
+	}
+
+	delivery, err := h.servicesFrom(c).Webhook.Redeliver(userId, webhookId, deliveryId)
+	if err != nil {
+		newDomainErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
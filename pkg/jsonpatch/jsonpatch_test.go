@@ -0,0 +1,181 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// сравнение результатов без учета порядка ключей и форматирования
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	gotValue, err := decode(got)
+	if err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	wantValue, err := decode([]byte(want))
+	if err != nil {
+		t.Fatalf("decode expected: %v", err)
+	}
+	if !equal(gotValue, wantValue) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"replace value", `{"title":"a","done":false}`, `{"title":"b"}`, `{"title":"b","done":false}`},
+		{"null removes field", `{"title":"a","due_date":"2021-01-01T00:00:00Z"}`, `{"due_date":null}`, `{"title":"a"}`},
+		{"nested objects are merged", `{"a":{"b":1,"c":2}}`, `{"a":{"c":null,"d":3}}`, `{"a":{"b":1,"d":3}}`},
+		{"arrays are replaced", `{"labels":["a","b"]}`, `{"labels":["c"]}`, `{"labels":["c"]}`},
+		{"non-object patch replaces document", `{"a":1}`, `[1,2]`, `[1,2]`},
+		{"large numbers keep precision", `{"id":1}`, `{"id":9007199254740993}`, `{"id":9007199254740993}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestMergePatchInvalid(t *testing.T) {
+	if _, err := MergePatch([]byte(`{}`), []byte(`{`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("got %v, want ErrInvalidPatch", err)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"add field", `{"a":1}`, `[{"op":"add","path":"/b","value":2}]`, `{"a":1,"b":2}`},
+		{"add to array end", `{"a":[1]}`, `[{"op":"add","path":"/a/-","value":2}]`, `{"a":[1,2]}`},
+		{"insert into array", `{"a":[1,3]}`, `[{"op":"add","path":"/a/1","value":2}]`, `{"a":[1,2,3]}`},
+		{"remove field", `{"a":1,"b":2}`, `[{"op":"remove","path":"/b"}]`, `{"a":1}`},
+		{"remove array element", `{"a":[1,2,3]}`, `[{"op":"remove","path":"/a/1"}]`, `{"a":[1,3]}`},
+		{"replace field", `{"a":1}`, `[{"op":"replace","path":"/a","value":"x"}]`, `{"a":"x"}`},
+		{"move field", `{"a":1}`, `[{"op":"move","from":"/a","path":"/b"}]`, `{"b":1}`},
+		{"copy field", `{"a":{"x":1}}`, `[{"op":"copy","from":"/a","path":"/b"}]`, `{"a":{"x":1},"b":{"x":1}}`},
+		{"escaped pointer", `{"a/b":1,"c~d":2}`, `[{"op":"remove","path":"/a~1b"},{"op":"remove","path":"/c~0d"}]`, `{}`},
+		{"test passes", `{"a":"x"}`, `[{"op":"test","path":"/a","value":"x"},{"op":"add","path":"/b","value":1}]`, `{"a":"x","b":1}`},
+		{"test compares numbers by value", `{"a":1}`, `[{"op":"test","path":"/a","value":1.0},{"op":"test","path":"/a","value":1e0}]`, `{"a":1}`},
+		{"test compares nested numbers by value", `{"a":{"b":[10]}}`, `[{"op":"test","path":"/a","value":{"b":[1e1]}}]`, `{"a":{"b":[10]}}`},
+		{"test ignores key order", `{"a":{"x":1,"y":2}}`, `[{"op":"test","path":"/a","value":{"y":2,"x":1}}]`, `{"a":{"x":1,"y":2}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyPatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  error
+	}{
+		{"test fails", `{"a":1}`, `[{"op":"test","path":"/a","value":2}]`, ErrTestFailed},
+		{"test number is not string", `{"a":1}`, `[{"op":"test","path":"/a","value":"1"}]`, ErrTestFailed},
+		{"test array length differs", `{"a":[1]}`, `[{"op":"test","path":"/a","value":[1,1]}]`, ErrTestFailed},
+		{"path is required", `{}`, `[{"op":"remove"}]`, ErrInvalidPatch},
+		{"value is required", `{}`, `[{"op":"add","path":"/a"}]`, ErrInvalidPatch},
+		{"unsupported op", `{}`, `[{"op":"merge","path":"/a"}]`, ErrInvalidPatch},
+		{"path without slash", `{"a":1}`, `[{"op":"remove","path":"a"}]`, ErrInvalidPatch},
+		{"missing path", `{}`, `[{"op":"remove","path":"/a"}]`, ErrInvalidPatch},
+		{"replace missing path", `{}`, `[{"op":"replace","path":"/a","value":1}]`, ErrInvalidPatch},
+		{"array index out of range", `{"a":[1]}`, `[{"op":"add","path":"/a/2","value":1}]`, ErrInvalidPatch},
+		{"array index with leading zero", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/01"}]`, ErrInvalidPatch},
+		{"move into itself", `{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/c"}]`, ErrInvalidPatch},
+		{"remove root", `{}`, `[{"op":"remove","path":""}]`, ErrInvalidPatch},
+		{"patch is not array", `{}`, `{"op":"add"}`, ErrInvalidPatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ApplyPatch([]byte(tt.doc), []byte(tt.patch)); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// при ошибке в любой операции исходный документ не изменяется
+func TestApplyPatchAtomic(t *testing.T) {
+	doc := []byte(`{"a":1}`)
+	if _, err := ApplyPatch(doc, []byte(`[{"op":"add","path":"/b","value":2},{"op":"test","path":"/a","value":3}]`)); err == nil {
+		t.Fatal("expected error")
+	}
+	if string(doc) != `{"a":1}` {
+		t.Errorf("document changed: %s", doc)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{`1`, `1.0`, true},
+		{`1`, `1e0`, true},
+		{`0.1`, `1e-1`, true},
+		{`-0`, `0`, true},
+		{`1`, `1.0000000000000000001`, false},
+		{`9007199254740993`, `9007199254740992`, false},
+		{`null`, `null`, true},
+		{`null`, `false`, false},
+		{`"a"`, `"a"`, true},
+		{`[1,2]`, `[2,1]`, false},
+		{`{"a":1}`, `{"a":1,"b":null}`, false},
+	}
+
+	for _, tt := range tests {
+		var a, b interface{}
+		var err error
+		if a, err = decode([]byte(tt.a)); err != nil {
+			t.Fatal(err)
+		}
+		if b, err = decode([]byte(tt.b)); err != nil {
+			t.Fatal(err)
+		}
+		if got := equal(a, b); got != tt.want {
+			t.Errorf("equal(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// числа документа не теряют точность при применении патча
+func TestApplyPatchKeepsNumbers(t *testing.T) {
+	got, err := ApplyPatch([]byte(`{"a":9007199254740993}`), []byte(`[{"op":"add","path":"/b","value":true}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var value map[string]json.RawMessage
+	if err := json.Unmarshal(got, &value); err != nil {
+		t.Fatal(err)
+	}
+	if string(value["a"]) != "9007199254740993" {
+		t.Errorf("got %s", value["a"])
+	}
+}
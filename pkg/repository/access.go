@@ -0,0 +1,63 @@
+// в данном файле реализуется проверка доступа пользователя к спискам и задачам по ролям
+
+package repository
+
+import (
+	"fmt"
+	todo "to-do-list"
+)
+
+// функция возвращает роль пользователя в списке.
+// Если пользователь не участник списка или списка нет - ErrNotFound:
+// существование чужих списков не раскрывается
+func listRole(db DB, userId, listId int) (string, error) {
+	var role string
+
+	query := fmt.Sprintf("SELECT role FROM %s WHERE user_id = $1 AND list_id = $2 LIMIT 1", usersListsTable)
+	if err := db.Get(&role, query, userId, listId); err != nil {
+		return "", dbError(err)
+	}
+
+	return role, nil
+}
+
+// функция возвращает роль пользователя в списке, которому принадлежит задача
+func itemRole(db DB, userId, itemId int) (string, error) {
+	var role string
+
+	query := fmt.Sprintf("SELECT ul.role FROM %s ti INNER JOIN %s ul on ul.list_id = ti.list_id WHERE ti.id = $1 AND ul.user_id = $2",
+		todoItemsTable, usersListsTable)
+	if err := db.Get(&role, query, itemId, userId); err != nil {
+		return "", dbError(err)
+	}
+
+	return role, nil
+}
+
+// функция проверяет, что роли достаточно для действия:
+// ErrForbidden - пользователь участник списка, но его роль слабее требуемой
+func requireRole(role, required string, err error) error {
+	if err != nil {
+		return err
+	}
+	if !todo.RoleAllows(role, required) {
+		return fmt.Errorf("%w: %s role is required", todo.ErrForbidden, required)
+	}
+
+	return nil
+}
+
+// функция определяет, почему изменение записи не затронуло ни одной строки:
+// нет доступа (ErrNotFound или ErrForbidden из roleErr),
+// иначе при заданной ожидаемой версии - запись успели изменить (ErrVersionConflict),
+// без версии - запись успели удалить (ErrNotFound)
+func notAffectedError(roleErr error, version *int) error {
+	if roleErr != nil {
+		return roleErr
+	}
+	if version != nil {
+		return todo.ErrVersionConflict
+	}
+
+	return todo.ErrNotFound
+}
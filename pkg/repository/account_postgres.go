@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+	todo "to-do-list"
+
+	"github.com/lib/pq"
+)
+
+// создаем структуру репозитория
+type AccountPostgres struct {
+	db DB
+}
+
+// создаем конструктор репозитория для удаления аккаунтов
+func NewAccountPostgres(db DB) *AccountPostgres {
+	return &AccountPostgres{db: db}
+}
+
+func (r *AccountPostgres) GetDeletion(userId int) (todo.AccountDeletion, error) {
+	var deletion todo.AccountDeletion
+
+	query := fmt.Sprintf("SELECT deletion_requested_at, deletion_scheduled_at FROM %s WHERE id = $1", usersTable)
+	err := r.db.Get(&deletion, query, userId)
+
+	return deletion, dbError(err)
+}
+
+// метод назначает удаление аккаунта, если пароль верный (иначе ErrNotFound).
+// Повторный запрос не переносит уже назначенное удаление
+func (r *AccountPostgres) ScheduleDeletion(userId int, passwordHash string, gracePeriod time.Duration) (todo.AccountDeletion, error) {
+	var deletion todo.AccountDeletion
+
+	query := fmt.Sprintf(`UPDATE %s SET deletion_requested_at = COALESCE(deletion_requested_at, now()),
+			deletion_scheduled_at = COALESCE(deletion_scheduled_at, now() + make_interval(secs => $3))
+		WHERE id = $1 AND password_hash = $2
+		RETURNING deletion_requested_at, deletion_scheduled_at`, usersTable)
+	err := r.db.Get(&deletion, query, userId, passwordHash, gracePeriod.Seconds())
+
+	return deletion, dbError(err)
+}
+
+// отмена удаления, ErrNotFound - удаление не назначено
+func (r *AccountPostgres) CancelDeletion(userId int) error {
+	query := fmt.Sprintf(`UPDATE %s SET deletion_requested_at = NULL, deletion_scheduled_at = NULL
+		WHERE id = $1 AND deletion_scheduled_at IS NOT NULL`, usersTable)
+
+	result, err := r.db.Exec(query, userId)
+	if err != nil {
+		return dbError(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return dbError(err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: account deletion is not scheduled", todo.ErrNotFound)
+	}
+
+	return nil
+}
+
+// метод блокирует до конца транзакции аккаунт, время удаления которого наступило,
+// аккаунты, которые уже удаляют другие экземпляры приложения, пропускаются.
+// ErrNotFound - таких аккаунтов нет
+func (r *AccountPostgres) LockDueDeletion() (int, error) {
+	var userId int
+
+	query := fmt.Sprintf(`SELECT id FROM %s WHERE deletion_scheduled_at <= now()
+		ORDER BY deletion_scheduled_at LIMIT 1 FOR UPDATE SKIP LOCKED`, usersTable)
+	err := r.db.Get(&userId, query)
+
+	return userId, dbError(err)
+}
+
+// метод передает владение общими списками пользователя другим участникам:
+// владельцем становится редактор, а при их отсутствии - читатель, добавленный раньше других.
+// Участники, аккаунты которых тоже удаляются, выбираются в последнюю очередь.
+// Списки, у которых есть другой владелец, не передаются: владение в них уже обеспечено.
+// Возвращает id переданных списков
+func (r *AccountPostgres) TransferLists(userId int) ([]int, error) {
+	lists := make([]int, 0)
+
+	query := fmt.Sprintf(`UPDATE %[1]s ul SET role = '%[3]s'
+		FROM (
+			SELECT DISTINCT ON (m.list_id) m.id
+			FROM %[1]s o
+				INNER JOIN %[1]s m ON m.list_id = o.list_id AND m.user_id <> o.user_id
+				INNER JOIN %[2]s u ON u.id = m.user_id
+			WHERE o.user_id = $1 AND o.role = '%[3]s'
+				AND NOT EXISTS (SELECT 1 FROM %[1]s x WHERE x.list_id = o.list_id AND x.user_id <> o.user_id AND x.role = '%[3]s')
+			ORDER BY m.list_id, u.deletion_scheduled_at IS NOT NULL, m.role = '%[4]s' DESC, m.id
+		) successor
+		WHERE ul.id = successor.id
+		RETURNING ul.list_id`, usersListsTable, usersTable, todo.RoleOwner, todo.RoleEditor)
+	err := r.db.Select(&lists, query, userId)
+
+	return lists, dbError(err)
+}
+
+// метод удаляет списки, в которых пользователь - единственный владелец и других участников нет,
+// вместе с их задачами. Возвращает число удаленных списков и задач
+func (r *AccountPostgres) DeleteSoleLists(userId int) (int, int, error) {
+	lists := make([]int64, 0)
+
+	query := fmt.Sprintf(`SELECT o.list_id FROM %[1]s o WHERE o.user_id = $1 AND o.role = '%[2]s'
+		AND NOT EXISTS (SELECT 1 FROM %[1]s m WHERE m.list_id = o.list_id AND m.user_id <> o.user_id)`,
+		usersListsTable, todo.RoleOwner)
+	if err := r.db.Select(&lists, query, userId); err != nil {
+		return 0, 0, dbError(err)
+	}
+	if len(lists) == 0 {
+		return 0, 0, nil
+	}
+
+	// задачи удалились бы и каскадно вместе со списками, но удаляются явно до списков,
+	// чтобы посчитать их для журнала аудита
+	query = fmt.Sprintf("DELETE FROM %s WHERE list_id = ANY($1)", todoItemsTable)
+	result, err := r.db.Exec(query, pq.Array(lists))
+	if err != nil {
+		return 0, 0, dbError(err)
+	}
+	items, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, dbError(err)
+	}
+
+	query = fmt.Sprintf("DELETE FROM %s WHERE id = ANY($1)", todoListsTable)
+	if _, err := r.db.Exec(query, pq.Array(lists)); err != nil {
+		return 0, 0, dbError(err)
+	}
+
+	return len(lists), int(items), nil
+}
+
+// id списков, в которых пользователь участвует
+func (r *AccountPostgres) GetMemberLists(userId int) ([]int, error) {
+	lists := make([]int, 0)
+
+	query := fmt.Sprintf("SELECT list_id FROM %s WHERE user_id = $1 ORDER BY list_id", usersListsTable)
+	err := r.db.Select(&lists, query, userId)
+
+	return lists, dbError(err)
+}
+
+// удаление пользователя. Его участие в списках, фильтры, вебхуки и остальные
+// записи пользователя удаляются каскадно, авторство списков и задач обнуляется
+func (r *AccountPostgres) DeleteUser(userId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", usersTable)
+
+	_, err := r.db.Exec(query, userId)
+
+	return dbError(err)
+}
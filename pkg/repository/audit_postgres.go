@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// создаем структуру репозитория
+type AuditLogPostgres struct {
+	db DB
+}
+
+// создаем конструктор репозитория журнала аудита
+func NewAuditLogPostgres(db DB) *AuditLogPostgres {
+	return &AuditLogPostgres{db: db}
+}
+
+// запись действия в журнал, details сохраняются в json
+func (r *AuditLogPostgres) Record(userId int, action string, details interface{}) error {
+	data, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (action, user_id, details) VALUES ($1, $2, $3)", auditLogTable)
+	_, err = r.db.Exec(query, action, userId, string(data))
+
+	return dbError(err)
+}
@@ -5,17 +5,15 @@ import (
 	todo "to-do-list"
 
 	_ "github.com/lib/pq"
-
-	"github.com/jmoiron/sqlx"
 )
 
 // описываем структуру репозитория для работы с авторизацией
 type AuthPostgres struct {
-	db *sqlx.DB
+	db DB
 }
 
 // создаем конструктор репозитория авторизации
-func NewAuthPostgres(db *sqlx.DB) *AuthPostgres {
+func NewAuthPostgres(db DB) *AuthPostgres {
 	return &AuthPostgres{db: db}
 }
 
@@ -25,13 +23,17 @@ func (r *AuthPostgres) CreateUser(user todo.User) (int, error) {
 
 	// осуществим запрос к базе данных, используя функцию для форматирования строк из fmt
 	// используем метод INSERT для добавления в usersTable, возвращаем id
-	// в плейсхолдеры $1, $2, $3 подставятся значения аргументов метода QueryRow, начиная со 2-го
-	query := fmt.Sprintf("INSERT INTO %s (name, username, password_hash) values ($1, $2, $3) RETURNING id", usersTable)
+	// в плейсхолдеры $1, $2, $3 подставятся значения аргументов метода QueryRow, начиная со 2-го.
+	// Пользователь регистрируется сам, поэтому id берется из последовательности заранее
+	// и записывается также в created_by и updated_by
+	query := fmt.Sprintf(`INSERT INTO %[1]s (id, name, username, password_hash, created_by, updated_by)
+		SELECT seq.id, $1, $2, $3, seq.id, seq.id FROM (SELECT nextval(pg_get_serial_sequence('%[1]s', 'id'))::int AS id) seq
+		RETURNING id`, usersTable)
 	row := r.db.QueryRow(query, user.Name, user.Username, user.Password)
 
 	// с помощью метода Scan записываем значение id в переменную
 	if err := row.Scan(&id); err != nil {
-		return 0, err
+		return 0, dbError(err)
 	}
 	return id, nil
 }
@@ -47,5 +49,5 @@ func (r *AuthPostgres) GetUser(username, password string) (todo.User, error) {
 	// делаем запрос к БД, записываем в user
 	err := r.db.Get(&user, query, username, password)
 
-	return user, err
+	return user, dbError(err)
 }
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"fmt"
+	todo "to-do-list"
+)
+
+// колонки UID и имени ресурса задачи календаря, для задач, созданных не через CalDAV,
+// строятся из id задачи так же, как todo.DefaultCalendarUid и todo.DefaultCalendarName
+const calendarItemColumns = "COALESCE(ti.ical_uid, 'todo-item-' || ti.id) AS uid, " +
+	"COALESCE(ti.ical_name, 'todo-item-' || ti.id || '.ics') AS name"
+
+// метка изменения календаря: наибольший id транзакции изменения списка, участия в нем,
+// его задач, а также удаления и переноса задач (колонки sync_txid, schema/000012_sync.up.sql)
+var calendarCTag = fmt.Sprintf(`GREATEST(tl.sync_txid, ul.sync_txid,
+	(SELECT max(ti.sync_txid) FROM %s ti WHERE ti.list_id = tl.id),
+	(SELECT max(st.sync_txid) FROM %s st WHERE st.list_id = tl.id AND st.entity = 'item')) AS ctag`,
+	todoItemsTable, syncTombstonesTable)
+
+// создаем структуру репозитория
+type CalendarPostgres struct {
+	db DB
+}
+
+// создаем конструктор репозитория для календарей CalDAV и лент iCalendar
+func NewCalendarPostgres(db DB) *CalendarPostgres {
+	return &CalendarPostgres{db: db}
+}
+
+// сохранение ссылки на ленту списка, предыдущая ссылка пользователя на список заменяется
+func (r *CalendarPostgres) SaveFeed(userId, listId int, tokenHash string) error {
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, list_id, token_hash) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, list_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = now()`, calendarFeedsTable)
+
+	_, err := r.db.Exec(query, userId, listId, tokenHash)
+
+	return dbError(err)
+}
+
+func (r *CalendarPostgres) DeleteFeed(userId, listId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1 AND list_id = $2", calendarFeedsTable)
+
+	res, err := r.db.Exec(query, userId, listId)
+	if err != nil {
+		return dbError(err)
+	}
+
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		return todo.ErrNotFound
+	}
+
+	return nil
+}
+
+// метод возвращает владельца ссылки и её список по хэшу токена
+func (r *CalendarPostgres) GetFeed(tokenHash string) (int, int, error) {
+	var feed struct {
+		UserId int `db:"user_id"`
+		ListId int `db:"list_id"`
+	}
+
+	query := fmt.Sprintf("SELECT user_id, list_id FROM %s WHERE token_hash = $1", calendarFeedsTable)
+	if err := r.db.Get(&feed, query, tokenHash); err != nil {
+		return 0, 0, dbError(err)
+	}
+
+	return feed.UserId, feed.ListId, nil
+}
+
+func (r *CalendarPostgres) GetCalendars(userId int) ([]todo.Calendar, error) {
+	calendars := make([]todo.Calendar, 0)
+
+	query := fmt.Sprintf("SELECT %s, ul.role, %s FROM %s tl INNER JOIN %s ul on ul.list_id = tl.id WHERE ul.user_id = $1 ORDER BY tl.position, tl.id",
+		listColumns, calendarCTag, todoListsTable, usersListsTable)
+	err := r.db.Select(&calendars, query, userId)
+
+	return calendars, dbError(err)
+}
+
+func (r *CalendarPostgres) GetCalendar(userId, listId int) (todo.Calendar, error) {
+	var calendar todo.Calendar
+
+	query := fmt.Sprintf("SELECT %s, ul.role, %s FROM %s tl INNER JOIN %s ul on ul.list_id = tl.id WHERE ul.user_id = $1 AND tl.id = $2",
+		listColumns, calendarCTag, todoListsTable, usersListsTable)
+	err := r.db.Get(&calendar, query, userId, listId)
+
+	return calendar, dbError(err)
+}
+
+// все задачи календаря, календарь не разбивается на страницы
+func (r *CalendarPostgres) GetItems(userId, listId int) ([]todo.CalendarItem, error) {
+	items := make([]todo.CalendarItem, 0)
+
+	query := fmt.Sprintf(`SELECT %s, %s FROM %s ti INNER JOIN %s ul on ul.list_id = ti.list_id
+		WHERE ti.list_id = $1 AND ul.user_id = $2 ORDER BY ti.position, ti.id`,
+		itemColumns, calendarItemColumns, todoItemsTable, usersListsTable)
+	err := r.db.Select(&items, query, listId, userId)
+
+	return items, dbError(err)
+}
+
+// метод возвращает задачу календаря по имени ресурса
+func (r *CalendarPostgres) GetItem(userId, listId int, name string) (todo.CalendarItem, error) {
+	var item todo.CalendarItem
+
+	query := fmt.Sprintf(`SELECT %s, %s FROM %s ti INNER JOIN %s ul on ul.list_id = ti.list_id
+		WHERE ti.list_id = $1 AND ul.user_id = $2 AND COALESCE(ti.ical_name, 'todo-item-' || ti.id || '.ics') = $3`,
+		itemColumns, calendarItemColumns, todoItemsTable, usersListsTable)
+	err := r.db.Get(&item, query, listId, userId, name)
+
+	return item, dbError(err)
+}
+
+// сохранение UID и имени ресурса, заданных клиентом CalDAV.
+// Значения по умолчанию не сохраняются, имя уникально в списке
+// (schema/000020_ical_name_unique.up.sql): занятое имя - ErrConflict
+func (r *CalendarPostgres) SetItemRef(itemId int, uid, name string) error {
+	query := fmt.Sprintf(`UPDATE %s SET ical_uid = NULLIF($1, 'todo-item-' || id), ical_name = NULLIF($2, 'todo-item-' || id || '.ics')
+		WHERE id = $3`, todoItemsTable)
+
+	_, err := r.db.Exec(query, uid, name, itemId)
+
+	return dbError(err)
+}
@@ -0,0 +1,46 @@
+// в данном файле ошибки драйвера БД приводятся к ошибкам предметной области из errors.go,
+// чтобы хендлеры не зависели от БД и не передавали клиенту текст ошибок postgres
+
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	todo "to-do-list"
+
+	"github.com/lib/pq"
+)
+
+// коды ошибок postgres, которые вызваны данными запроса
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+	pqStringTooLong       = "22001"
+)
+
+// функция возвращает ошибку предметной области для известных ошибок БД,
+// остальные ошибки возвращаются без изменений
+func dbError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return todo.ErrNotFound
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case pqUniqueViolation:
+			return fmt.Errorf("%w: record already exists", todo.ErrConflict)
+		case pqForeignKeyViolation:
+			return fmt.Errorf("%w: referenced record does not exist", todo.ErrNotFound)
+		case pqStringTooLong:
+			return fmt.Errorf("%w: value is too long", todo.ErrValidation)
+		}
+	}
+
+	return err
+}
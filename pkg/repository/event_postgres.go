@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+	todo "to-do-list"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// канал LISTEN/NOTIFY, в который публикуются id новых событий.
+// Уведомление доставляется только после фиксации транзакции с событием,
+// при откате транзакции оно отбрасывается
+const eventsChannel = "todo_events"
+
+// колонки события для выборок
+const eventColumns = "id, type, list_id, item_id, from_list_id, user_id, created_at"
+
+// создаем структуру репозитория
+type EventPostgres struct {
+	db DB
+}
+
+// создаем конструктор репозитория для работы с событиями
+func NewEventPostgres(db DB) *EventPostgres {
+	return &EventPostgres{db: db}
+}
+
+// метод сохраняет событие, ставит в очередь его доставку вебхукам получателей,
+// добавляет сообщение в outbox для внешних получателей
+// и уведомляет о событии все экземпляры приложения.
+// Получатели - участники списка события (и исходного списка для перенесенных задач).
+// Тело доставки и сообщения совпадает с json события (todo.Event)
+func (r *EventPostgres) Publish(event todo.Event) error {
+	query := fmt.Sprintf(`WITH e AS (
+			INSERT INTO %[1]s (type, list_id, item_id, from_list_id, user_id, recipients)
+			VALUES ($1, $2, $3, $4, $5, ARRAY(SELECT DISTINCT user_id FROM %[2]s WHERE list_id = $2 OR list_id = $4))
+			RETURNING *
+		), p AS (
+			SELECT e.*, jsonb_strip_nulls(jsonb_build_object('id', e.id, 'type', e.type, 'list_id', e.list_id,
+				'item_id', e.item_id, 'from_list_id', e.from_list_id, 'user_id', e.user_id, 'created_at', e.created_at)) AS payload
+			FROM e
+		), d AS (
+			INSERT INTO %[3]s (webhook_id, event_id, event_type, payload)
+			SELECT w.id, p.id, p.type, p.payload
+			FROM p INNER JOIN %[4]s w ON w.user_id = ANY(p.recipients)
+			WHERE w.active AND (w.list_id IS NULL OR w.list_id = p.list_id) AND (w.events = '{}' OR p.type = ANY(w.events))
+		), o AS (
+			INSERT INTO %[5]s (event_id, aggregate_type, aggregate_id, type, payload)
+			SELECT p.id, CASE WHEN p.item_id IS NULL THEN '%[6]s' ELSE '%[7]s' END, COALESCE(p.item_id, p.list_id), p.type, p.payload
+			FROM p
+		)
+		SELECT pg_notify('%[8]s', id::text) FROM e`, eventsTable, usersListsTable, webhookDeliveriesTable, webhooksTable,
+		outboxTable, todo.AggregateList, todo.AggregateItem, eventsChannel)
+
+	_, err := r.db.Exec(query, event.Type, event.ListId, event.ItemId, event.FromListId, event.UserId)
+	return dbError(err)
+}
+
+// состояние задачи для публикации событий: список задачи и статус выполнения
+type ItemState struct {
+	ListId int  `db:"list_id"`
+	Done   bool `db:"done"`
+}
+
+// метод возвращает состояния задач по их id.
+// Вызывается до изменения: удаление и перенос меняют список задачи,
+// а по прежнему статусу определяется перевод задачи в выполненные
+func (r *EventPostgres) ItemStates(itemIds []int) (map[int]ItemState, error) {
+	var rows []struct {
+		ItemId int `db:"item_id"`
+		ItemState
+	}
+
+	query := fmt.Sprintf("SELECT id AS item_id, list_id, done FROM %s WHERE id = ANY($1)", todoItemsTable)
+	if err := r.db.Select(&rows, query, pq.Array(itemIds)); err != nil {
+		return nil, dbError(err)
+	}
+
+	states := make(map[int]ItemState, len(rows))
+	for _, row := range rows {
+		states[row.ItemId] = row.ItemState
+	}
+
+	return states, nil
+}
+
+// строка выборки события вместе с получателями
+type eventRow struct {
+	todo.Event
+	Recipients pq.Int64Array `db:"recipients"`
+}
+
+// метод возвращает текущий снимок транзакций - начальный курсор рассылки событий
+func (r *EventPostgres) Snapshot() (string, error) {
+	var snapshot string
+	err := r.db.Get(&snapshot, "SELECT txid_current_snapshot()::text")
+
+	return snapshot, dbError(err)
+}
+
+// метод возвращает события с получателями, транзакции которых не видны в снимке snapshot,
+// по возрастанию id, и текущий снимок - курсор для следующей выборки.
+// События выбираются по видимости транзакций, а не по id: id выделяются до фиксации,
+// и событие с меньшим id может зафиксироваться позже уже разосланного события с большим id
+func (r *EventPostgres) GetSince(snapshot string) ([]todo.Event, string, error) {
+	tx, err := beginSnapshot(r.db)
+	if err != nil {
+		return nil, "", dbError(err)
+	}
+	defer tx.Rollback()
+
+	var current string
+	if err := tx.Get(&current, "SELECT txid_current_snapshot()::text"); err != nil {
+		return nil, "", dbError(err)
+	}
+
+	var rows []eventRow
+	query := fmt.Sprintf(`SELECT %s, recipients FROM %s
+		WHERE txid >= txid_snapshot_xmin($1::txid_snapshot) AND NOT txid_visible_in_snapshot(txid, $1::txid_snapshot)
+		ORDER BY id`, eventColumns, eventsTable)
+	if err := tx.Select(&rows, query, snapshot); err != nil {
+		return nil, "", dbError(err)
+	}
+
+	events := make([]todo.Event, 0, len(rows))
+	for _, row := range rows {
+		event := row.Event
+		event.Recipients = make([]int, 0, len(row.Recipients))
+		for _, userId := range row.Recipients {
+			event.Recipients = append(event.Recipients, int(userId))
+		}
+		events = append(events, event)
+	}
+
+	return events, current, nil
+}
+
+// события пользователя для повтора по возрастанию id после afterId, не более limit,
+// и текущий снимок транзакций. snapshot - курсор клиента: повторяются события,
+// транзакции которых не видны в нем; пустой snapshot - повтор по id
+// (Last-Event-ID в прежнем формате, id события)
+func (r *EventPostgres) GetAfter(userId int, snapshot string, afterId int64, limit int) ([]todo.Event, string, error) {
+	events := make([]todo.Event, 0)
+
+	tx, err := beginSnapshot(r.db)
+	if err != nil {
+		return nil, "", dbError(err)
+	}
+	defer tx.Rollback()
+
+	var current string
+	if err := tx.Get(&current, "SELECT txid_current_snapshot()::text"); err != nil {
+		return nil, "", dbError(err)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id > $1 AND $2 = ANY(recipients)", eventColumns, eventsTable)
+	args := []interface{}{afterId, userId, limit}
+	if snapshot != "" {
+		query += " AND txid >= txid_snapshot_xmin($4::txid_snapshot) AND NOT txid_visible_in_snapshot(txid, $4::txid_snapshot)"
+		args = append(args, snapshot)
+	}
+	query += " ORDER BY id LIMIT $3"
+
+	if err := tx.Select(&events, query, args...); err != nil {
+		return nil, "", dbError(err)
+	}
+
+	return events, current, nil
+}
+
+// удаление событий старше before, события хранятся ограниченное время
+func (r *EventPostgres) DeleteBefore(before time.Time) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE created_at < $1", eventsTable)
+	_, err := r.db.Exec(query, before)
+
+	return dbError(err)
+}
+
+// интервал проверки соединения подписки при отсутствии уведомлений
+const listenerPingInterval = 90 * time.Second
+
+// подписка на уведомления о новых событиях через LISTEN.
+// Для подписки используется отдельное соединение с БД,
+// при его обрыве pq.Listener переподключается автоматически
+type EventListenerPostgres struct {
+	listener *pq.Listener
+	ids      chan int64
+	// закрывается в Close, чтобы run не заблокировался на отправке
+	// в ids, который больше никто не читает
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// конструктор подписки, cfg - параметры подключения к БД
+func NewEventListenerPostgres(cfg Config) (*EventListenerPostgres, error) {
+	listener := pq.NewListener(cfg.dsn(), 10*time.Second, time.Minute, func(_ pq.ListenerEventType, err error) {
+		if err != nil {
+			logrus.Errorf("events listener: %s", err.Error())
+		}
+	})
+
+	if err := listener.Listen(eventsChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	l := &EventListenerPostgres{listener: listener, ids: make(chan int64, 64), done: make(chan struct{})}
+	go l.run()
+
+	return l, nil
+}
+
+// метод возвращает канал с id новых событий.
+// Значение 0 означает, что соединение было восстановлено
+// и часть уведомлений могла быть потеряна
+func (l *EventListenerPostgres) Notifications() <-chan int64 {
+	return l.ids
+}
+
+func (l *EventListenerPostgres) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.done)
+		err = l.listener.Close()
+	})
+	return err
+}
+
+// горутина переводит уведомления pq в id событий,
+// канал ids закрывается после закрытия подписки
+func (l *EventListenerPostgres) run() {
+	defer close(l.ids)
+
+	for {
+		select {
+		case n, ok := <-l.listener.Notify:
+			if !ok {
+				return
+			}
+			// nil приходит после переподключения
+			var id int64
+			if n != nil {
+				var err error
+				if id, err = strconv.ParseInt(n.Extra, 10, 64); err != nil {
+					logrus.Errorf("events listener: invalid payload %q", n.Extra)
+					continue
+				}
+			}
+			if !l.send(id) {
+				return
+			}
+		case <-time.After(listenerPingInterval):
+			go l.listener.Ping()
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// метод передает id события брокеру, false - подписка закрыта
+func (l *EventListenerPostgres) send(id int64) bool {
+	select {
+	case l.ids <- id:
+		return true
+	case <-l.done:
+		return false
+	}
+}
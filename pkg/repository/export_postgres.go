@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"fmt"
+	todo "to-do-list"
+)
+
+// создаем структуру репозитория
+type ExportPostgres struct {
+	db DB
+}
+
+// создаем конструктор репозитория для выгрузки данных пользователя
+func NewExportPostgres(db DB) *ExportPostgres {
+	return &ExportPostgres{db: db}
+}
+
+// все списки пользователя с его ролью, списки не разбиваются на страницы
+func (r *ExportPostgres) GetLists(userId int) ([]todo.ExportList, error) {
+	lists := make([]todo.ExportList, 0)
+
+	query := fmt.Sprintf("SELECT %s, ul.role FROM %s tl INNER JOIN %s ul on ul.list_id = tl.id WHERE ul.user_id = $1 ORDER BY tl.position, tl.id",
+		listColumns, todoListsTable, usersListsTable)
+	err := r.db.Select(&lists, query, userId)
+
+	return lists, dbError(err)
+}
+
+// метод читает задачи списка построчно и передает каждую в fn,
+// поэтому задачи списка не загружаются в память целиком.
+// Ошибка fn прерывает чтение и возвращается как есть
+func (r *ExportPostgres) StreamItems(userId, listId int, fn func(item todo.TodoItem) error) error {
+	query := fmt.Sprintf(`SELECT %s FROM %s ti INNER JOIN %s ul on ul.list_id = ti.list_id
+		WHERE ti.list_id = $1 AND ul.user_id = $2 ORDER BY ti.position, ti.id`,
+		itemColumns, todoItemsTable, usersListsTable)
+
+	rows, err := r.db.Queryx(query, listId, userId)
+	if err != nil {
+		return dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item todo.TodoItem
+		if err := rows.StructScan(&item); err != nil {
+			return dbError(err)
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+
+	return dbError(rows.Err())
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+	todo "to-do-list"
+)
+
+// создаем структуру репозитория
+type IdempotencyPostgres struct {
+	db DB
+}
+
+// создаем конструктор репозитория для работы с ключами идемпотентности
+func NewIdempotencyPostgres(db DB) *IdempotencyPostgres {
+	return &IdempotencyPostgres{db: db}
+}
+
+// метод занимает ключ для нового запроса. Если ключ уже занят,
+// возвращается сохраненная запись и false.
+// Вставка с ON CONFLICT DO NOTHING гарантирует, что из нескольких
+// одновременных запросов с одним ключом ключ займет только один.
+// Записи старше ttl считаются истекшими и удаляются, как и ключи без ответа старше lease:
+// запрос, занявший такой ключ, прерван (например, экземпляр упал), и клиент может повторить его
+func (r *IdempotencyPostgres) Begin(userId int, key, requestHash string, ttl, lease time.Duration) (todo.IdempotencyRecord, bool, error) {
+	var record todo.IdempotencyRecord
+
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1 AND (created_at < now() - make_interval(secs => $2)
+		OR status_code IS NULL AND created_at < now() - make_interval(secs => $3))`, idempotencyTable)
+	if _, err := r.db.Exec(deleteQuery, userId, ttl.Seconds(), lease.Seconds()); err != nil {
+		return record, false, err
+	}
+
+	insertQuery := fmt.Sprintf(`INSERT INTO %s (user_id, key, request_hash) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, key) DO NOTHING RETURNING key, request_hash, status_code, response_body, response_headers, created_at`, idempotencyTable)
+	rows, err := r.db.Queryx(insertQuery, userId, key, requestHash)
+	if err != nil {
+		return record, false, err
+	}
+
+	created := rows.Next()
+	if created {
+		err = rows.StructScan(&record)
+	}
+	rows.Close()
+	if err != nil || created {
+		return record, created, err
+	}
+
+	selectQuery := fmt.Sprintf("SELECT key, request_hash, status_code, response_body, response_headers, created_at FROM %s WHERE user_id = $1 AND key = $2", idempotencyTable)
+	err = r.db.Get(&record, selectQuery, userId, key)
+
+	return record, false, err
+}
+
+// метод сохраняет ответ на запрос, занявший ключ.
+// startedAt - время, когда запрос занял ключ: если аренда истекла и ключ занял
+// повторный запрос, ответ прерванного запроса не сохраняется
+func (r *IdempotencyPostgres) Complete(userId int, key string, startedAt time.Time, statusCode int, body []byte, headers todo.ResponseHeaders) error {
+	query := fmt.Sprintf(`UPDATE %s SET status_code = $1, response_body = $2, response_headers = $3
+		WHERE user_id = $4 AND key = $5 AND created_at = $6 AND status_code IS NULL`, idempotencyTable)
+
+	_, err := r.db.Exec(query, statusCode, body, headers, userId, key, startedAt)
+
+	return err
+}
+
+// метод освобождает ключ, если запрос завершился ошибкой сервера,
+// чтобы клиент мог повторить его. Ключ, занятый повторным запросом после
+// истечения аренды, не освобождается
+func (r *IdempotencyPostgres) Release(userId int, key string, startedAt time.Time) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1 AND key = $2 AND created_at = $3 AND status_code IS NULL", idempotencyTable)
+
+	_, err := r.db.Exec(query, userId, key, startedAt)
+
+	return err
+}
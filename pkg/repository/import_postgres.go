@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	todo "to-do-list"
+)
+
+// колонки задания импорта без загруженного файла
+const importJobColumns = `id, source, file_name, status, total_lists, processed_lists, total_items, processed_items,
+	imported_items, errors, error, created_at, started_at, finished_at`
+
+// задание, захваченное обработчиком, с загруженным файлом
+type PendingImport struct {
+	Id             int    `db:"id"`
+	UserId         int    `db:"user_id"`
+	Source         string `db:"source"`
+	FileName       string `db:"file_name"`
+	Data           []byte `db:"data"`
+	ProcessedLists int    `db:"processed_lists"`
+}
+
+// создаем структуру репозитория
+type ImportPostgres struct {
+	db DB
+}
+
+// создаем конструктор репозитория для заданий импорта
+func NewImportPostgres(db DB) *ImportPostgres {
+	return &ImportPostgres{db: db}
+}
+
+func (r *ImportPostgres) Create(userId int, input todo.ImportInput) (int, error) {
+	var id int
+
+	query := fmt.Sprintf("INSERT INTO %s (user_id, source, file_name, data) VALUES ($1, $2, $3, $4) RETURNING id", importJobsTable)
+	row := r.db.QueryRow(query, userId, input.Source, input.FileName, input.Data)
+	if err := row.Scan(&id); err != nil {
+		return 0, dbError(err)
+	}
+
+	return id, nil
+}
+
+func (r *ImportPostgres) GetAll(userId int) ([]todo.ImportJob, error) {
+	jobs := make([]todo.ImportJob, 0)
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE user_id = $1 ORDER BY id DESC", importJobColumns, importJobsTable)
+	err := r.db.Select(&jobs, query, userId)
+
+	return jobs, dbError(err)
+}
+
+func (r *ImportPostgres) GetById(userId, jobId int) (todo.ImportJob, error) {
+	var job todo.ImportJob
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE user_id = $1 AND id = $2", importJobColumns, importJobsTable)
+	err := r.db.Get(&job, query, userId, jobId)
+
+	return job, dbError(err)
+}
+
+// метод захватывает самое старое задание, ожидающее выполнения,
+// или задание, аренда которого истекла (обработчик остановился).
+// ErrNotFound - заданий нет
+func (r *ImportPostgres) Claim(lease time.Duration) (PendingImport, error) {
+	var job PendingImport
+
+	query := fmt.Sprintf(`UPDATE %[1]s SET status = '%[2]s', started_at = COALESCE(started_at, now()),
+			locked_until = now() + make_interval(secs => $1)
+		WHERE id = (
+			SELECT id FROM %[1]s
+			WHERE status = '%[3]s' OR (status = '%[2]s' AND locked_until < now())
+			ORDER BY id LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, source, file_name, data, processed_lists`,
+		importJobsTable, todo.ImportRunning, todo.ImportPending)
+	err := r.db.Get(&job, query, lease.Seconds())
+
+	return job, dbError(err)
+}
+
+// сохранение числа списков и задач в файле
+func (r *ImportPostgres) SetTotals(jobId, lists, items int) error {
+	query := fmt.Sprintf("UPDATE %s SET total_lists = $1, total_items = $2 WHERE id = $3", importJobsTable)
+
+	_, err := r.db.Exec(query, lists, items, jobId)
+
+	return dbError(err)
+}
+
+// ошибка отметки или продления аренды задания: аренда истекла
+// или задание уже продолжил другой обработчик
+var ErrLeaseLost = fmt.Errorf("%w: import job lease lost", todo.ErrConflict)
+
+// метод отмечает импорт очередного списка и продлевает аренду.
+// Вызывается в транзакции импорта списка, поэтому список не импортируется дважды:
+// отметка выполняется, только если аренда не истекла и до импорта списка было обработано
+// processedLists списков. Иначе возвращается ErrLeaseLost и транзакция списка откатывается
+func (r *ImportPostgres) Checkpoint(jobId, processedLists, processedItems, importedItems int, errs todo.ImportErrors, lease time.Duration) error {
+	query := fmt.Sprintf(`UPDATE %s SET processed_lists = processed_lists + 1, processed_items = processed_items + $1,
+			imported_items = imported_items + $2, errors = errors || $3::jsonb, locked_until = now() + make_interval(secs => $4)
+		WHERE id = $5 AND status = '%s' AND processed_lists = $6 AND locked_until > now()`, importJobsTable, todo.ImportRunning)
+
+	result, err := r.db.Exec(query, processedItems, importedItems, errs, lease.Seconds(), jobId, processedLists)
+
+	return checkLeaseAffected(result, err)
+}
+
+// продление аренды выполняемого задания, ErrLeaseLost - аренда истекла
+func (r *ImportPostgres) ExtendLease(jobId int, lease time.Duration) error {
+	query := fmt.Sprintf(`UPDATE %s SET locked_until = now() + make_interval(secs => $1)
+		WHERE id = $2 AND status = '%s' AND locked_until > now()`, importJobsTable, todo.ImportRunning)
+
+	result, err := r.db.Exec(query, lease.Seconds(), jobId)
+
+	return checkLeaseAffected(result, err)
+}
+
+func checkLeaseAffected(result sql.Result, err error) error {
+	if err != nil {
+		return dbError(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return dbError(err)
+	}
+	if affected == 0 {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
+
+// завершение задания, загруженный файл больше не нужен и удаляется.
+// message - причина неудачи для статуса failed
+func (r *ImportPostgres) Finish(jobId int, status string, message *string) error {
+	query := fmt.Sprintf(`UPDATE %s SET status = $1, error = $2, data = NULL, locked_until = NULL, finished_at = now()
+		WHERE id = $3`, importJobsTable)
+
+	_, err := r.db.Exec(query, status, message, jobId)
+
+	return dbError(err)
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	todo "to-do-list"
+)
+
+// создаем структуру репозитория
+type ListMemberPostgres struct {
+	db DB
+}
+
+// создаем конструктор репозитория для работы с участниками списков
+func NewListMemberPostgres(db DB) *ListMemberPostgres {
+	return &ListMemberPostgres{db: db}
+}
+
+// участников списка видят все его участники
+func (r *ListMemberPostgres) GetMembers(userId, listId int) ([]todo.ListMember, error) {
+	if _, err := listRole(r.db, userId, listId); err != nil {
+		return nil, err
+	}
+
+	members := make([]todo.ListMember, 0)
+	query := fmt.Sprintf(`SELECT u.id AS user_id, u.name, u.username, ul.role FROM %s ul INNER JOIN %s u on u.id = ul.user_id
+		WHERE ul.list_id = $1 ORDER BY ul.id`, usersListsTable, usersTable)
+	err := r.db.Select(&members, query, listId)
+
+	return members, dbError(err)
+}
+
+// метод добавляет участника в список или меняет его роль,
+// доступно только владельцу списка, роль владельца не меняется
+func (r *ListMemberPostgres) SetMember(userId, listId int, input todo.ListMemberInput) (todo.ListMember, error) {
+	member := todo.ListMember{Username: input.Username, Role: input.Role}
+
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return member, dbError(err)
+	}
+
+	role, err := listRole(tx, userId, listId)
+	if err := requireRole(role, todo.RoleOwner, err); err != nil {
+		tx.Rollback()
+		return member, err
+	}
+
+	userQuery := fmt.Sprintf("SELECT id AS user_id, name, username FROM %s WHERE username = $1", usersTable)
+	if err := tx.Get(&member, userQuery, input.Username); err != nil {
+		tx.Rollback()
+		if err := dbError(err); !errors.Is(err, todo.ErrNotFound) {
+			return member, err
+		}
+		return member, fmt.Errorf("%w: user %q not found", todo.ErrNotFound, input.Username)
+	}
+	member.Role = input.Role
+
+	// текущая роль пользователя, ErrNotFound - пользователь еще не участник
+	current, err := listRole(tx, member.UserId, listId)
+	switch {
+	case err == nil && current == todo.RoleOwner:
+		tx.Rollback()
+		return member, fmt.Errorf("%w: owner role cannot be changed", todo.ErrConflict)
+	case err == nil:
+		query := fmt.Sprintf("UPDATE %s SET role = $1 WHERE user_id = $2 AND list_id = $3", usersListsTable)
+		_, err = tx.Exec(query, member.Role, member.UserId, listId)
+	case errors.Is(err, todo.ErrNotFound):
+		query := fmt.Sprintf("INSERT INTO %s (user_id, list_id, role) VALUES ($1, $2, $3)", usersListsTable)
+		_, err = tx.Exec(query, member.UserId, listId, member.Role)
+	}
+	if err != nil {
+		tx.Rollback()
+		return member, dbError(err)
+	}
+
+	return member, dbError(tx.Commit())
+}
+
+// метод удаляет участника из списка: владелец может удалить любого участника,
+// остальные - только себя (покинуть список). Владельца удалить нельзя
+func (r *ListMemberPostgres) DeleteMember(userId, listId, memberId int) error {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return dbError(err)
+	}
+
+	role, err := listRole(tx, userId, listId)
+	if memberId != userId {
+		err = requireRole(role, todo.RoleOwner, err)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	current, err := listRole(tx, memberId, listId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if current == todo.RoleOwner {
+		tx.Rollback()
+		return fmt.Errorf("%w: owner cannot be removed from the list", todo.ErrConflict)
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1 AND list_id = $2", usersListsTable)
+	if _, err := tx.Exec(query, memberId, listId); err != nil {
+		tx.Rollback()
+		return dbError(err)
+	}
+
+	return dbError(tx.Commit())
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"fmt"
+	todo "to-do-list"
+
+	"github.com/lib/pq"
+)
+
+// создаем структуру репозитория
+type MaintenancePostgres struct {
+	db DB
+}
+
+// создаем конструктор репозитория для обслуживания БД
+func NewMaintenancePostgres(db DB) *MaintenancePostgres {
+	return &MaintenancePostgres{db: db}
+}
+
+// метод находит списки без участников и число их задач.
+// Найденные списки блокируются до конца транзакции
+func (r *MaintenancePostgres) FindOrphans() (todo.OrphanReport, error) {
+	report := todo.OrphanReport{Lists: make([]int, 0)}
+
+	query := fmt.Sprintf(`SELECT tl.id FROM %s tl WHERE NOT EXISTS (SELECT 1 FROM %s ul WHERE ul.list_id = tl.id)
+		ORDER BY tl.id FOR UPDATE`, todoListsTable, usersListsTable)
+	if err := r.db.Select(&report.Lists, query); err != nil {
+		return report, dbError(err)
+	}
+
+	query = fmt.Sprintf("SELECT count(*) FROM %s WHERE list_id = ANY($1)", todoItemsTable)
+	err := r.db.Get(&report.ListItems, query, pq.Array(report.Lists))
+
+	return report, dbError(err)
+}
+
+// удаление найденных списков, их задачи удаляются каскадно.
+// Участники проверяются повторно: список мог получить участника после поиска
+func (r *MaintenancePostgres) DeleteOrphans(report todo.OrphanReport) error {
+	query := fmt.Sprintf("DELETE FROM %s tl WHERE tl.id = ANY($1) AND NOT EXISTS (SELECT 1 FROM %s ul WHERE ul.list_id = tl.id)",
+		todoListsTable, usersListsTable)
+	_, err := r.db.Exec(query, pq.Array(report.Lists))
+
+	return dbError(err)
+}
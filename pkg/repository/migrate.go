@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"to-do-list/schema"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/sirupsen/logrus"
+)
+
+// Миграции схемы БД из папки schema, встроенные в бинарный файл, применяются golang-migrate.
+// Версия схемы хранится в таблице schema_migrations, как и при применении миграций
+// утилитой migrate, поэтому БД, обновленные утилитой, продолжают обновляться приложением.
+// Драйвер postgres выполняет каждую операцию под pg_advisory_lock: при одновременном
+// запуске нескольких экземпляров миграции применяет один, остальные ждут снятия блокировки
+// и находят схему актуальной
+
+// ошибка запуска на БД, схема которой обновлена более новой версией приложения
+var ErrSchemaTooNew = errors.New("database schema is newer than the application")
+
+type Migrator struct {
+	m *migrate.Migrate
+	// последняя версия миграций, известная приложению
+	latest uint
+}
+
+// конструктор открывает для миграций отдельное подключение к БД,
+// оно закрывается методом Close
+func NewMigrator(cfg Config) (*Migrator, error) {
+	source, err := iofs.New(schema.Migrations, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := latestVersion(source)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("postgres", cfg.dsn())
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		driver.Close()
+		return nil, err
+	}
+	m.Log = migrateLogger{}
+
+	return &Migrator{m: m, latest: latest}, nil
+}
+
+// последняя версия среди файлов миграций
+func latestVersion(source interface {
+	First() (uint, error)
+	Next(version uint) (uint, error)
+}) (uint, error) {
+	version, err := source.First()
+	if err != nil {
+		return 0, fmt.Errorf("no migrations found: %w", err)
+	}
+
+	for {
+		next, err := source.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// применение steps следующих миграций, 0 - всех
+func (r *Migrator) Up(steps int) error {
+	var err error
+	if steps > 0 {
+		err = r.m.Steps(steps)
+	} else {
+		err = r.m.Up()
+	}
+
+	return migrateError(err)
+}
+
+// откат steps последних миграций, 0 - всех
+func (r *Migrator) Down(steps int) error {
+	var err error
+	if steps > 0 {
+		err = r.m.Steps(-steps)
+	} else {
+		err = r.m.Down()
+	}
+
+	return migrateError(err)
+}
+
+// текущая версия схемы, 0 - миграции не применялись.
+// dirty - миграция версии завершилась ошибкой, схему нужно исправить вручную
+// и отметить версию командой force
+func (r *Migrator) Version() (uint, bool, error) {
+	version, dirty, err := r.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+
+	return version, dirty, err
+}
+
+// последняя версия схемы, известная приложению
+func (r *Migrator) Latest() uint {
+	return r.latest
+}
+
+// установка версии схемы без применения миграций и со сбросом признака dirty
+func (r *Migrator) Force(version int) error {
+	return r.m.Force(version)
+}
+
+// проверка схемы перед запуском сервера: сервер не запускается
+// на схеме новее известной приложению и на схеме с незавершенной миграцией
+func (r *Migrator) Check() error {
+	version, dirty, err := r.Version()
+	if err != nil {
+		return err
+	}
+
+	if version > r.latest {
+		return fmt.Errorf("%w: schema version %d, latest known version %d", ErrSchemaTooNew, version, r.latest)
+	}
+	if dirty {
+		return fmt.Errorf("database schema version %d is dirty: fix the schema and run migrate force", version)
+	}
+
+	return nil
+}
+
+// закрытие подключения к БД, открытого для миграций
+func (r *Migrator) Close() error {
+	sourceErr, dbErr := r.m.Close()
+	if sourceErr != nil {
+		return sourceErr
+	}
+
+	return dbErr
+}
+
+// отсутствие миграций для применения ошибкой не считается
+func migrateError(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+
+	return err
+}
+
+// вывод хода миграций в журнал приложения
+type migrateLogger struct{}
+
+func (migrateLogger) Printf(format string, v ...interface{}) {
+	logrus.Infof("migrate: "+format, v...)
+}
+
+func (migrateLogger) Verbose() bool {
+	return false
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+	todo "to-do-list"
+
+	"github.com/lib/pq"
+)
+
+// ключ рекомендательной блокировки, которую удерживает экземпляр, публикующий outbox
+const outboxLockKey = "todo_outbox_relay"
+
+// создаем структуру репозитория
+type OutboxPostgres struct {
+	db DB
+}
+
+// создаем конструктор репозитория для работы с исходящими сообщениями.
+// Сообщения добавляются в EventPostgres.Publish вместе с событием
+func NewOutboxPostgres(db DB) *OutboxPostgres {
+	return &OutboxPostgres{db: db}
+}
+
+// сообщение, захваченное ретранслятором: LeasedUntil - срок аренды,
+// до которого сообщение не выбирается другими экземплярами
+type ClaimedMessage struct {
+	todo.OutboxMessage
+	LeasedUntil time.Time `db:"next_attempt_at"`
+}
+
+// метод захватывает до limit сообщений к публикации в порядке id на время lease.
+// Захват выполняется в короткой транзакции под блокировкой публикации:
+// экземпляры захватывают сообщения по очереди, публикация идет вне транзакции.
+// Сообщение не выбирается, пока более раннее сообщение того же агрегата
+// захвачено или ожидает повторной попытки: так сохраняется порядок внутри агрегата.
+// Если блокировку держит другой экземпляр, возвращается пустой список
+func (r *OutboxPostgres) Claim(limit int, lease time.Duration) ([]ClaimedMessage, error) {
+	messages := make([]ClaimedMessage, 0, limit)
+
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	var locked bool
+	if err := tx.Get(&locked, "SELECT pg_try_advisory_xact_lock(hashtext($1))", outboxLockKey); err != nil || !locked {
+		tx.Rollback()
+		return messages, dbError(err)
+	}
+
+	query := fmt.Sprintf(`WITH c AS (
+			UPDATE %[1]s SET next_attempt_at = now() + make_interval(secs => $2)
+			WHERE id IN (
+				SELECT o.id FROM %[1]s o
+				WHERE o.published_at IS NULL AND o.next_attempt_at <= now() AND NOT EXISTS (
+					SELECT 1 FROM %[1]s b
+					WHERE b.published_at IS NULL AND b.aggregate_type = o.aggregate_type AND b.aggregate_id = o.aggregate_id
+						AND b.id < o.id AND b.next_attempt_at > now()
+				)
+				ORDER BY o.id LIMIT $1
+			)
+			RETURNING id, event_id, aggregate_type, aggregate_id, type, payload, attempts, created_at, next_attempt_at
+		)
+		SELECT * FROM c ORDER BY id`, outboxTable)
+	if err := tx.Select(&messages, query, limit, lease.Seconds()); err != nil {
+		tx.Rollback()
+		return nil, dbError(err)
+	}
+
+	return messages, dbError(tx.Commit())
+}
+
+// изменения сообщения применяются, только пока аренда leasedUntil не перехвачена:
+// после истечения аренды сообщение могло быть захвачено другим экземпляром
+func (r *OutboxPostgres) MarkPublished(id int64, leasedUntil time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET published_at = now(), attempts = attempts + 1, last_error = NULL
+		WHERE id = $1 AND next_attempt_at = $2 AND published_at IS NULL`, outboxTable)
+
+	_, err := r.db.Exec(query, id, leasedUntil)
+
+	return dbError(err)
+}
+
+// неудачная публикация повторяется в retryAt, сообщения не отбрасываются
+func (r *OutboxPostgres) MarkFailed(id int64, leasedUntil time.Time, message string, retryAt time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET attempts = attempts + 1, last_error = $3, next_attempt_at = $4
+		WHERE id = $1 AND next_attempt_at = $2 AND published_at IS NULL`, outboxTable)
+
+	_, err := r.db.Exec(query, id, leasedUntil, message, retryAt)
+
+	return dbError(err)
+}
+
+// метод снимает аренду с неопубликованных сообщений пачки, они снова доступны для захвата
+func (r *OutboxPostgres) Release(ids []int64, leasedUntil time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET next_attempt_at = now()
+		WHERE id = ANY($1) AND next_attempt_at = $2 AND published_at IS NULL`, outboxTable)
+
+	_, err := r.db.Exec(query, pq.Array(ids), leasedUntil)
+
+	return dbError(err)
+}
+
+// удаление опубликованных сообщений старше before
+func (r *OutboxPostgres) DeletePublishedBefore(before time.Time) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE published_at < $1", outboxTable)
+
+	_, err := r.db.Exec(query, before)
+
+	return dbError(err)
+}
@@ -0,0 +1,211 @@
+// в данном файле реализуется курсорная (keyset) пагинация для выборок списков и задач
+
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	todo "to-do-list"
+	"unicode/utf8"
+)
+
+// описание колонки сортировки:
+// expr - выражение для ORDER BY (NULL заменяются на граничное значение),
+// cast - тип, к которому приводится значение из курсора
+type sortColumn struct {
+	expr string
+	cast string
+}
+
+// соответствие полей сортировки из запроса колонкам таблиц,
+// префиксы tl и ti совпадают с алиасами в запросах
+var (
+	listSortColumns = map[string]sortColumn{
+		"created":    {expr: "tl.id", cast: "int"},
+		"updated":    {expr: "tl.updated_at", cast: "timestamptz"},
+		"title":      {expr: "tl.title", cast: "text"},
+		"position":   {expr: "tl.position", cast: "int"},
+		"created_at": {expr: "tl.created_at", cast: "timestamptz"},
+		"updated_at": {expr: "tl.updated_at", cast: "timestamptz"},
+	}
+	itemSortColumns = map[string]sortColumn{
+		"created":      {expr: "ti.id", cast: "int"},
+		"updated":      {expr: "ti.updated_at", cast: "timestamptz"},
+		"title":        {expr: "ti.title", cast: "text"},
+		"due_date":     {expr: "COALESCE(ti.due_date, 'infinity')", cast: "timestamptz"},
+		"position":     {expr: "ti.position", cast: "int"},
+		"created_at":   {expr: "ti.created_at", cast: "timestamptz"},
+		"updated_at":   {expr: "ti.updated_at", cast: "timestamptz"},
+		"completed_at": {expr: "COALESCE(ti.completed_at, 'infinity')", cast: "timestamptz"},
+	}
+)
+
+// содержимое курсора: поле и направление сортировки,
+// значение сортировки и id последней записи страницы
+type cursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	Id    int    `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, todo.ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, todo.ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// форматы текстового представления timestamptz в postgres (DateStyle ISO),
+// в таком виде значение сортировки попадает в курсор
+var cursorTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999-07",
+	"2006-01-02 15:04:05.999999-07:00",
+	"2006-01-02 15:04:05.999999-07:00:00",
+}
+
+// проверка значения из курсора по типу колонки сортировки.
+// Курсор приходит от клиента, и без проверки поврежденное значение
+// привело бы к ошибке приведения типа в БД вместо ответа 400
+func validCursorValue(cast, value string) bool {
+	switch cast {
+	case "int":
+		_, err := strconv.ParseInt(value, 10, 32)
+		return err == nil
+	case "timestamptz":
+		if value == "infinity" || value == "-infinity" {
+			return true
+		}
+		for _, layout := range cursorTimeLayouts {
+			if _, err := time.Parse(layout, value); err == nil {
+				return true
+			}
+		}
+		return false
+	default:
+		// нулевой байт и неверный UTF-8 postgres не принимает в тексте
+		return utf8.ValidString(value) && !strings.ContainsRune(value, 0)
+	}
+}
+
+// pageBuilder собирает WHERE, ORDER BY и LIMIT части запроса.
+// argId продолжает нумерацию плейсхолдеров после уже добавленных аргументов
+type pageBuilder struct {
+	where []string
+	args  []interface{}
+	argId int
+}
+
+func newPageBuilder(where string, args ...interface{}) *pageBuilder {
+	return &pageBuilder{where: []string{where}, args: args, argId: len(args) + 1}
+}
+
+func (b *pageBuilder) add(condition string, arg interface{}) {
+	b.where = append(b.where, fmt.Sprintf(condition, b.argId))
+	b.args = append(b.args, arg)
+	b.argId++
+}
+
+// фильтр по подстроке в названии, спецсимволы LIKE экранируются
+func (b *pageBuilder) titleFilter(column, title string) {
+	if title == "" {
+		return
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	b.add(column+" ILIKE '%%' || $%d || '%%'", replacer.Replace(title))
+}
+
+// фильтры по полям аудита, alias - алиас таблицы списков или задач
+func (b *pageBuilder) auditFilter(alias string, query todo.PageQuery) {
+	if query.CreatedAfter != nil {
+		b.add(alias+".created_at >= $%d", *query.CreatedAfter)
+	}
+	if query.CreatedBefore != nil {
+		b.add(alias+".created_at < $%d", *query.CreatedBefore)
+	}
+	if query.UpdatedAfter != nil {
+		b.add(alias+".updated_at >= $%d", *query.UpdatedAfter)
+	}
+	if query.UpdatedBefore != nil {
+		b.add(alias+".updated_at < $%d", *query.UpdatedBefore)
+	}
+	if query.CreatedBy != nil {
+		b.add(alias+".created_by = $%d", *query.CreatedBy)
+	}
+	if query.UpdatedBy != nil {
+		b.add(alias+".updated_by = $%d", *query.UpdatedBy)
+	}
+}
+
+// фильтры задач из параметров запроса: подстрока в названии, статус,
+// время выполнения и поля аудита
+func (b *pageBuilder) itemFilter(query todo.PageQuery) {
+	b.titleFilter("ti.title", query.Title)
+	if query.Done != nil {
+		b.add("ti.done = $%d", *query.Done)
+	}
+	if query.CompletedAfter != nil {
+		b.add("ti.completed_at >= $%d", *query.CompletedAfter)
+	}
+	if query.CompletedBefore != nil {
+		b.add("ti.completed_at < $%d", *query.CompletedBefore)
+	}
+	b.auditFilter("ti", query)
+}
+
+// добавляет условие для курсора и возвращает хвост запроса с ORDER BY и LIMIT,
+// а также выражение ключа сортировки для SELECT
+func (b *pageBuilder) page(columns map[string]sortColumn, idColumn string, query todo.PageQuery) (string, string, error) {
+	column := columns[query.Sort]
+
+	direction, compare := "ASC", ">"
+	if query.Order == todo.SortDesc {
+		direction, compare = "DESC", "<"
+	}
+
+	if query.Cursor != "" {
+		c, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return "", "", err
+		}
+		// курсор действителен только для той же сортировки
+		// значения курсора проверяются до подстановки в запрос (id - колонка int)
+		if c.Sort != query.Sort || c.Order != query.Order ||
+			!validCursorValue(column.cast, c.Value) || c.Id < 0 || c.Id > math.MaxInt32 {
+			return "", "", todo.ErrInvalidCursor
+		}
+
+		b.where = append(b.where, fmt.Sprintf("(%s, %s) %s ($%d::%s, $%d)", column.expr, idColumn, compare, b.argId, column.cast, b.argId+1))
+		b.args = append(b.args, c.Value, c.Id)
+		b.argId += 2
+	}
+
+	// запрашиваем на одну запись больше, чтобы понять, есть ли следующая страница
+	tail := fmt.Sprintf("WHERE %s ORDER BY %s %s, %s %s LIMIT %d",
+		strings.Join(b.where, " AND "), column.expr, direction, idColumn, direction, query.Limit+1)
+
+	return tail, column.expr + "::text AS sort_key", nil
+}
+
+// nextCursor возвращает курсор следующей страницы по последней записи текущей
+func nextCursor(query todo.PageQuery, sortKey string, id int) string {
+	return encodeCursor(cursor{Sort: query.Sort, Order: query.Order, Value: sortKey, Id: id})
+}
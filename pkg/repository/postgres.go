@@ -11,11 +11,22 @@ import (
 
 // описвания названия таблиц из БД для использования в методах модуля
 const (
-	usersTable      = "users"
-	todoListsTable  = "todo_lists"
-	usersListsTable = "users_lists"
-	todoItemsTable  = "todo_items"
-	listsItemsTable = "lists_items"
+	usersTable       = "users"
+	todoListsTable   = "todo_lists"
+	usersListsTable  = "users_lists"
+	todoItemsTable   = "todo_items"
+	filtersTable     = "saved_filters"
+	idempotencyTable = "idempotency_keys"
+	eventsTable      = "events"
+	webhooksTable    = "webhooks"
+	// таблица очереди и журнала доставок вебхуков
+	webhookDeliveriesTable = "webhook_deliveries"
+	outboxTable            = "outbox"
+	syncTombstonesTable    = "sync_tombstones"
+	syncClientIdsTable     = "sync_client_ids"
+	calendarFeedsTable     = "calendar_feeds"
+	importJobsTable        = "import_jobs"
+	auditLogTable          = "audit_log"
 )
 
 // параметры для БД
@@ -28,11 +39,16 @@ type Config struct {
 	SSLMode  string
 }
 
+// строка подключения к БД, используется также для подписки на уведомления (event_postgres.go)
+func (cfg Config) dsn() string {
+	return fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=%s",
+		cfg.Host, cfg.Port, cfg.Username, cfg.DBName, cfg.Password, cfg.SSLMode)
+}
+
 // описываем конструктор для инициализации БД
 func NewPostgresDB(cfg Config) (*sqlx.DB, error) {
 	// запуск БД
-	db, err := sqlx.Open("postgres", fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.Username, cfg.DBName, cfg.Password, cfg.SSLMode))
+	db, err := sqlx.Open("postgres", cfg.dsn())
 	if err != nil {
 		return nil, err
 	}
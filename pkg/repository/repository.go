@@ -1,14 +1,14 @@
 package repository
 
 import (
+	"time"
 	todo "to-do-list"
-
-	"github.com/jmoiron/sqlx"
 )
 
 // Интерфейсы называем исходя из их доменной зоны бизнес-логики.
 // Создаем объединяющую структуру Repository, и объявим ее конструктор.
-// Repository работает непосредственно с базой данных, поэтому конструктор принимает указатель на структуру - *sqlx.DB.
+// Repository работает непосредственно с базой данных, поэтому конструктор принимает подключение к БД - *sqlx.DB
+// (или транзакцию *sqlx.Tx, см. интерфейс DB в transaction.go).
 // Это внедрение зависимостей
 type Authorization interface {
 	CreateUser(user todo.User) (int, error)
@@ -16,33 +16,203 @@ type Authorization interface {
 }
 type TodoList interface {
 	Create(userId int, list todo.TodoList) (int, error)
-	GetAll(userId int) ([]todo.TodoList, error)
+	GetAll(userId int, query todo.PageQuery) ([]todo.TodoList, string, error)
 	GetById(userId, listId int) (todo.TodoList, error)
-	DeleteList(userId, listId int) error
+	CheckAccess(userId, listId int, required string) error
+	DeleteList(userId, listId int, version *int) error
 	UpdateList(userId, listId int, input todo.UpdateListInput) error
+	PatchList(userId, listId int, version *int, apply func(list todo.TodoList) (todo.TodoList, error)) (todo.TodoList, error)
+}
+type ListMember interface {
+	GetMembers(userId, listId int) ([]todo.ListMember, error)
+	SetMember(userId, listId int, input todo.ListMemberInput) (todo.ListMember, error)
+	DeleteMember(userId, listId, memberId int) error
 }
 type TodoItem interface {
-	CreateItem(listId int, item todo.TodoItem) (int, error)
-	GetAllItems(userId, listId int) ([]todo.TodoItem, error)
+	CreateItem(userId, listId int, item todo.TodoItem) (int, error)
+	GetAllItems(userId, listId int, query todo.PageQuery) ([]todo.TodoItem, string, error)
 	GetItemById(userId, itemId int) (todo.TodoItem, error)
 	UpdateItem(userId, itemId int, input todo.UpdateItemInput) error
-	DeleteItem(userId, itemId int) error
+	PatchItem(userId, itemId int, version *int, apply func(item todo.TodoItem) (todo.TodoItem, error)) (todo.TodoItem, error)
+	DeleteItem(userId, itemId int, version *int) error
+	BulkItems(userId int, input todo.BulkItemsInput) ([]todo.BulkItemResult, error)
+}
+type SavedFilter interface {
+	Create(userId int, filter todo.SavedFilter) (int, error)
+	GetAll(userId int) ([]todo.SavedFilter, error)
+	GetById(userId, filterId int) (todo.SavedFilter, error)
+	Update(userId, filterId int, input todo.UpdateSavedFilterInput) error
+	Delete(userId, filterId int) error
+	GetItems(userId int, filter todo.FilterExpression, query todo.PageQuery) ([]todo.TodoItem, string, error)
+}
+type Idempotency interface {
+	Begin(userId int, key, requestHash string, ttl, lease time.Duration) (todo.IdempotencyRecord, bool, error)
+	Complete(userId int, key string, startedAt time.Time, statusCode int, body []byte, headers todo.ResponseHeaders) error
+	Release(userId int, key string, startedAt time.Time) error
+}
+type Search interface {
+	Search(userId int, query todo.SearchQuery) ([]todo.SearchResult, error)
+}
+type Event interface {
+	Publish(event todo.Event) error
+	ItemStates(itemIds []int) (map[int]ItemState, error)
+	Snapshot() (string, error)
+	GetSince(snapshot string) ([]todo.Event, string, error)
+	GetAfter(userId int, snapshot string, afterId int64, limit int) ([]todo.Event, string, error)
+	DeleteBefore(before time.Time) error
+}
+type Webhook interface {
+	Create(userId int, webhook todo.Webhook) (int, error)
+	GetAll(userId int) ([]todo.Webhook, error)
+	GetById(userId, webhookId int) (todo.Webhook, error)
+	Update(userId, webhookId int, input todo.UpdateWebhookInput) error
+	Delete(userId, webhookId int) error
+	GetDeliveries(userId, webhookId int, query todo.DeliveryQuery) ([]todo.WebhookDelivery, string, error)
+	Redeliver(userId, webhookId int, deliveryId int64) (todo.WebhookDelivery, error)
+	ClaimDeliveries(limit int, lease time.Duration) ([]PendingDelivery, error)
+	CompleteDelivery(id int64, statusCode int) error
+	FailDelivery(id int64, statusCode *int, message string, retryAt *time.Time, disableAfter int) error
+}
+type Outbox interface {
+	Claim(limit int, lease time.Duration) ([]ClaimedMessage, error)
+	MarkPublished(id int64, leasedUntil time.Time) error
+	MarkFailed(id int64, leasedUntil time.Time, message string, retryAt time.Time) error
+	Release(ids []int64, leasedUntil time.Time) error
+	DeletePublishedBefore(before time.Time) error
+}
+type Sync interface {
+	Changes(userId int, since int64) (todo.SyncChanges, int64, error)
+	DeleteTombstonesBefore(before time.Time) error
+	ResolveClientId(userId int, entity, clientId string) (int, error)
+	SaveClientId(userId int, entity, clientId string, id int) error
+	ListFieldTimes(userId, listId int) (todo.FieldTimes, error)
+	ItemFieldTimes(userId, itemId int) (todo.FieldTimes, error)
+	SetFieldTimes(entity string, id int, fields []string, changedAt time.Time) error
+}
+type Calendar interface {
+	SaveFeed(userId, listId int, tokenHash string) error
+	DeleteFeed(userId, listId int) error
+	GetFeed(tokenHash string) (int, int, error)
+	GetCalendars(userId int) ([]todo.Calendar, error)
+	GetCalendar(userId, listId int) (todo.Calendar, error)
+	GetItems(userId, listId int) ([]todo.CalendarItem, error)
+	GetItem(userId, listId int, name string) (todo.CalendarItem, error)
+	SetItemRef(itemId int, uid, name string) error
+}
+type Import interface {
+	Create(userId int, input todo.ImportInput) (int, error)
+	GetAll(userId int) ([]todo.ImportJob, error)
+	GetById(userId, jobId int) (todo.ImportJob, error)
+	Claim(lease time.Duration) (PendingImport, error)
+	SetTotals(jobId, lists, items int) error
+	Checkpoint(jobId, processedLists, processedItems, importedItems int, errs todo.ImportErrors, lease time.Duration) error
+	ExtendLease(jobId int, lease time.Duration) error
+	Finish(jobId int, status string, message *string) error
+}
+type Export interface {
+	GetLists(userId int) ([]todo.ExportList, error)
+	StreamItems(userId, listId int, fn func(item todo.TodoItem) error) error
+}
+type Account interface {
+	GetDeletion(userId int) (todo.AccountDeletion, error)
+	ScheduleDeletion(userId int, passwordHash string, gracePeriod time.Duration) (todo.AccountDeletion, error)
+	CancelDeletion(userId int) error
+	LockDueDeletion() (int, error)
+	TransferLists(userId int) ([]int, error)
+	DeleteSoleLists(userId int) (int, int, error)
+	GetMemberLists(userId int) ([]int, error)
+	DeleteUser(userId int) error
+}
+type AuditLog interface {
+	Record(userId int, action string, details interface{}) error
+}
+type Maintenance interface {
+	FindOrphans() (todo.OrphanReport, error)
+	DeleteOrphans(report todo.OrphanReport) error
+}
+
+// подписка на уведомления о новых событиях (LISTEN/NOTIFY),
+// работает на отдельном соединении и не входит в Repository
+type EventListener interface {
+	Notifications() <-chan int64
+	Close() error
 }
 
 // описываем струтуру сервиса, состоящую из интерфейсов
+// db хранится для запуска транзакций, охватывающих несколько репозиториев
 type Repository struct {
+	db DB
+
 	Authorization
 	TodoList
+	ListMember
 	TodoItem
+	SavedFilter
+	Idempotency
+	Search
+	Event
+	Webhook
+	Outbox
+	Sync
+	Calendar
+	Import
+	Export
+	Account
+	AuditLog
+	Maintenance
 }
 
 // repository должен работать с БД, передаем объект базы данных в качестве аргумента
 // метод вызывается в main.go
-func NewRepository(db *sqlx.DB) *Repository {
+func NewRepository(db DB) *Repository {
 	// инициализируем репозиторий
 	return &Repository{
+		db:            db,
 		Authorization: NewAuthPostgres(db),
 		TodoList:      NewTodoListPostgres(db),
+		ListMember:    NewListMemberPostgres(db),
 		TodoItem:      NewTodoItemPostgres(db),
+		SavedFilter:   NewSavedFilterPostgres(db),
+		Idempotency:   NewIdempotencyPostgres(db),
+		Search:        NewSearchPostgres(db),
+		Event:         NewEventPostgres(db),
+		Webhook:       NewWebhookPostgres(db),
+		Outbox:        NewOutboxPostgres(db),
+		Sync:          NewSyncPostgres(db),
+		Calendar:      NewCalendarPostgres(db),
+		Import:        NewImportPostgres(db),
+		Export:        NewExportPostgres(db),
+		Account:       NewAccountPostgres(db),
+		AuditLog:      NewAuditLogPostgres(db),
+		Maintenance:   NewMaintenancePostgres(db),
+	}
+}
+
+// метод выполняет fn с репозиториями, работающими внутри одной транзакции.
+// Если fn вернула ошибку, изменения всех репозиториев откатываются
+func (r *Repository) Transaction(fn func(repos *Repository) error) error {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return err
 	}
+
+	if err := fn(NewRepository(tx.Tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// метод выполняет fn с репозиториями, работающими внутри читающей транзакции
+// с единым снимком данных: все запросы fn видят данные на момент первого запроса
+func (r *Repository) Snapshot(fn func(repos *Repository) error) error {
+	tx, err := beginSnapshot(r.db)
+	if err != nil {
+		return err
+	}
+	// транзакция только читает данные, фиксировать нечего
+	defer tx.Rollback()
+
+	return fn(NewRepository(tx.Tx))
 }
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	todo "to-do-list"
+
+	"github.com/lib/pq"
+)
+
+// создаем структуру репозитория
+type SavedFilterPostgres struct {
+	db DB
+}
+
+// создаем конструктор репозитория для работы с сохраненными фильтрами
+func NewSavedFilterPostgres(db DB) *SavedFilterPostgres {
+	return &SavedFilterPostgres{db: db}
+}
+
+func (r *SavedFilterPostgres) Create(userId int, filter todo.SavedFilter) (int, error) {
+	var id int
+
+	query := fmt.Sprintf("INSERT INTO %s (user_id, name, filter) VALUES ($1, $2, $3) RETURNING id", filtersTable)
+	row := r.db.QueryRow(query, userId, filter.Name, filter.Filter)
+	if err := row.Scan(&id); err != nil {
+		return 0, dbError(err)
+	}
+
+	return id, nil
+}
+
+func (r *SavedFilterPostgres) GetAll(userId int) ([]todo.SavedFilter, error) {
+	filters := make([]todo.SavedFilter, 0)
+
+	query := fmt.Sprintf("SELECT id, name, filter FROM %s WHERE user_id = $1 ORDER BY name, id", filtersTable)
+	err := r.db.Select(&filters, query, userId)
+
+	return filters, dbError(err)
+}
+
+func (r *SavedFilterPostgres) GetById(userId, filterId int) (todo.SavedFilter, error) {
+	var filter todo.SavedFilter
+
+	query := fmt.Sprintf("SELECT id, name, filter FROM %s WHERE user_id = $1 AND id = $2", filtersTable)
+	err := r.db.Get(&filter, query, userId, filterId)
+
+	return filter, dbError(err)
+}
+
+func (r *SavedFilterPostgres) Update(userId, filterId int, input todo.UpdateSavedFilterInput) error {
+	setValues := make([]string, 0)
+	args := make([]interface{}, 0)
+	argId := 1
+
+	if input.Name != nil {
+		setValues = append(setValues, fmt.Sprintf("name=$%d", argId))
+		args = append(args, *input.Name)
+		argId++
+	}
+
+	if input.Filter != nil {
+		setValues = append(setValues, fmt.Sprintf("filter=$%d", argId))
+		args = append(args, *input.Filter)
+		argId++
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE user_id = $%d AND id = $%d", filtersTable, strings.Join(setValues, ", "), argId, argId+1)
+	args = append(args, userId, filterId)
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return checkFilterAffected(result)
+}
+
+func (r *SavedFilterPostgres) Delete(userId, filterId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1 AND id = $2", filtersTable)
+
+	result, err := r.db.Exec(query, userId, filterId)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return checkFilterAffected(result)
+}
+
+// выражение фильтра переводится в условия WHERE, доступ ограничивается
+// списками пользователя так же, как и при выборке задач одного списка
+func (r *SavedFilterPostgres) GetItems(userId int, filter todo.FilterExpression, query todo.PageQuery) ([]todo.TodoItem, string, error) {
+	builder := newPageBuilder("ul.user_id = $1", userId)
+
+	if filter.Done != nil {
+		builder.add("ti.done = $%d", *filter.Done)
+	}
+	if filter.Priority != nil {
+		builder.add("ti.priority = $%d", *filter.Priority)
+	}
+	if len(filter.Labels) > 0 {
+		builder.add("ti.labels @> $%d", pq.StringArray(filter.Labels))
+	}
+	if filter.DueWithinDays != nil {
+		builder.add("ti.due_date < now() + make_interval(days => $%d)", *filter.DueWithinDays)
+	}
+	if len(filter.ListIds) > 0 {
+		builder.add("ti.list_id = ANY($%d)", pq.Array(filter.ListIds))
+	}
+	builder.titleFilter("ti.title", filter.Title)
+
+	// параметры запроса позволяют дополнительно сузить выборку
+	builder.itemFilter(query)
+
+	return selectItemsPage(r.db, builder, query)
+}
+
+// фильтр принадлежит одному пользователю, поэтому запрос без затронутых строк
+// означает, что фильтра нет или он чужой (ErrNotFound)
+func checkFilterAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil || affected > 0 {
+		return dbError(err)
+	}
+
+	return notAffectedError(nil, nil)
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	todo "to-do-list"
+)
+
+// создаем структуру репозитория
+type SearchPostgres struct {
+	db DB
+}
+
+// создаем конструктор репозитория для полнотекстового поиска
+func NewSearchPostgres(db DB) *SearchPostgres {
+	return &SearchPostgres{db: db}
+}
+
+// фрагмент текста с подсвеченными совпадениями. Текст экранируется как HTML до подсветки,
+// поэтому единственная разметка во фрагменте - <mark></mark>, и клиент может вывести его как HTML.
+// Фрагмент строится конфигурацией языка, запрос на котором совпал с текстом:
+// русская конфигурация не подсвечивает английские словоформы
+func searchSnippet(text string) string {
+	escaped := text
+	for _, r := range [][2]string{{"&", "&amp;"}, {"<", "&lt;"}, {">", "&gt;"}, {`"`, "&quot;"}, {"'", "&#39;"}} {
+		escaped = fmt.Sprintf("replace(%s, '%s', '%s')", escaped, strings.ReplaceAll(r[0], "'", "''"), r[1])
+	}
+
+	return fmt.Sprintf(`CASE WHEN to_tsvector('english', %[1]s) @@ q.en
+			THEN ts_headline('english', %[2]s, q.en, $4)
+			ELSE ts_headline('russian', %[2]s, q.ru, $4) END`, text, escaped)
+}
+
+// поиск выполняется по колонкам search_vector, которые поддерживаются триггерами
+// (см. schema/000003_search.up.sql). Запрос разбирается сразу для русского и
+// английского стемминга, результаты ограничены списками пользователя
+// и задачами из этих списков, сортируются по рангу
+func (r *SearchPostgres) Search(userId int, query todo.SearchQuery) ([]todo.SearchResult, error) {
+	results := make([]todo.SearchResult, 0)
+
+	searchQuery := fmt.Sprintf(`WITH q AS (
+			SELECT websearch_to_tsquery('russian', $1) AS ru, websearch_to_tsquery('english', $1) AS en,
+				websearch_to_tsquery('russian', $1) || websearch_to_tsquery('english', $1) AS query
+		)
+		SELECT '%s' AS type, tl.id, tl.id AS list_id, tl.title,
+			%s AS snippet,
+			ts_rank(tl.search_vector, q.query) AS rank
+		FROM %s tl INNER JOIN %s ul on ul.list_id = tl.id, q
+		WHERE ul.user_id = $2 AND tl.search_vector @@ q.query
+		UNION ALL
+		SELECT '%s' AS type, ti.id, ti.list_id, ti.title,
+			%s AS snippet,
+			ts_rank(ti.search_vector, q.query) AS rank
+		FROM %s ti INNER JOIN %s ul on ul.list_id = ti.list_id, q
+		WHERE ul.user_id = $2 AND ti.search_vector @@ q.query
+		ORDER BY rank DESC, type, id
+		LIMIT $3`,
+		todo.SearchTypeList, searchSnippet("tl.title || ' ' || coalesce(tl.description, '')"), todoListsTable, usersListsTable,
+		todo.SearchTypeItem, searchSnippet("ti.title || ' ' || coalesce(ti.description, '')"), todoItemsTable, usersListsTable)
+
+	// совпадения в фрагменте оборачиваются в <mark></mark>
+	headlineOptions := "StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2"
+
+	err := r.db.Select(&results, searchQuery, query.Query, userId, query.Limit, headlineOptions)
+
+	return results, err
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	todo "to-do-list"
+)
+
+// создаем структуру репозитория
+type SyncPostgres struct {
+	db DB
+}
+
+// создаем конструктор репозитория для синхронизации офлайн-клиентов.
+// Колонки sync_txid и field_updated_at списков и задач, а также надгробия
+// заполняются триггерами (schema/000012_sync.up.sql)
+func NewSyncPostgres(db DB) *SyncPostgres {
+	return &SyncPostgres{db: db}
+}
+
+// метод возвращает изменения, доступные пользователю, в транзакциях с id не меньше since,
+// и курсор для следующего запроса. since = 0 - полный снимок без надгробий.
+// Курсор - нижняя граница незавершенных транзакций, полученная до выборки:
+// транзакции с меньшими id уже зафиксированы и попали в выборку,
+// остальные будут выбраны повторно следующим запросом.
+// Запись попадает в выборку при изменении самой записи (в том числе переносе задачи в другой список)
+// или участия пользователя в списке (например, при получении доступа)
+func (r *SyncPostgres) Changes(userId int, since int64) (todo.SyncChanges, int64, error) {
+	changes := todo.SyncChanges{
+		Lists:      make([]todo.TodoList, 0),
+		Items:      make([]todo.SyncItem, 0),
+		Members:    make([]todo.SyncMember, 0),
+		Tombstones: make([]todo.SyncTombstone, 0),
+	}
+
+	var cursor int64
+	if err := r.db.Get(&cursor, "SELECT txid_snapshot_xmin(txid_current_snapshot())"); err != nil {
+		return changes, 0, dbError(err)
+	}
+
+	listsQuery := fmt.Sprintf(`SELECT %s FROM %s tl INNER JOIN %s ul on tl.id = ul.list_id
+		WHERE ul.user_id = $1 AND (tl.sync_txid >= $2 OR ul.sync_txid >= $2) ORDER BY tl.id`,
+		listColumns, todoListsTable, usersListsTable)
+	if err := r.db.Select(&changes.Lists, listsQuery, userId, since); err != nil {
+		return changes, 0, dbError(err)
+	}
+
+	itemsQuery := fmt.Sprintf(`SELECT ti.list_id, %s FROM %s ti INNER JOIN %s ul on ul.list_id = ti.list_id
+		WHERE ul.user_id = $1 AND (ti.sync_txid >= $2 OR ul.sync_txid >= $2) ORDER BY ti.id`,
+		itemColumns, todoItemsTable, usersListsTable)
+	if err := r.db.Select(&changes.Items, itemsQuery, userId, since); err != nil {
+		return changes, 0, dbError(err)
+	}
+
+	membersQuery := fmt.Sprintf(`SELECT m.list_id, m.user_id, u.username, m.role FROM %s ul
+		INNER JOIN %s m on m.list_id = ul.list_id INNER JOIN %s u on u.id = m.user_id
+		WHERE ul.user_id = $1 AND (m.sync_txid >= $2 OR ul.sync_txid >= $2) ORDER BY m.list_id, m.user_id`,
+		usersListsTable, usersListsTable, usersTable)
+	if err := r.db.Select(&changes.Members, membersQuery, userId, since); err != nil {
+		return changes, 0, dbError(err)
+	}
+
+	if since > 0 {
+		tombstonesQuery := fmt.Sprintf(`SELECT entity, entity_id, list_id, deleted_at FROM %s
+			WHERE recipients @> ARRAY[$1::int] AND sync_txid >= $2 ORDER BY id`, syncTombstonesTable)
+		if err := r.db.Select(&changes.Tombstones, tombstonesQuery, userId, since); err != nil {
+			return changes, 0, dbError(err)
+		}
+	}
+
+	return changes, cursor, nil
+}
+
+// удаление надгробий старше before
+func (r *SyncPostgres) DeleteTombstonesBefore(before time.Time) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE deleted_at < $1", syncTombstonesTable)
+
+	_, err := r.db.Exec(query, before)
+
+	return dbError(err)
+}
+
+// метод возвращает id записи на сервере по id клиента, ErrNotFound - запись не создавалась
+func (r *SyncPostgres) ResolveClientId(userId int, entity, clientId string) (int, error) {
+	var id int
+
+	query := fmt.Sprintf("SELECT entity_id FROM %s WHERE user_id = $1 AND entity = $2 AND client_id = $3", syncClientIdsTable)
+	err := r.db.Get(&id, query, userId, entity, clientId)
+
+	return id, dbError(err)
+}
+
+func (r *SyncPostgres) SaveClientId(userId int, entity, clientId string, id int) error {
+	query := fmt.Sprintf("INSERT INTO %s (user_id, entity, client_id, entity_id) VALUES ($1, $2, $3, $4)", syncClientIdsTable)
+
+	_, err := r.db.Exec(query, userId, entity, clientId, id)
+
+	return dbError(err)
+}
+
+// строка времени изменения полей записи
+type fieldTimesRow struct {
+	CreatedAt time.Time `db:"created_at"`
+	Fields    []byte    `db:"field_updated_at"`
+}
+
+func (row fieldTimesRow) fieldTimes() (todo.FieldTimes, error) {
+	times := todo.FieldTimes{CreatedAt: row.CreatedAt}
+	err := json.Unmarshal(row.Fields, &times.Fields)
+
+	return times, err
+}
+
+// метод блокирует список до конца транзакции и возвращает время изменения его полей.
+// Изменять список могут участники с ролью не ниже editor
+func (r *SyncPostgres) ListFieldTimes(userId, listId int) (todo.FieldTimes, error) {
+	role, err := listRole(r.db, userId, listId)
+	if err := requireRole(role, todo.RoleEditor, err); err != nil {
+		return todo.FieldTimes{}, err
+	}
+
+	var row fieldTimesRow
+	query := fmt.Sprintf("SELECT created_at, field_updated_at FROM %s WHERE id = $1 FOR UPDATE", todoListsTable)
+	if err := r.db.Get(&row, query, listId); err != nil {
+		return todo.FieldTimes{}, dbError(err)
+	}
+
+	return row.fieldTimes()
+}
+
+// метод блокирует задачу до конца транзакции и возвращает время изменения её полей.
+// Изменять задачи могут участники списка с ролью не ниже editor
+func (r *SyncPostgres) ItemFieldTimes(userId, itemId int) (todo.FieldTimes, error) {
+	role, err := itemRole(r.db, userId, itemId)
+	if err := requireRole(role, todo.RoleEditor, err); err != nil {
+		return todo.FieldTimes{}, err
+	}
+
+	var row fieldTimesRow
+	query := fmt.Sprintf("SELECT created_at, field_updated_at FROM %s WHERE id = $1 FOR UPDATE", todoItemsTable)
+	if err := r.db.Get(&row, query, itemId); err != nil {
+		return todo.FieldTimes{}, dbError(err)
+	}
+
+	return row.fieldTimes()
+}
+
+// метод записывает время изменения полей, примененных мутацией синхронизации,
+// вместо времени сервера, проставленного триггером
+func (r *SyncPostgres) SetFieldTimes(entity string, id int, fields []string, changedAt time.Time) error {
+	table := todoListsTable
+	if entity == todo.SyncEntityItem {
+		table = todoItemsTable
+	}
+
+	times := make(map[string]time.Time, len(fields))
+	for _, field := range fields {
+		times[field] = changedAt
+	}
+	data, err := json.Marshal(times)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET field_updated_at = field_updated_at || $1::jsonb WHERE id = $2", table)
+	_, err = r.db.Exec(query, string(data), id)
+
+	return dbError(err)
+}
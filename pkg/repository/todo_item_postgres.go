@@ -1,74 +1,105 @@
 package repository
 
 import (
+	"database/sql"
 	"fmt"
 	"strings"
 	todo "to-do-list"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+// колонки задачи для выборок, алиас таблицы задач - ti
+const itemColumns = "ti.id, ti.title, ti.description, ti.done, ti.position, ti.due_date, ti.priority, ti.labels, ti.version, " +
+	"ti.completed_at, ti.created_at, ti.updated_at, ti.created_by, ti.updated_by"
+
+// функция возвращает присваивание completed_at для UPDATE, где done - новое значение done:
+// время проставляется при переводе задачи в выполненные, сохраняется, пока задача выполнена,
+// и сбрасывается при возврате в невыполненные. Колонки без алиаса в SET содержат старые значения
+func setCompletedAt(done string) string {
+	return fmt.Sprintf("completed_at = CASE WHEN NOT %s THEN NULL WHEN done THEN completed_at ELSE now() END", done)
+}
+
 // создаем структуру репозитория
 type TodoItemPostgres struct {
-	db *sqlx.DB
+	db DB
 }
 
 // создаем конструктор репозитория для работы с задачами
-func NewTodoItemPostgres(db *sqlx.DB) *TodoItemPostgres {
+func NewTodoItemPostgres(db DB) *TodoItemPostgres {
 	return &TodoItemPostgres{db: db}
 }
 
-func (r *TodoItemPostgres) CreateItem(listId int, item todo.TodoItem) (int, error) {
-	// создаем транзакцию
-	tx, err := r.db.Begin()
-	if err != nil {
-		return 0, err
-	}
-
-	// создаем запись в todoItemsTable
+// задача принадлежит одному списку, связь хранится в колонке list_id задачи
+// (schema/000018_item_list_id.up.sql)
+func (r *TodoItemPostgres) CreateItem(userId, listId int, item todo.TodoItem) (int, error) {
+	// создаем запись в todoItemsTable, автором создания и изменения становится пользователь
 	var itemId int
-	createItemQuery := fmt.Sprintf("INSERT INTO %s (title, description) VALUES ($1, $2) RETURNING id", todoItemsTable)
-	row := tx.QueryRow(createItemQuery, item.Title, item.Description)
+	createItemQuery := fmt.Sprintf(`INSERT INTO %s (list_id, title, description, position, due_date, priority, labels, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8) RETURNING id`, todoItemsTable)
+	row := r.db.QueryRow(createItemQuery, listId, item.Title, item.Description, item.Position, item.DueDate, item.Priority, item.Labels, userId)
 	if err := row.Scan(&itemId); err != nil {
-		// в случае ошибки останавливаем транзакцию и откатываем изменения
-		tx.Rollback()
-		return 0, err
+		return 0, dbError(err)
 	}
 
-	// создаем запись в listsItemsTable
-	createListItemsQuery := fmt.Sprintf("INSERT INTO %s (list_id, item_id) VALUES ($1, $2)", listsItemsTable)
-	_, err = tx.Exec(createListItemsQuery, listId, itemId)
-	if err != nil {
-		// в случае ошибки останавливаем транзакцию и откатываем изменения
-		tx.Rollback()
-		return 0, err
-	}
+	return itemId, nil
+}
 
-	// применяем изменения к БД и закрываем транзакцию
-	return itemId, tx.Commit()
+// строка выборки задачи вместе с ключом сортировки для курсора
+type itemRow struct {
+	todo.TodoItem
+	SortKey string `db:"sort_key"`
 }
 
-func (r *TodoItemPostgres) GetAllItems(userId, listId int) ([]todo.TodoItem, error) {
-	var items []todo.TodoItem
+func (r *TodoItemPostgres) GetAllItems(userId, listId int, query todo.PageQuery) ([]todo.TodoItem, string, error) {
+	builder := newPageBuilder("ti.list_id = $1 AND ul.user_id = $2", listId, userId)
+	builder.itemFilter(query)
+
+	return selectItemsPage(r.db, builder, query)
+}
+
+// выборка страницы задач из списков пользователя по условиям из builder,
+// используется также для сохраненных фильтров
+func selectItemsPage(db DB, builder *pageBuilder, query todo.PageQuery) ([]todo.TodoItem, string, error) {
+	tail, sortKey, err := builder.page(itemSortColumns, "ti.id", query)
+	if err != nil {
+		return nil, "", dbError(err)
+	}
 
 	// команда INNER JOIN позволяет выбрать только те элементы, которые есть в обеих таблицах
-	// делаем выборку из todoItemsTable, при этом "джойним" listsItemsTable и usersListsTable
-	query := fmt.Sprintf("SELECT ti.id, ti.title, ti.description, ti.done FROM %s ti INNER JOIN %s li on li.item_id = ti.id INNER JOIN %s ul on ul.list_id = li.list_id WHERE li.list_id = $1 AND ul.user_id = $2", todoItemsTable, listsItemsTable, usersListsTable)
+	// делаем выборку из todoItemsTable, при этом "джойним" usersListsTable
+	selectQuery := fmt.Sprintf("SELECT %s, %s FROM %s ti INNER JOIN %s ul on ul.list_id = ti.list_id %s",
+		itemColumns, sortKey, todoItemsTable, usersListsTable, tail)
+
+	var rows []itemRow
+	if err := db.Select(&rows, selectQuery, builder.args...); err != nil {
+		return nil, "", dbError(err)
+	}
 
-	if err := r.db.Select(&items, query, listId, userId); err != nil {
-		return nil, err
+	items := make([]todo.TodoItem, 0, len(rows))
+	for i, row := range rows {
+		if i == query.Limit {
+			break
+		}
+		items = append(items, row.TodoItem)
 	}
 
-	return items, nil
+	var next string
+	if len(rows) > query.Limit {
+		last := rows[query.Limit-1]
+		next = nextCursor(query, last.SortKey, last.Id)
+	}
+
+	return items, next, nil
 }
 
 func (r *TodoItemPostgres) GetItemById(userId, itemId int) (todo.TodoItem, error) {
 	var item todo.TodoItem
 
-	query := fmt.Sprintf("SELECT ti.id, ti.title, ti.description, ti.done FROM %s ti INNER JOIN %s li on li.item_id = ti.id INNER JOIN %s ul on ul.list_id = li.list_id WHERE ti.id = $1 AND ul.user_id = $2", todoItemsTable, listsItemsTable, usersListsTable)
+	query := fmt.Sprintf("SELECT %s FROM %s ti INNER JOIN %s ul on ul.list_id = ti.list_id WHERE ti.id = $1 AND ul.user_id = $2", itemColumns, todoItemsTable, usersListsTable)
 
 	if err := r.db.Get(&item, query, itemId, userId); err != nil {
-		return item, err
+		return item, dbError(err)
 	}
 
 	return item, nil
@@ -99,11 +130,41 @@ func (s *TodoItemPostgres) UpdateItem(userId, itemId int, input todo.UpdateItemI
 	}
 
 	if input.Done != nil {
-		setValues = append(setValues, fmt.Sprintf("done=$%d", argId))
+		setValues = append(setValues, fmt.Sprintf("done=$%d", argId), setCompletedAt(fmt.Sprintf("$%d", argId)))
 		args = append(args, *input.Done)
 		argId++
 	}
 
+	if input.Position != nil {
+		setValues = append(setValues, fmt.Sprintf("position=$%d", argId))
+		args = append(args, *input.Position)
+		argId++
+	}
+
+	if input.DueDate != nil {
+		setValues = append(setValues, fmt.Sprintf("due_date=$%d", argId))
+		args = append(args, *input.DueDate)
+		argId++
+	}
+
+	if input.Priority != nil {
+		setValues = append(setValues, fmt.Sprintf("priority=$%d", argId))
+		args = append(args, *input.Priority)
+		argId++
+	}
+
+	if input.Labels != nil {
+		setValues = append(setValues, fmt.Sprintf("labels=$%d", argId))
+		args = append(args, *input.Labels)
+		argId++
+	}
+
+	// каждое обновление увеличивает версию записи
+	// и запоминает время и автора изменения
+	setValues = append(setValues, "version=ti.version+1", "updated_at=now()", fmt.Sprintf("updated_by=$%d", argId))
+	args = append(args, userId)
+	argId++
+
 	// переменная setValues используются для создания запроса такого вида:
 	// title=$1
 	// description=$1
@@ -111,18 +172,209 @@ func (s *TodoItemPostgres) UpdateItem(userId, itemId int, input todo.UpdateItemI
 	// title=$1, decription=$2, done=$3
 	setQuery := strings.Join(setValues, ", ")
 
-	query := fmt.Sprintf("UPDATE %s ti SET %s FROM %s li, %s ul WHERE ti.id = li.item_id AND li.list_id=ul.list_id AND ul.user_id = $%d AND ti.id=$%d", todoItemsTable, setQuery, listsItemsTable, usersListsTable, argId, argId+1)
-	args = append(args, userId, itemId)
+	// изменять задачи могут участники списка с ролью не ниже editor
+	query := fmt.Sprintf("UPDATE %s ti SET %s FROM %s ul WHERE ti.list_id=ul.list_id AND ul.user_id = $%d AND ti.id=$%d AND ul.role = ANY($%d)", todoItemsTable, setQuery, usersListsTable, argId, argId+1, argId+2)
+	args = append(args, userId, itemId, pq.Array(todo.RolesAllowing(todo.RoleEditor)))
+
+	// при заданной ожидаемой версии обновляем запись только если версия не изменилась
+	if input.Version != nil {
+		query += fmt.Sprintf(" AND ti.version=$%d", argId+3)
+		args = append(args, *input.Version)
+	}
+
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return s.checkAffected(userId, itemId, input.Version, result)
+}
+
+// если запрос не изменил ни одной строки, определяем причину:
+// задача недоступна (ErrNotFound), роль в списке слабее editor (ErrForbidden)
+// или задачу с ожидаемой версией успели изменить (ErrVersionConflict)
+func (r *TodoItemPostgres) checkAffected(userId, itemId int, version *int, result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil || affected > 0 {
+		return dbError(err)
+	}
+
+	role, err := itemRole(r.db, userId, itemId)
+	return notAffectedError(requireRole(role, todo.RoleEditor, err), version)
+}
+
+// удалять задачи могут участники списка с ролью не ниже editor
+// version - ожидаемая версия из заголовка If-Match, nil - удаление без проверки
+func (r *TodoItemPostgres) DeleteItem(userId, itemId int, version *int) error {
+	query := fmt.Sprintf(`DELETE FROM %s ti USING %s ul WHERE ti.list_id = ul.list_id AND ul.user_id = $1 AND ti.id = $2 AND ul.role = ANY($3)`, todoItemsTable, usersListsTable)
+	args := []interface{}{userId, itemId, pq.Array(todo.RolesAllowing(todo.RoleEditor))}
 
-	_, err := s.db.Exec(query, args...)
+	if version != nil {
+		query += " AND ti.version = $4"
+		args = append(args, *version)
+	}
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return r.checkAffected(userId, itemId, version, result)
+}
 
-	return err
+// строка выборки задачи с ролью пользователя в её списке
+type itemAccessRow struct {
+	Id   int    `db:"id"`
+	Role string `db:"role"`
 }
 
-func (r *TodoItemPostgres) DeleteItem(userId, itemId int) error {
-	query := fmt.Sprintf(`DELETE FROM %s ti USING %s li, %s ul WHERE ti.id = li.item_id AND li.list_id = ul.list_id AND ul.user_id = $1 AND ti.id = $2`, todoItemsTable, listsItemsTable, usersListsTable)
+// массовая операция выполняется в одной транзакции:
+// сначала блокируем задачи из набора, доступные пользователю,
+// затем применяем к ним операцию одним запросом.
+// Недоступные и несуществующие задачи получают статус not_found,
+// задачи из списков, где роль пользователя слабее editor - статус forbidden
+func (r *TodoItemPostgres) BulkItems(userId int, input todo.BulkItemsInput) ([]todo.BulkItemResult, error) {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	// при переносе проверяем право добавлять задачи в целевой список в той же транзакции,
+	// что и перенос: роль не может быть отозвана между проверкой и изменением
+	if input.Action == todo.BulkMove {
+		role, err := listRole(tx, userId, input.ListId)
+		if err := requireRole(role, todo.RoleEditor, err); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
 
-	_, err := r.db.Exec(query, userId, itemId)
+	var rows []itemAccessRow
+	selectQuery := fmt.Sprintf("SELECT ti.id, ul.role FROM %s ti INNER JOIN %s ul on ul.list_id = ti.list_id WHERE ul.user_id = $1 AND ti.id = ANY($2) FOR UPDATE OF ti",
+		todoItemsTable, usersListsTable)
+	if err := tx.Select(&rows, selectQuery, userId, pq.Array(input.Ids)); err != nil {
+		tx.Rollback()
+		return nil, dbError(err)
+	}
+
+	statuses := make(map[int]string, len(rows))
+	allowed := make([]int, 0, len(rows))
+	for _, row := range rows {
+		if todo.RoleAllows(row.Role, todo.RoleEditor) {
+			statuses[row.Id] = todo.BulkStatusOk
+			allowed = append(allowed, row.Id)
+		} else {
+			statuses[row.Id] = todo.BulkStatusForbidden
+		}
+	}
+
+	if len(allowed) > 0 {
+		var query string
+		// $2 - автор изменения, $3 - параметр операции
+		args := []interface{}{pq.Array(allowed), userId}
+
+		switch input.Action {
+		case todo.BulkComplete, todo.BulkUncomplete:
+			query = fmt.Sprintf("UPDATE %s SET done = $3, %s, version = version + 1, updated_at = now(), updated_by = $2 WHERE id = ANY($1)",
+				todoItemsTable, setCompletedAt("$3"))
+			args = append(args, input.Action == todo.BulkComplete)
+		case todo.BulkDelete:
+			query = fmt.Sprintf("DELETE FROM %s WHERE id = ANY($1)", todoItemsTable)
+			args = args[:1]
+		case todo.BulkMove:
+			// перенос меняет список задачи, поэтому версия увеличивается:
+			// If-Match с версией до переноса должен получить конфликт.
+			// Имя ресурса CalDAV относится к календарю исходного списка,
+			// в новом списке задача получает имя по умолчанию, которое не может быть занято
+			query = fmt.Sprintf("UPDATE %s SET list_id = $3, ical_name = NULL, version = version + 1, updated_at = now(), updated_by = $2 WHERE id = ANY($1)", todoItemsTable)
+			args = append(args, input.ListId)
+		case todo.BulkLabel:
+			// после добавления у задачи не может оказаться больше MaxLabels меток,
+			// задачи заблокированы выше, поэтому проверка и изменение согласованы
+			var exceeded bool
+			checkQuery := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE id = ANY($1) AND cardinality(ARRAY(SELECT DISTINCT unnest(labels || $2::text[]))) > $3)",
+				todoItemsTable)
+			if err := tx.Get(&exceeded, checkQuery, pq.Array(allowed), pq.StringArray(input.Labels), todo.MaxLabels); err != nil {
+				tx.Rollback()
+				return nil, dbError(err)
+			}
+			if exceeded {
+				tx.Rollback()
+				return nil, todo.NewValidationError("labels", todo.CodeOutOfRange, fmt.Sprintf("at most %d labels are allowed", todo.MaxLabels))
+			}
+
+			// добавляем метки без повторов
+			query = fmt.Sprintf("UPDATE %s SET labels = ARRAY(SELECT DISTINCT unnest(labels || $3::text[])), version = version + 1, updated_at = now(), updated_by = $2 WHERE id = ANY($1)", todoItemsTable)
+			args = append(args, pq.StringArray(input.Labels))
+		case todo.BulkSetDueDate:
+			query = fmt.Sprintf("UPDATE %s SET due_date = $3, version = version + 1, updated_at = now(), updated_by = $2 WHERE id = ANY($1)", todoItemsTable)
+			args = append(args, input.DueDate)
+		}
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			return nil, dbError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, dbError(err)
+	}
+
+	results := make([]todo.BulkItemResult, 0, len(input.Ids))
+	for _, id := range input.Ids {
+		status, ok := statuses[id]
+		if !ok {
+			status = todo.BulkStatusNotFound
+		}
+		results = append(results, todo.BulkItemResult{Id: id, Status: status})
+	}
+
+	return results, nil
+}
+
+// изменение задачи по патчу выполняется атомарно: задача блокируется
+// на время транзакции, apply получает её текущее состояние и возвращает новое.
+// version - ожидаемая версия из заголовка If-Match
+func (r *TodoItemPostgres) PatchItem(userId, itemId int, version *int, apply func(item todo.TodoItem) (todo.TodoItem, error)) (todo.TodoItem, error) {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return todo.TodoItem{}, dbError(err)
+	}
+
+	// изменять задачи могут участники списка с ролью не ниже editor
+	role, err := itemRole(tx, userId, itemId)
+	if err := requireRole(role, todo.RoleEditor, err); err != nil {
+		tx.Rollback()
+		return todo.TodoItem{}, err
+	}
+
+	var item todo.TodoItem
+	query := fmt.Sprintf("SELECT %s FROM %s ti INNER JOIN %s ul on ul.list_id = ti.list_id WHERE ti.id = $1 AND ul.user_id = $2 FOR UPDATE OF ti", itemColumns, todoItemsTable, usersListsTable)
+	if err := tx.Get(&item, query, itemId, userId); err != nil {
+		tx.Rollback()
+		return item, dbError(err)
+	}
+
+	if version != nil && *version != item.Version {
+		tx.Rollback()
+		return item, todo.ErrVersionConflict
+	}
+
+	patched, err := apply(item)
+	if err != nil {
+		tx.Rollback()
+		return item, dbError(err)
+	}
+
+	updateQuery := fmt.Sprintf(`UPDATE %s SET title = $1, description = $2, done = $3, %s, position = $4, due_date = $5, priority = $6, labels = $7,
+		version = version + 1, updated_at = now(), updated_by = $8
+		WHERE id = $9 RETURNING version, completed_at, updated_at, updated_by`, todoItemsTable, setCompletedAt("$3::boolean"))
+	row := tx.QueryRow(updateQuery, patched.Title, patched.Description, patched.Done, patched.Position, patched.DueDate, patched.Priority, patched.Labels, userId, itemId)
+	if err := row.Scan(&patched.Version, &patched.CompletedAt, &patched.UpdatedAt, &patched.UpdatedBy); err != nil {
+		tx.Rollback()
+		return item, dbError(err)
+	}
 
-	return err
+	return patched, tx.Commit()
 }
@@ -1,21 +1,25 @@
 package repository
 
 import (
+	"database/sql"
 	"fmt"
 	"strings"
 	todo "to-do-list"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 )
 
+// колонки списка для выборок, алиас таблицы списков - tl
+const listColumns = "tl.id, tl.title, tl.description, tl.position, tl.version, tl.created_at, tl.updated_at, tl.created_by, tl.updated_by"
+
 // создаем структуру репозитория
 type TodoListPostgres struct {
-	db *sqlx.DB
+	db DB
 }
 
 // создаем конструктор репозитория для работы со списками
-func NewTodoListPostgres(db *sqlx.DB) *TodoListPostgres {
+func NewTodoListPostgres(db DB) *TodoListPostgres {
 	return &TodoListPostgres{db: db}
 }
 
@@ -24,68 +28,120 @@ func NewTodoListPostgres(db *sqlx.DB) *TodoListPostgres {
 // эти операции проводятся в транзакции (последовательность действий, которые должны выполниться полностью)
 func (r *TodoListPostgres) Create(userId int, list todo.TodoList) (int, error) {
 	// создаем транзакцию
-	tx, err := r.db.Begin()
+	tx, err := beginTx(r.db)
 	if err != nil {
-		return 0, err
+		return 0, dbError(err)
 	}
 
-	// создаем запись в todoListsTable
+	// создаем запись в todoListsTable, автором создания и изменения становится пользователь,
+	// время created_at и updated_at проставляется по умолчанию
 	var id int
-	createListQuery := fmt.Sprintf("INSERT INTO %s (title, description) VALUES ($1, $2) RETURNING id", todoListsTable)
-	row := tx.QueryRow(createListQuery, list.Title, list.Description)
+	createListQuery := fmt.Sprintf("INSERT INTO %s (title, description, position, created_by, updated_by) VALUES ($1, $2, $3, $4, $4) RETURNING id", todoListsTable)
+	row := tx.QueryRow(createListQuery, list.Title, list.Description, list.Position, userId)
 	// записваем в переменную id
 	if err := row.Scan(&id); err != nil {
 		// в случае ошибки останавливаем транзакцию и откатываем изменения
 		tx.Rollback()
-		return 0, err
+		return 0, dbError(err)
 	}
 
 	// осуществляем вставку в usersListsTable
-	// связываем id пользователя и id нового списка
-	createUsersListQuery := fmt.Sprintf("INSERT INTO %s (user_id, list_id) VALUES ($1, $2) RETURNING id", usersListsTable)
+	// связываем id пользователя и id нового списка, создатель становится владельцем
+	createUsersListQuery := fmt.Sprintf("INSERT INTO %s (user_id, list_id, role) VALUES ($1, $2, $3) RETURNING id", usersListsTable)
 	// метод Exec не возварщает никакой информации
-	_, err = tx.Exec(createUsersListQuery, userId, id)
+	_, err = tx.Exec(createUsersListQuery, userId, id, todo.RoleOwner)
 	if err != nil {
 		// в случае ошибки останавливаем транзакцию и откатываем изменения
 		tx.Rollback()
-		return 0, err
+		return 0, dbError(err)
 	}
 
 	// применяем изменения к БД и заканчиваем транзакцию
 	return id, tx.Commit()
 }
 
-func (r *TodoListPostgres) GetAll(userId int) ([]todo.TodoList, error) {
-	var lists []todo.TodoList
-	// в $1 будет поподать userId в r.db.Select
+// строка выборки списка вместе с ключом сортировки для курсора
+type listRow struct {
+	todo.TodoList
+	SortKey string `db:"sort_key"`
+}
+
+func (r *TodoListPostgres) GetAll(userId int, query todo.PageQuery) ([]todo.TodoList, string, error) {
+	// в $1 будет поподать userId, далее - параметры фильтров и курсора
+	builder := newPageBuilder("ul.user_id = $1", userId)
+	builder.titleFilter("tl.title", query.Title)
+	builder.auditFilter("tl", query)
+
+	tail, sortKey, err := builder.page(listSortColumns, "tl.id", query)
+	if err != nil {
+		return nil, "", dbError(err)
+	}
+
 	// команда INNER JOIN позволяет выбрать только те элементы, которые есть в обеих таблицах
-	query := fmt.Sprintf("SELECT tl.id, tl.title, tl.description FROM %s tl INNER JOIN %s ul on tl.id = ul.list_id WHERE ul.user_id = $1", todoListsTable, usersListsTable)
+	selectQuery := fmt.Sprintf("SELECT %s, %s FROM %s tl INNER JOIN %s ul on tl.id = ul.list_id %s",
+		listColumns, sortKey, todoListsTable, usersListsTable, tail)
 
-	// записываем в lists результат запроса с помощью метода Select
-	err := r.db.Select(&lists, query, userId)
+	// записываем в rows результат запроса с помощью метода Select
+	var rows []listRow
+	if err := r.db.Select(&rows, selectQuery, builder.args...); err != nil {
+		return nil, "", dbError(err)
+	}
+
+	lists := make([]todo.TodoList, 0, len(rows))
+	for i, row := range rows {
+		if i == query.Limit {
+			break
+		}
+		lists = append(lists, row.TodoList)
+	}
 
-	return lists, err
+	var next string
+	if len(rows) > query.Limit {
+		last := rows[query.Limit-1]
+		next = nextCursor(query, last.SortKey, last.Id)
+	}
+
+	return lists, next, nil
 }
 
 func (r *TodoListPostgres) GetById(userId, listId int) (todo.TodoList, error) {
 	var list todo.TodoList
 
 	// команда INNER JOIN позволяет выбрать только те элементы, которые есть в обеих таблицах
-	query := fmt.Sprintf(`SELECT tl.id, tl.title, tl.description FROM %s tl INNER JOIN %s ul on tl.id = ul.list_id WHERE ul.user_id = $1 AND ul.list_id = $2`, todoListsTable, usersListsTable)
+	query := fmt.Sprintf(`SELECT %s FROM %s tl INNER JOIN %s ul on tl.id = ul.list_id WHERE ul.user_id = $1 AND ul.list_id = $2`, listColumns, todoListsTable, usersListsTable)
 
 	// записываем в list результат запроса с помощью метода Select
 	err := r.db.Get(&list, query, userId, listId)
 
-	return list, err
+	return list, dbError(err)
+}
+
+// метод проверяет доступ пользователя к списку: роль пользователя должна быть не слабее required.
+// Если пользователь не участник списка - ErrNotFound, если роль слабее - ErrForbidden
+func (r *TodoListPostgres) CheckAccess(userId, listId int, required string) error {
+	role, err := listRole(r.db, userId, listId)
+	return requireRole(role, required, err)
 }
 
-func (r *TodoListPostgres) DeleteList(userId, listId int) error {
-	// записи удаляем сразу из 2 таблиц
-	query := fmt.Sprintf(`DELETE FROM %s tl USING %s ul WHERE tl.id = ul.list_id AND ul.user_id = $1 AND ul.list_id = $2`, todoListsTable, usersListsTable)
+// удалить список может только владелец
+// version - ожидаемая версия из заголовка If-Match, nil - удаление без проверки
+func (r *TodoListPostgres) DeleteList(userId, listId int, version *int) error {
+	// записи удаляем сразу из 2 таблиц,
+	// задачи списка удаляются каскадно по внешнему ключу list_id (schema/000018)
+	query := fmt.Sprintf(`DELETE FROM %s tl USING %s ul WHERE tl.id = ul.list_id AND ul.user_id = $1 AND ul.list_id = $2 AND ul.role = ANY($3)`, todoListsTable, usersListsTable)
+	args := []interface{}{userId, listId, pq.Array(todo.RolesAllowing(todo.RoleOwner))}
 
-	_, err := r.db.Exec(query, userId, listId)
+	if version != nil {
+		query += " AND tl.version = $4"
+		args = append(args, *version)
+	}
 
-	return err
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return r.checkAffected(userId, listId, todo.RoleOwner, version, result)
 }
 
 func (r *TodoListPostgres) UpdateList(userId, listId int, input todo.UpdateListInput) error {
@@ -112,19 +168,98 @@ func (r *TodoListPostgres) UpdateList(userId, listId int, input todo.UpdateListI
 		argId++
 	}
 
+	if input.Position != nil {
+		setValues = append(setValues, fmt.Sprintf("position=$%d", argId))
+		args = append(args, *input.Position)
+		argId++
+	}
+
+	// каждое обновление увеличивает версию записи
+	// и запоминает время и автора изменения
+	setValues = append(setValues, "version=tl.version+1", "updated_at=now()", fmt.Sprintf("updated_by=$%d", argId))
+	args = append(args, userId)
+	argId++
+
 	// переменная setValues используются для создания запроса такого вида:
 	// title=$1
 	// description=$1
 	// title=$1, decription=$2
 	setQuery := strings.Join(setValues, ", ")
 
-	query := fmt.Sprintf("UPDATE %s tl SET %s FROM %s ul WHERE tl.id = ul.list_id AND ul.list_id=$%d AND ul.user_id=$%d", todoListsTable, setQuery, usersListsTable, argId, argId+1)
-	args = append(args, listId, userId)
+	// изменять список могут участники с ролью не ниже editor
+	query := fmt.Sprintf("UPDATE %s tl SET %s FROM %s ul WHERE tl.id = ul.list_id AND ul.list_id=$%d AND ul.user_id=$%d AND ul.role = ANY($%d)", todoListsTable, setQuery, usersListsTable, argId, argId+1, argId+2)
+	args = append(args, listId, userId, pq.Array(todo.RolesAllowing(todo.RoleEditor)))
+
+	// при заданной ожидаемой версии обновляем запись только если версия не изменилась
+	if input.Version != nil {
+		query += fmt.Sprintf(" AND tl.version=$%d", argId+3)
+		args = append(args, *input.Version)
+	}
 
 	logrus.Debugf("updateQuery: %s", query)
 	logrus.Debugf("args: %s", args)
 
-	_, err := r.db.Exec(query, args...)
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return r.checkAffected(userId, listId, todo.RoleEditor, input.Version, result)
+}
+
+// если запрос не изменил ни одной строки, определяем причину:
+// список недоступен (ErrNotFound), роль слабее required (ErrForbidden)
+// или список с ожидаемой версией успели изменить (ErrVersionConflict)
+func (r *TodoListPostgres) checkAffected(userId, listId int, required string, version *int, result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil || affected > 0 {
+		return dbError(err)
+	}
+
+	return notAffectedError(r.CheckAccess(userId, listId, required), version)
+}
+
+// изменение списка по патчу выполняется атомарно: список блокируется
+// на время транзакции, apply получает его текущее состояние и возвращает новое.
+// version - ожидаемая версия из заголовка If-Match
+func (r *TodoListPostgres) PatchList(userId, listId int, version *int, apply func(list todo.TodoList) (todo.TodoList, error)) (todo.TodoList, error) {
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return todo.TodoList{}, dbError(err)
+	}
+
+	// изменять список могут участники с ролью не ниже editor
+	role, err := listRole(tx, userId, listId)
+	if err := requireRole(role, todo.RoleEditor, err); err != nil {
+		tx.Rollback()
+		return todo.TodoList{}, err
+	}
+
+	var list todo.TodoList
+	query := fmt.Sprintf(`SELECT %s FROM %s tl INNER JOIN %s ul on tl.id = ul.list_id WHERE ul.user_id = $1 AND ul.list_id = $2 FOR UPDATE OF tl`, listColumns, todoListsTable, usersListsTable)
+	if err := tx.Get(&list, query, userId, listId); err != nil {
+		tx.Rollback()
+		return list, dbError(err)
+	}
+
+	if version != nil && *version != list.Version {
+		tx.Rollback()
+		return list, todo.ErrVersionConflict
+	}
+
+	patched, err := apply(list)
+	if err != nil {
+		tx.Rollback()
+		return list, dbError(err)
+	}
+
+	updateQuery := fmt.Sprintf(`UPDATE %s SET title = $1, description = $2, position = $3, version = version + 1, updated_at = now(), updated_by = $4
+		WHERE id = $5 RETURNING version, updated_at, updated_by`, todoListsTable)
+	row := tx.QueryRow(updateQuery, patched.Title, patched.Description, patched.Position, userId, listId)
+	if err := row.Scan(&patched.Version, &patched.UpdatedAt, &patched.UpdatedBy); err != nil {
+		tx.Rollback()
+		return list, dbError(err)
+	}
 
-	return err
+	return patched, tx.Commit()
 }
@@ -0,0 +1,72 @@
+// в данном файле описывается общий интерфейс подключения к БД и транзакции,
+// а также логика вложенных транзакций
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DB - общий интерфейс для *sqlx.DB и *sqlx.Tx.
+// Репозитории работают через него, поэтому могут выполняться
+// как на подключении к БД, так и внутри внешней транзакции
+type DB interface {
+	sqlx.Ext
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// транзакция репозитория. Если репозиторий уже работает внутри
+// внешней транзакции, новая не создается: Commit и Rollback
+// ничего не делают, фиксацию или откат выполняет владелец внешней транзакции
+type txn struct {
+	*sqlx.Tx
+	nested bool
+}
+
+func (t txn) Commit() error {
+	if t.nested {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+func (t txn) Rollback() error {
+	if t.nested {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+// функция для начала транзакции на подключении к БД или внутри внешней транзакции
+func beginTx(db DB) (txn, error) {
+	if tx, ok := db.(*sqlx.Tx); ok {
+		return txn{Tx: tx, nested: true}, nil
+	}
+
+	tx, err := db.(*sqlx.DB).Beginx()
+	if err != nil {
+		return txn{}, err
+	}
+
+	return txn{Tx: tx}, nil
+}
+
+// функция для начала читающей транзакции с уровнем изоляции REPEATABLE READ.
+// Внутри внешней транзакции используется она сама
+func beginSnapshot(db DB) (txn, error) {
+	if tx, ok := db.(*sqlx.Tx); ok {
+		return txn{Tx: tx, nested: true}, nil
+	}
+
+	tx, err := db.(*sqlx.DB).BeginTxx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return txn{}, err
+	}
+
+	return txn{Tx: tx}, nil
+}
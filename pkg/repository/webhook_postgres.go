@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	todo "to-do-list"
+)
+
+// колонки вебхука без секрета, секрет возвращается только при создании
+const webhookColumns = "id, url, list_id, events, active, failure_count, disabled_at, created_at, updated_at"
+
+// колонки записи журнала доставок
+const deliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+	last_status_code, last_error, created_at, delivered_at`
+
+// доставка, захваченная обработчиком очереди, с адресом и секретом вебхука
+type PendingDelivery struct {
+	Id        int64  `db:"id"`
+	WebhookId int    `db:"webhook_id"`
+	EventType string `db:"event_type"`
+	Payload   []byte `db:"payload"`
+	Attempts  int    `db:"attempts"`
+	Url       string `db:"url"`
+	Secret    string `db:"secret"`
+}
+
+// создаем структуру репозитория
+type WebhookPostgres struct {
+	db DB
+}
+
+// создаем конструктор репозитория для работы с вебхуками и их доставками
+func NewWebhookPostgres(db DB) *WebhookPostgres {
+	return &WebhookPostgres{db: db}
+}
+
+func (r *WebhookPostgres) Create(userId int, webhook todo.Webhook) (int, error) {
+	var id int
+
+	query := fmt.Sprintf("INSERT INTO %s (user_id, list_id, url, secret, events) VALUES ($1, $2, $3, $4, $5) RETURNING id", webhooksTable)
+	row := r.db.QueryRow(query, userId, webhook.ListId, webhook.Url, webhook.Secret, webhook.Events)
+	if err := row.Scan(&id); err != nil {
+		return 0, dbError(err)
+	}
+
+	return id, nil
+}
+
+func (r *WebhookPostgres) GetAll(userId int) ([]todo.Webhook, error) {
+	webhooks := make([]todo.Webhook, 0)
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE user_id = $1 ORDER BY id", webhookColumns, webhooksTable)
+	err := r.db.Select(&webhooks, query, userId)
+
+	return webhooks, dbError(err)
+}
+
+func (r *WebhookPostgres) GetById(userId, webhookId int) (todo.Webhook, error) {
+	var webhook todo.Webhook
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE user_id = $1 AND id = $2", webhookColumns, webhooksTable)
+	err := r.db.Get(&webhook, query, userId, webhookId)
+
+	return webhook, dbError(err)
+}
+
+// включение вебхука (active = true) сбрасывает счетчик неудачных доставок
+func (r *WebhookPostgres) Update(userId, webhookId int, input todo.UpdateWebhookInput) error {
+	setValues := []string{"updated_at=now()"}
+	args := make([]interface{}, 0)
+	argId := 1
+
+	if input.Url != nil {
+		setValues = append(setValues, fmt.Sprintf("url=$%d", argId))
+		args = append(args, *input.Url)
+		argId++
+	}
+
+	if input.Events != nil {
+		setValues = append(setValues, fmt.Sprintf("events=$%d", argId))
+		args = append(args, *input.Events)
+		argId++
+	}
+
+	if input.Active != nil {
+		setValues = append(setValues, fmt.Sprintf("active=$%d", argId))
+		args = append(args, *input.Active)
+		argId++
+
+		if *input.Active {
+			setValues = append(setValues, "failure_count=0", "disabled_at=NULL")
+		} else {
+			setValues = append(setValues, "disabled_at=COALESCE(disabled_at, now())")
+		}
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE user_id = $%d AND id = $%d", webhooksTable, strings.Join(setValues, ", "), argId, argId+1)
+	args = append(args, userId, webhookId)
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return checkWebhookAffected(result)
+}
+
+func (r *WebhookPostgres) Delete(userId, webhookId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1 AND id = $2", webhooksTable)
+
+	result, err := r.db.Exec(query, userId, webhookId)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return checkWebhookAffected(result)
+}
+
+// журнал доставок вебхука от новых к старым,
+// курсор следующей страницы содержит id последней доставки
+func (r *WebhookPostgres) GetDeliveries(userId, webhookId int, query todo.DeliveryQuery) ([]todo.WebhookDelivery, string, error) {
+	if _, err := r.GetById(userId, webhookId); err != nil {
+		return nil, "", err
+	}
+
+	builder := newPageBuilder("webhook_id = $1", webhookId)
+	if query.Status != "" {
+		builder.add("status = $%d", query.Status)
+	}
+	if query.Cursor != "" {
+		c, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		builder.add("id < $%d", c.Id)
+	}
+
+	deliveries := make([]todo.WebhookDelivery, 0, query.Limit+1)
+	selectQuery := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY id DESC LIMIT %d",
+		deliveryColumns, webhookDeliveriesTable, strings.Join(builder.where, " AND "), query.Limit+1)
+	if err := r.db.Select(&deliveries, selectQuery, builder.args...); err != nil {
+		return nil, "", dbError(err)
+	}
+
+	var next string
+	if len(deliveries) > query.Limit {
+		deliveries = deliveries[:query.Limit]
+		next = encodeCursor(cursor{Id: int(deliveries[len(deliveries)-1].Id)})
+	}
+
+	return deliveries, next, nil
+}
+
+// повторная отправка создает новую доставку с тем же телом,
+// исходная запись журнала не меняется
+func (r *WebhookPostgres) Redeliver(userId, webhookId int, deliveryId int64) (todo.WebhookDelivery, error) {
+	var delivery todo.WebhookDelivery
+
+	query := fmt.Sprintf(`INSERT INTO %[1]s (webhook_id, event_id, event_type, payload)
+		SELECT d.webhook_id, d.event_id, d.event_type, d.payload FROM %[1]s d
+		INNER JOIN %[2]s w on w.id = d.webhook_id
+		WHERE w.user_id = $1 AND d.webhook_id = $2 AND d.id = $3
+		RETURNING %[3]s`, webhookDeliveriesTable, webhooksTable, deliveryColumns)
+	err := r.db.Get(&delivery, query, userId, webhookId, deliveryId)
+
+	return delivery, dbError(err)
+}
+
+// метод захватывает до limit доставок, время отправки которых наступило.
+// next_attempt_at сдвигается на время аренды: если экземпляр упадет во время отправки,
+// доставку повторит другой экземпляр. SKIP LOCKED не дает двум экземплярам захватить одну доставку.
+// Доставки отключенных вебхуков не отправляются и остаются в очереди до включения вебхука
+func (r *WebhookPostgres) ClaimDeliveries(limit int, lease time.Duration) ([]PendingDelivery, error) {
+	deliveries := make([]PendingDelivery, 0, limit)
+
+	query := fmt.Sprintf(`UPDATE %[1]s d SET next_attempt_at = now() + make_interval(secs => $2)
+		FROM %[2]s w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT pd.id FROM %[1]s pd INNER JOIN %[2]s pw on pw.id = pd.webhook_id
+			WHERE pd.status = '%[3]s' AND pd.next_attempt_at <= now() AND pw.active
+			ORDER BY pd.next_attempt_at, pd.id LIMIT $1
+			FOR UPDATE OF pd SKIP LOCKED
+		)
+		RETURNING d.id, d.webhook_id, d.event_type, d.payload, d.attempts, w.url, w.secret`,
+		webhookDeliveriesTable, webhooksTable, todo.DeliveryPending)
+	err := r.db.Select(&deliveries, query, limit, lease.Seconds())
+
+	return deliveries, dbError(err)
+}
+
+// успешная доставка: получатель ответил 2xx, счетчик неудач вебхука сбрасывается
+func (r *WebhookPostgres) CompleteDelivery(id int64, statusCode int) error {
+	query := fmt.Sprintf(`WITH d AS (
+			UPDATE %s SET status = '%s', attempts = attempts + 1, last_status_code = $2, last_error = NULL,
+				next_attempt_at = NULL, delivered_at = now()
+			WHERE id = $1 RETURNING webhook_id
+		)
+		UPDATE %s SET failure_count = 0 WHERE id = (SELECT webhook_id FROM d)`,
+		webhookDeliveriesTable, todo.DeliverySucceeded, webhooksTable)
+
+	_, err := r.db.Exec(query, id, statusCode)
+
+	return dbError(err)
+}
+
+// неудачная доставка: retryAt - время следующей попытки, nil - попытки исчерпаны.
+// Вебхук отключается, если число неудач подряд достигло disableAfter,
+// тогда ожидающие доставки вебхука отменяются (помечаются failed):
+// после повторного включения они не отправляются разом
+func (r *WebhookPostgres) FailDelivery(id int64, statusCode *int, message string, retryAt *time.Time, disableAfter int) error {
+	status := todo.DeliveryPending
+	if retryAt == nil {
+		status = todo.DeliveryFailed
+	}
+
+	tx, err := beginTx(r.db)
+	if err != nil {
+		return dbError(err)
+	}
+
+	// disabled - вебхук отключен этим запросом: disabled_at совпадает со временем транзакции
+	var webhook struct {
+		Id       int  `db:"id"`
+		Disabled bool `db:"disabled"`
+	}
+	query := fmt.Sprintf(`WITH d AS (
+			UPDATE %s SET status = $2, attempts = attempts + 1, last_status_code = $3, last_error = $4, next_attempt_at = $5
+			WHERE id = $1 RETURNING webhook_id
+		)
+		UPDATE %s SET failure_count = failure_count + 1,
+			active = active AND failure_count + 1 < $6,
+			disabled_at = CASE WHEN active AND failure_count + 1 >= $6 THEN now() ELSE disabled_at END
+		WHERE id = (SELECT webhook_id FROM d)
+		RETURNING id, NOT active AND disabled_at = now() AS disabled`,
+		webhookDeliveriesTable, webhooksTable)
+
+	err = tx.Get(&webhook, query, id, status, statusCode, message, retryAt, disableAfter)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && !webhook.Disabled {
+		return dbError(tx.Commit())
+	}
+	if err != nil {
+		tx.Rollback()
+		return dbError(err)
+	}
+
+	cancelQuery := fmt.Sprintf(`UPDATE %s SET status = '%s', next_attempt_at = NULL,
+			last_error = COALESCE(last_error, 'webhook disabled after consecutive failures')
+		WHERE webhook_id = $1 AND status = '%s'`,
+		webhookDeliveriesTable, todo.DeliveryFailed, todo.DeliveryPending)
+	if _, err := tx.Exec(cancelQuery, webhook.Id); err != nil {
+		tx.Rollback()
+		return dbError(err)
+	}
+
+	return dbError(tx.Commit())
+}
+
+// вебхук принадлежит одному пользователю, поэтому запрос без затронутых строк
+// означает, что вебхука нет или он чужой (ErrNotFound)
+func checkWebhookAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil || affected > 0 {
+		return dbError(err)
+	}
+
+	return notAffectedError(nil, nil)
+}
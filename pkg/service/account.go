@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+)
+
+// Методы сервиса вызывают соответствующие методы из модуля repository,
+// передаем данные на уровень ниже.
+// Сервис назначает и отменяет удаление аккаунта, само удаление
+// после отсрочки выполняет account_deleter.go
+
+// отсрочка удаления, если в конфиге не задано accounts.deletion_grace_period
+const defaultDeletionGracePeriod = 30 * 24 * time.Hour
+
+// gracePeriod - время, в течение которого удаление аккаунта можно отменить
+type AccountService struct {
+	repos       *repository.Repository
+	gracePeriod time.Duration
+}
+
+// конструктор для создания сервиса удаления аккаунтов
+func NewAccountService(repos *repository.Repository, gracePeriod time.Duration) *AccountService {
+	if gracePeriod <= 0 {
+		gracePeriod = defaultDeletionGracePeriod
+	}
+	return &AccountService{repos: repos, gracePeriod: gracePeriod}
+}
+
+func (s *AccountService) GetDeletion(userId int) (todo.AccountDeletion, error) {
+	return s.repos.Account.GetDeletion(userId)
+}
+
+// удаление подтверждается паролем, чтобы аккаунт нельзя было удалить
+// одним лишь украденным токеном
+func (s *AccountService) ScheduleDeletion(userId int, input todo.DeleteAccountInput) (todo.AccountDeletion, error) {
+	var deletion todo.AccountDeletion
+
+	err := s.repos.Transaction(func(repos *repository.Repository) error {
+		var err error
+		deletion, err = repos.Account.ScheduleDeletion(userId, generatePasswordHash(input.Password), s.gracePeriod)
+		if errors.Is(err, todo.ErrNotFound) {
+			return fmt.Errorf("%w: invalid password", todo.ErrForbidden)
+		}
+		if err != nil {
+			return err
+		}
+
+		return repos.AuditLog.Record(userId, todo.AuditDeletionRequested, deletion)
+	})
+
+	return deletion, err
+}
+
+func (s *AccountService) CancelDeletion(userId int) error {
+	return s.repos.Transaction(func(repos *repository.Repository) error {
+		if err := repos.Account.CancelDeletion(userId); err != nil {
+			return err
+		}
+
+		return repos.AuditLog.Record(userId, todo.AuditDeletionCancelled, struct{}{})
+	})
+}
+
+// функция удаляет аккаунт: передает общие списки другим участникам,
+// удаляет списки, в которых других участников нет, и самого пользователя
+func deleteAccount(repos *repository.Repository, userId int) (todo.AccountDeletionReport, error) {
+	var report todo.AccountDeletionReport
+
+	transferred, err := repos.Account.TransferLists(userId)
+	if err != nil {
+		return report, err
+	}
+
+	report.ListsDeleted, report.ItemsDeleted, err = repos.Account.DeleteSoleLists(userId)
+	if err != nil {
+		return report, err
+	}
+
+	// остались списки с другими участниками. Событие публикуется до удаления,
+	// пока пользователь участник, как и при исключении участника
+	lists, err := repos.Account.GetMemberLists(userId)
+	if err != nil {
+		return report, err
+	}
+	for _, listId := range lists {
+		if err := repos.Event.Publish(todo.NewListEvent(todo.EventListMembers, userId, listId)); err != nil {
+			return report, err
+		}
+	}
+
+	report.ListsTransferred = len(transferred)
+	report.MembershipsLeft = len(lists) - len(transferred)
+
+	return report, repos.Account.DeleteUser(userId)
+}
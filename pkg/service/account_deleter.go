@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Обработчик удаляет аккаунты, отсрочка удаления которых истекла.
+// Каждый аккаунт удаляется в своей транзакции вместе с записью в журнал аудита,
+// строка пользователя блокируется, поэтому экземпляры приложения
+// не удаляют один аккаунт одновременно
+
+// значение по умолчанию для параметра из секции accounts конфига
+const defaultDeletionPollInterval = time.Minute
+
+// параметры обработчика: PollInterval - интервал проверки аккаунтов к удалению
+type AccountDeletionConfig struct {
+	PollInterval time.Duration
+}
+
+type AccountDeleter struct {
+	repos *repository.Repository
+	cfg   AccountDeletionConfig
+}
+
+// конструктор обработчика, незаданные параметры заменяются значениями по умолчанию
+func NewAccountDeleter(repos *repository.Repository, cfg AccountDeletionConfig) *AccountDeleter {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultDeletionPollInterval
+	}
+
+	return &AccountDeleter{repos: repos, cfg: cfg}
+}
+
+// метод запускает удаление аккаунтов, работает до отмены ctx
+func (d *AccountDeleter) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.deleteDue(ctx)
+		}
+	}
+}
+
+// удаление аккаунтов по одному, пока есть аккаунты к удалению
+func (d *AccountDeleter) deleteDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		deleted, err := d.deleteNext()
+		if err != nil {
+			logrus.Errorf("accounts delete: %s", err.Error())
+			return
+		}
+		if !deleted {
+			return
+		}
+	}
+}
+
+// метод удаляет один аккаунт, false - аккаунтов к удалению нет
+func (d *AccountDeleter) deleteNext() (bool, error) {
+	deleted := false
+
+	err := d.repos.Transaction(func(repos *repository.Repository) error {
+		userId, err := repos.Account.LockDueDeletion()
+		if errors.Is(err, todo.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		report, err := deleteAccount(repos, userId)
+		if err != nil {
+			return err
+		}
+		if err := repos.AuditLog.Record(userId, todo.AuditAccountDeleted, report); err != nil {
+			return err
+		}
+
+		deleted = true
+		logrus.Infof("account %d deleted: %d lists deleted, %d lists transferred", userId, report.ListsDeleted, report.ListsTransferred)
+		return nil
+	})
+
+	return deleted && err == nil, err
+}
@@ -22,10 +22,19 @@ const (
 	signingKey = "dfa3fef4breg43f43"
 )
 
-// объявим структуру для настройки генерации токена с UserId
+// токен потока событий передается в адресе запроса (EventSource в браузере
+// не отправляет заголовки), поэтому он живет недолго и годится только для /api/stream
+const (
+	StreamTokenTTL = time.Minute
+	streamScope    = "stream"
+)
+
+// объявим структуру для настройки генерации токена с UserId,
+// scope пустой у основного токена и "stream" у токена потока событий
 type tokenClaims struct {
 	jwt.StandardClaims
-	UserId int `json:"user_id"`
+	UserId int    `json:"user_id"`
+	Scope  string `json:"scope,omitempty"`
 }
 
 // описываем структуру сервиса авторизации, котороя принимает в контструктор
@@ -42,17 +51,41 @@ func NewAuthService(repo repository.Authorization) *AuthService {
 // имплементируем метод создания пользователя
 // здесь данные будут передаваться на слой ниже, в repository
 func (s *AuthService) CreateUser(user todo.User) (int, error) {
+	// валидируем и нормализуем данные пользователя
+	if err := user.Validate(); err != nil {
+		return 0, err
+	}
+
 	// хэшируем пароль
 	user.Password = generatePasswordHash(user.Password)
 	// вызываем метод из модуля repository
-	return s.repo.CreateUser(user)
+	id, err := s.repo.CreateUser(user)
+	if errors.Is(err, todo.ErrConflict) {
+		return 0, fmt.Errorf("%w: username is already taken", todo.ErrConflict)
+	}
+	return id, err
 }
 
-// публичные метод для генерация токена
-func (s *AuthService) GenerateToken(username, password string) (string, error) {
+// метод проверки учетных данных, возвращает id пользователя.
+// Используется при генерации токена и для Basic-авторизации клиентов CalDAV
+func (s *AuthService) Authenticate(username, password string) (int, error) {
 	// получаем юзера из БД, использую метод из repository
 	// так как пароль храниться в хэшированном виде, передаем его с помощью generatePasswordHash
 	user, err := s.repo.GetUser(username, generatePasswordHash(password))
+	if err != nil {
+		// отсутствие пользователя означает неверные учетные данные
+		if errors.Is(err, todo.ErrNotFound) {
+			return 0, fmt.Errorf("%w: invalid username or password", todo.ErrUnauthorized)
+		}
+		return 0, err
+	}
+
+	return user.Id, nil
+}
+
+// публичные метод для генерация токена
+func (s *AuthService) GenerateToken(username, password string) (string, error) {
+	userId, err := s.Authenticate(username, password)
 	if err != nil {
 		return "", err
 	}
@@ -62,16 +95,26 @@ func (s *AuthService) GenerateToken(username, password string) (string, error) {
 	// второй аргумент - json с различными полями,
 	// стандартные настройки: время жизни токена (ExpiresAt),
 	// время, когда токен был сгенерирован (IssuedAt),
-	// а также user.Id (токен будет содержать его внутри себя)
+	// а также id пользователя (токен будет содержать его внутри себя)
+	return signToken(userId, "", tokenTTL)
+}
+
+// метод генерации токена потока событий для авторизованного пользователя
+func (s *AuthService) GenerateStreamToken(userId int) (string, error) {
+	return signToken(userId, streamScope, StreamTokenTTL)
+}
+
+// функция возвращает подписанный с помощью ключа токен
+func signToken(userId int, scope string, ttl time.Duration) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &tokenClaims{
 		jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(tokenTTL).Unix(),
+			ExpiresAt: time.Now().Add(ttl).Unix(),
 			IssuedAt:  time.Now().Unix(),
 		},
-		user.Id,
+		userId,
+		scope,
 	})
 
-	// возвращаем подписанный с помощью ключа токен
 	return token.SignedString([]byte(signingKey))
 }
 
@@ -83,8 +126,18 @@ func generatePasswordHash(password string) string {
 	return fmt.Sprintf("%x", hash.Sum([]byte(salt)))
 }
 
-// метод для парсинга токена и получения id, используется в хендлере middleware.go
+// метод для парсинга токена и получения id, используется в хендлере middleware.go.
+// Токен потока событий вместо основного не принимается
 func (s *AuthService) ParseToken(accessToken string) (int, error) {
+	return parseToken(accessToken, "")
+}
+
+// метод для парсинга токена потока событий
+func (s *AuthService) ParseStreamToken(streamToken string) (int, error) {
+	return parseToken(streamToken, streamScope)
+}
+
+func parseToken(accessToken, scope string) (int, error) {
 	// используем ParseWithClaims пакета jwt
 	token, err := jwt.ParseWithClaims(accessToken, &tokenClaims{}, func(token *jwt.Token) (interface{}, error) {
 		// проверяем  метод подписи токена
@@ -96,14 +149,18 @@ func (s *AuthService) ParseToken(accessToken string) (int, error) {
 		return []byte(signingKey), nil
 	})
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("%w: %s", todo.ErrUnauthorized, err.Error())
 	}
 
 	// приведем поле Claims объекта token к структуре tokenClaims
 	claims, ok := token.Claims.(*tokenClaims)
 
 	if !ok {
-		return 0, errors.New(("token claims are not of type"))
+		return 0, fmt.Errorf("%w: token claims are not of type", todo.ErrUnauthorized)
+	}
+
+	if claims.Scope != scope {
+		return 0, fmt.Errorf("%w: token is not valid for this request", todo.ErrUnauthorized)
 	}
 
 	// возвращаем UserId
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+)
+
+// Сервис календарей CalDAV и лент iCalendar, модель описана в calendar.go корневого пакета.
+// Задачи изменяются через сервис задач, поэтому для них проверяются права
+// и публикуются события, как для запросов api
+
+// длина токена ленты в байтах, в ссылке - base64 без выравнивания
+const calendarFeedTokenBytes = 32
+
+type CalendarService struct {
+	repos *repository.Repository
+}
+
+// конструктор для создания сервиса календарей
+func NewCalendarService(repos *repository.Repository) *CalendarService {
+	return &CalendarService{repos: repos}
+}
+
+// метод создает ссылку на ленту списка и возвращает её токен,
+// предыдущая ссылка пользователя на этот список перестает действовать.
+// Ссылку может получить любой участник списка
+func (s *CalendarService) CreateFeed(userId, listId int) (string, error) {
+	if err := s.repos.TodoList.CheckAccess(userId, listId, todo.RoleViewer); err != nil {
+		return "", err
+	}
+
+	data := make([]byte, calendarFeedTokenBytes)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(data)
+
+	if err := s.repos.Calendar.SaveFeed(userId, listId, hashFeedToken(token)); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *CalendarService) DeleteFeed(userId, listId int) error {
+	return s.repos.Calendar.DeleteFeed(userId, listId)
+}
+
+// метод возвращает календарь и задачи по токену ленты,
+// неизвестный токен и токен участника, исключенного из списка, - ErrNotFound
+func (s *CalendarService) GetFeed(token string) (todo.Calendar, []todo.CalendarItem, error) {
+	userId, listId, err := s.repos.Calendar.GetFeed(hashFeedToken(token))
+	if err != nil {
+		return todo.Calendar{}, nil, err
+	}
+
+	calendar, err := s.repos.Calendar.GetCalendar(userId, listId)
+	if err != nil {
+		return calendar, nil, err
+	}
+
+	items, err := s.repos.Calendar.GetItems(userId, listId)
+
+	return calendar, items, err
+}
+
+func (s *CalendarService) GetCalendars(userId int) ([]todo.Calendar, error) {
+	return s.repos.Calendar.GetCalendars(userId)
+}
+
+func (s *CalendarService) GetCalendar(userId, listId int) (todo.Calendar, error) {
+	return s.repos.Calendar.GetCalendar(userId, listId)
+}
+
+func (s *CalendarService) GetItems(userId, listId int) ([]todo.CalendarItem, error) {
+	return s.repos.Calendar.GetItems(userId, listId)
+}
+
+func (s *CalendarService) GetItem(userId, listId int, name string) (todo.CalendarItem, error) {
+	return s.repos.Calendar.GetItem(userId, listId, name)
+}
+
+// метод сохраняет задачу календаря: создает её, если ресурса с таким именем в списке нет,
+// иначе заменяет поля задачи. Позиция задачи в списке при замене не меняется.
+// Возвращает сохраненную задачу и признак создания
+func (s *CalendarService) PutItem(userId, listId int, input todo.PutCalendarItemInput) (todo.CalendarItem, bool, error) {
+	if err := input.Validate(); err != nil {
+		return todo.CalendarItem{}, false, err
+	}
+
+	var item todo.CalendarItem
+	created := false
+	err := s.repos.Transaction(func(repos *repository.Repository) error {
+		existing, err := repos.Calendar.GetItem(userId, listId, input.Name)
+		switch {
+		case errors.Is(err, todo.ErrNotFound):
+			if input.IfExists || input.Version != nil {
+				return todo.ErrVersionConflict
+			}
+			created = true
+			return createCalendarItem(repos, userId, listId, input)
+		case err != nil:
+			return err
+		case input.IfNotExists:
+			return todo.ErrVersionConflict
+		}
+
+		return replaceCalendarItem(repos, userId, existing.Id, input)
+	})
+	if err != nil {
+		return item, false, err
+	}
+
+	item, err = s.repos.Calendar.GetItem(userId, listId, input.Name)
+
+	return item, created, err
+}
+
+// создание задачи через сервис задач, выполненная задача из VTODO сразу же отмечается выполненной.
+// Имена по умолчанию зарезервированы за задачами, созданными не через CalDAV
+func createCalendarItem(repos *repository.Repository, userId, listId int, input todo.PutCalendarItemInput) error {
+	if todo.IsDefaultCalendarName(input.Name) {
+		return todo.NewValidationError("name", todo.CodeInvalid, "names todo-item-<id>.ics are reserved")
+	}
+
+	done, _ := strconv.ParseBool(input.Item.Done)
+	id, err := newTodoItemService(repos).createWithDone(userId, listId, input.Item.TodoItem, done)
+	if err != nil {
+		return err
+	}
+
+	return repos.Calendar.SetItemRef(id, input.Item.Uid, input.Name)
+}
+
+// замена полей задачи значениями из VTODO с проверкой ожидаемой версии
+func replaceCalendarItem(repos *repository.Repository, userId, itemId int, input todo.PutCalendarItemInput) error {
+	before, err := repos.Event.ItemStates([]int{itemId})
+	if err != nil {
+		return err
+	}
+
+	_, err = repos.TodoItem.PatchItem(userId, itemId, input.Version, func(item todo.TodoItem) (todo.TodoItem, error) {
+		item.Title = input.Item.Title
+		item.Description = input.Item.Description
+		item.Done = input.Item.Done
+		item.DueDate = input.Item.DueDate
+		item.Priority = input.Item.Priority
+		item.Labels = input.Item.Labels
+		return item, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := publishUpdatedItem(repos, userId, itemId, before); err != nil {
+		return err
+	}
+
+	return repos.Calendar.SetItemRef(itemId, input.Item.Uid, input.Name)
+}
+
+// удаление задачи календаря по имени ресурса, version - ожидаемая версия из If-Match
+func (s *CalendarService) DeleteItem(userId, listId int, name string, version *int) error {
+	return s.repos.Transaction(func(repos *repository.Repository) error {
+		item, err := repos.Calendar.GetItem(userId, listId, name)
+		if err != nil {
+			return err
+		}
+
+		return newTodoItemService(repos).DeleteItem(userId, item.Id, version)
+	})
+}
+
+// в БД хранится только хэш токена ленты
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Брокер рассылает события подписчикам потока /api/stream текущего экземпляра приложения.
+// О новых событиях брокер узнает через LISTEN/NOTIFY, поэтому подписчики
+// получают изменения, выполненные на любом экземпляре.
+// По уведомлению брокер выбирает все события, транзакции которых не были видны
+// в снимке предыдущей выборки: id событий выделяются до фиксации, поэтому рассылка по id
+// пропускала бы события, зафиксированные позже событий с большими id.
+// Уведомления, потерянные при переподключении к БД, не теряют событий - их выберет следующая выборка.
+// Если подписчик не успевает читать события или выборка не удалась,
+// подписка закрывается: клиент переподключается с Last-Event-ID и получает пропущенное из журнала
+
+const (
+	// время хранения событий, если в конфиге не задано events.retention
+	defaultEventRetention = 24 * time.Hour
+	// размер буфера событий подписчика
+	subscriptionBuffer = 64
+	// интервал удаления устаревших событий
+	eventCleanupInterval = time.Hour
+)
+
+// подписка пользователя на события
+type Subscription struct {
+	userId int
+	events chan todo.StreamEvent
+}
+
+// канал событий подписки, закрывается при завершении подписки брокером
+func (s *Subscription) Events() <-chan todo.StreamEvent {
+	return s.events
+}
+
+// snapshot - снимок транзакций последней выборки, события видимых в нем транзакций разосланы,
+// используется только горутиной Run
+type EventBroker struct {
+	repo      repository.Event
+	listener  repository.EventListener
+	retention time.Duration
+	snapshot  string
+
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// конструктор брокера, retention - время хранения событий для повтора
+func NewEventBroker(repo repository.Event, listener repository.EventListener, retention time.Duration) *EventBroker {
+	if retention <= 0 {
+		retention = defaultEventRetention
+	}
+
+	return &EventBroker{
+		repo:      repo,
+		listener:  listener,
+		retention: retention,
+		subs:      make(map[*Subscription]struct{}),
+	}
+}
+
+// метод запускает рассылку событий, работает до отмены ctx.
+// После завершения все подписки закрываются
+func (b *EventBroker) Run(ctx context.Context) {
+	cleanup := time.NewTicker(eventCleanupInterval)
+	defer cleanup.Stop()
+	defer b.closeAll()
+
+	// события, зафиксированные до запуска, подписчики получают повтором из журнала
+	snapshot, err := b.repo.Snapshot()
+	if err != nil {
+		logrus.Errorf("events snapshot: %s", err.Error())
+	}
+	b.snapshot = snapshot
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := b.listener.Close(); err != nil {
+				logrus.Errorf("events listener close: %s", err.Error())
+			}
+			return
+		case _, ok := <-b.listener.Notifications():
+			if !ok {
+				return
+			}
+			// id события из уведомления не используется: выборка по снимку
+			// возвращает и события, уведомления о которых потеряны при переподключении (id 0),
+			// поэтому накопившиеся уведомления обрабатываются одной выборкой
+			if !b.drain() {
+				return
+			}
+			b.deliver()
+		case <-cleanup.C:
+			if err := b.repo.DeleteBefore(time.Now().Add(-b.retention)); err != nil {
+				logrus.Errorf("events cleanup: %s", err.Error())
+			}
+		}
+	}
+}
+
+// метод пропускает уже полученные уведомления, false - подписка закрыта
+func (b *EventBroker) drain() bool {
+	for {
+		select {
+		case _, ok := <-b.listener.Notifications():
+			if !ok {
+				return false
+			}
+		default:
+			return true
+		}
+	}
+}
+
+func (b *EventBroker) deliver() {
+	// снимок не был получен при запуске: события до текущего момента
+	// подписчики получат повтором после переподключения
+	if b.snapshot == "" {
+		snapshot, err := b.repo.Snapshot()
+		if err != nil {
+			logrus.Errorf("events snapshot: %s", err.Error())
+			return
+		}
+		b.snapshot = snapshot
+		b.closeAll()
+		return
+	}
+
+	events, snapshot, err := b.repo.GetSince(b.snapshot)
+	if err != nil {
+		logrus.Errorf("events delivery: %s", err.Error())
+		b.closeAll()
+		return
+	}
+	previous := b.snapshot
+	b.snapshot = snapshot
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// курсор нового снимка получает только последнее событие подписчика в выборке:
+	// если клиент отключится раньше, он повторит выборку с предыдущего снимка
+	last := make(map[*Subscription]int, len(b.subs))
+	for i, event := range events {
+		for sub := range b.subs {
+			if event.SentTo(sub.userId) {
+				last[sub] = i
+			}
+		}
+	}
+
+	for i, event := range events {
+		for sub := range b.subs {
+			if !event.SentTo(sub.userId) {
+				continue
+			}
+
+			cursor := previous
+			if last[sub] == i {
+				cursor = snapshot
+			}
+
+			select {
+			case sub.events <- todo.StreamEvent{Event: event, Cursor: cursor}:
+			default:
+				// подписчик не успевает читать события
+				b.remove(sub)
+			}
+		}
+	}
+}
+
+func (b *EventBroker) Subscribe(userId int) *Subscription {
+	sub := &Subscription{userId: userId, events: make(chan todo.StreamEvent, subscriptionBuffer)}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+func (b *EventBroker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	b.remove(sub)
+	b.mu.Unlock()
+}
+
+// события пользователя для повтора при переподключении: не видимые в снимке cursor
+// (или после id afterId для курсора прежнего формата), постранично по id после afterId.
+// Возвращает также текущий снимок транзакций
+func (b *EventBroker) Replay(userId int, cursor string, afterId int64, limit int) ([]todo.Event, string, error) {
+	return b.repo.GetAfter(userId, cursor, afterId, limit)
+}
+
+// удаление подписки, вызывается под b.mu
+func (b *EventBroker) remove(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.events)
+	}
+}
+
+func (b *EventBroker) closeAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		b.remove(sub)
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+)
+
+// Методы сервиса вызывают соответствующие методы из модуля repository,
+// передаем данные на уровень ниже
+
+// получатель выгрузки: форматы выгрузки реализованы в handler/export.go.
+// Методы вызываются по порядку: список, затем его задачи, затем следующий список
+type ExportWriter interface {
+	WriteList(list todo.ExportList) error
+	WriteItem(item todo.TodoItem) error
+}
+
+// структура сервиса выгрузки данных пользователя
+type ExportService struct {
+	repos *repository.Repository
+}
+
+// конструктор для создания сервиса выгрузки
+func NewExportService(repos *repository.Repository) *ExportService {
+	return &ExportService{repos: repos}
+}
+
+// метод передает получателю все списки пользователя и их задачи.
+// Выгрузка читается в одной транзакции, поэтому согласована,
+// даже если данные меняются во время выгрузки
+func (s *ExportService) Export(userId int, w ExportWriter) error {
+	return s.repos.Snapshot(func(repos *repository.Repository) error {
+		lists, err := repos.Export.GetLists(userId)
+		if err != nil {
+			return err
+		}
+
+		for _, list := range lists {
+			if err := w.WriteList(list); err != nil {
+				return err
+			}
+			if err := repos.Export.StreamItems(userId, list.Id, w.WriteItem); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"time"
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+)
+
+// Методы сервиса вызывают соответствующие методы из модуля repository,
+// передаем данные на уровень ниже
+
+// время хранения ответа и аренды ключа, если в конфиге не заданы idempotency.ttl и idempotency.lease
+const (
+	defaultIdempotencyTTL   = 24 * time.Hour
+	defaultIdempotencyLease = time.Minute
+)
+
+// ttl - время хранения ответа для ключа идемпотентности,
+// lease - время аренды ключа запросом, который еще выполняется:
+// если экземпляр упал, не сохранив ответ, ключ можно занять повторно по истечении аренды
+type IdempotencyService struct {
+	repo  repository.Idempotency
+	ttl   time.Duration
+	lease time.Duration
+}
+
+// конструктор для создания сервиса ключей идемпотентности
+func NewIdempotencyService(repo repository.Idempotency, ttl, lease time.Duration) *IdempotencyService {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	if lease <= 0 {
+		lease = defaultIdempotencyLease
+	}
+	return &IdempotencyService{repo: repo, ttl: ttl, lease: lease}
+}
+
+func (s *IdempotencyService) Begin(userId int, key, requestHash string) (todo.IdempotencyRecord, bool, error) {
+	return s.repo.Begin(userId, key, requestHash, s.ttl, s.lease)
+}
+
+func (s *IdempotencyService) Complete(userId int, key string, startedAt time.Time, statusCode int, body []byte, headers todo.ResponseHeaders) error {
+	return s.repo.Complete(userId, key, startedAt, statusCode, body, headers)
+}
+
+func (s *IdempotencyService) Release(userId int, key string, startedAt time.Time) error {
+	return s.repo.Release(userId, key, startedAt)
+}
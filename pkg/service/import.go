@@ -0,0 +1,103 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+)
+
+// Методы сервиса вызывают соответствующие методы из модуля repository,
+// передаем данные на уровень ниже.
+// Импорт описан в import.go корневого пакета, задания выполняет import_runner.go,
+// форматы файлов разбирают импортеры import_todoist.go, import_trello.go, import_microsoft.go
+// и import_native.go
+
+// название списка, если в файле его нет
+const defaultImportListTitle = "Imported list"
+
+// структура сервиса по работе с заданиями импорта
+type ImportService struct {
+	repo repository.Import
+}
+
+// конструктор для создания сервиса импорта
+func NewImportService(repo repository.Import) *ImportService {
+	return &ImportService{repo: repo}
+}
+
+// метод создает задание импорта. Файл разбирается сразу,
+// чтобы о неподдерживаемом формате сообщить в ответе на запрос, а не в отчете задания
+func (s *ImportService) Create(userId int, input todo.ImportInput) (todo.ImportJob, error) {
+	if err := input.Validate(); err != nil {
+		return todo.ImportJob{}, err
+	}
+
+	if _, err := parseImport(input.Source, input.FileName, input.Data); err != nil {
+		return todo.ImportJob{}, todo.NewValidationError("file", todo.CodeInvalid, err.Error())
+	}
+
+	id, err := s.repo.Create(userId, input)
+	if err != nil {
+		return todo.ImportJob{}, err
+	}
+
+	return s.repo.GetById(userId, id)
+}
+
+func (s *ImportService) GetAll(userId int) ([]todo.ImportJob, error) {
+	return s.repo.GetAll(userId)
+}
+
+func (s *ImportService) GetById(userId, jobId int) (todo.ImportJob, error) {
+	return s.repo.GetById(userId, jobId)
+}
+
+// функция выбирает импортер по источнику
+func parseImport(source, fileName string, data []byte) ([]todo.ImportList, error) {
+	switch source {
+	case todo.ImportTodoist:
+		return parseTodoist(fileName, data)
+	case todo.ImportTrello:
+		return parseTrello(data)
+	case todo.ImportMicrosoft:
+		return parseMicrosoftToDo(data)
+	case todo.ImportNative:
+		return parseExportDocument(data)
+	}
+
+	return nil, fmt.Errorf("unsupported source %q", source)
+}
+
+// форматы дат в файлах экспорта: дата, дата со временем без пояса (UTC) и RFC 3339
+var importDateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	time.RFC3339,
+	time.RFC3339Nano,
+}
+
+func parseImportDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range importDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// функция объединяет непустые части текста через перевод строки
+func joinText(parts ...string) string {
+	nonEmpty := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+
+	return strings.Join(nonEmpty, "\n")
+}
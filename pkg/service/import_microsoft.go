@@ -0,0 +1,137 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+	todo "to-do-list"
+)
+
+// Импорт Microsoft To Do. Сервис не выгружает данные в файл,
+// поэтому импортируются списки в формате Microsoft Graph API (todoTaskList) с задачами в поле tasks:
+// {"value": [списки]}, {"lists": [списки]} или массив списков.
+// Задачи: title, body, status, importance, dueDateTime, categories и checklistItems.
+// Важность high - высокий приоритет, low - низкий, normal - без приоритета
+
+type microsoftTaskList struct {
+	DisplayName string `json:"displayName"`
+	Tasks       []struct {
+		Title string `json:"title"`
+		Body  *struct {
+			Content     string `json:"content"`
+			ContentType string `json:"contentType"`
+		} `json:"body"`
+		Status      string `json:"status"`
+		Importance  string `json:"importance"`
+		DueDateTime *struct {
+			DateTime string `json:"dateTime"`
+			TimeZone string `json:"timeZone"`
+		} `json:"dueDateTime"`
+		Categories     []string `json:"categories"`
+		ChecklistItems []struct {
+			DisplayName string `json:"displayName"`
+			IsChecked   bool   `json:"isChecked"`
+		} `json:"checklistItems"`
+	} `json:"tasks"`
+}
+
+func parseMicrosoftToDo(data []byte) ([]todo.ImportList, error) {
+	data = bytes.TrimSpace(data)
+
+	var taskLists []microsoftTaskList
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &taskLists); err != nil {
+			return nil, fmt.Errorf("invalid json: %w", err)
+		}
+	} else {
+		var export struct {
+			Value []microsoftTaskList `json:"value"`
+			Lists []microsoftTaskList `json:"lists"`
+		}
+		if err := json.Unmarshal(data, &export); err != nil {
+			return nil, fmt.Errorf("invalid json: %w", err)
+		}
+		taskLists = append(export.Value, export.Lists...)
+	}
+	if len(taskLists) == 0 {
+		return nil, fmt.Errorf("json has no task lists")
+	}
+
+	lists := make([]todo.ImportList, 0, len(taskLists))
+	for _, taskList := range taskLists {
+		list := todo.ImportList{Title: taskList.DisplayName}
+		if list.Title == "" {
+			list.Title = defaultImportListTitle
+		}
+
+		for _, task := range taskList.Tasks {
+			item := todo.ImportItem{
+				Title:    task.Title,
+				Done:     task.Status == "completed",
+				Priority: microsoftPriority(task.Importance),
+				Labels:   task.Categories,
+			}
+			if task.Body != nil {
+				item.Description = task.Body.Content
+				if strings.EqualFold(task.Body.ContentType, "html") {
+					item.Description = htmlText(item.Description)
+				}
+			}
+			if task.DueDateTime != nil {
+				if due, ok := parseMicrosoftDateTime(task.DueDateTime.DateTime, task.DueDateTime.TimeZone); ok {
+					item.DueDate = &due
+				}
+			}
+			for _, checklistItem := range task.ChecklistItems {
+				item.Checklist = append(item.Checklist, todo.ImportChecklistItem{
+					Title: checklistItem.DisplayName,
+					Done:  checklistItem.IsChecked,
+				})
+			}
+
+			list.Items = append(list.Items, item)
+		}
+
+		lists = append(lists, list)
+	}
+
+	return lists, nil
+}
+
+func microsoftPriority(importance string) int {
+	switch importance {
+	case "high":
+		return todo.PriorityHigh
+	case "low":
+		return todo.PriorityLow
+	default:
+		return todo.PriorityNone
+	}
+}
+
+// время Graph API без часового пояса в значении: "2026-10-20T00:00:00.0000000" и имя пояса отдельно.
+// Пояса в формате Windows ("Pacific Standard Time") не распознаются и считаются UTC
+func parseMicrosoftDateTime(value, timeZone string) (time.Time, bool) {
+	location := time.UTC
+	if loaded, err := time.LoadLocation(timeZone); err == nil && timeZone != "" {
+		location = loaded
+	}
+
+	due, err := time.ParseInLocation("2006-01-02T15:04:05.9999999", value, location)
+	if err != nil {
+		return parseImportDate(value)
+	}
+	return due, true
+}
+
+var htmlTag = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// текст из html-описания задачи
+func htmlText(s string) string {
+	s = strings.NewReplacer("<br>", "\n", "<br/>", "\n", "<br />", "\n", "</p>", "\n").Replace(s)
+	return strings.TrimSpace(html.UnescapeString(htmlTag.ReplaceAllString(s, "")))
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	todo "to-do-list"
+)
+
+// Импорт json-выгрузки этого сервиса (GET /api/me/export?format=json).
+// Списки и задачи создаются заново: id, версии, роли и время изменения из выгрузки
+// не переносятся, порядок задач сохраняется
+
+func parseExportDocument(data []byte) ([]todo.ImportList, error) {
+	var document todo.ExportDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	if document.Format != todo.ExportDocumentFormat {
+		return nil, fmt.Errorf("json is not an export of this service")
+	}
+	if document.Version > todo.ExportDocumentVersion {
+		return nil, fmt.Errorf("unsupported export version %d", document.Version)
+	}
+
+	lists := make([]todo.ImportList, 0, len(document.Lists))
+	for _, exported := range document.Lists {
+		list := todo.ImportList{
+			Title:       exported.Title,
+			Description: exported.Description,
+			Items:       make([]todo.ImportItem, 0, len(exported.Items)),
+		}
+
+		for _, item := range exported.Items {
+			done, _ := strconv.ParseBool(item.Done)
+			list.Items = append(list.Items, todo.ImportItem{
+				Title:       item.Title,
+				Description: item.Description,
+				Done:        done,
+				DueDate:     item.DueDate,
+				Priority:    item.Priority,
+				Labels:      item.Labels,
+			})
+		}
+
+		lists = append(lists, list)
+	}
+
+	return lists, nil
+}
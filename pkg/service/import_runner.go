@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Обработчик выполняет задания импорта из таблицы import_jobs.
+// Каждый экземпляр приложения периодически захватывает ожидающее задание
+// и импортирует списки файла по одному. Список, его задачи и отметка о его
+// обработке сохраняются в одной транзакции, поэтому задание, прерванное
+// остановкой приложения, после истечения аренды продолжается со следующего списка.
+// Пока задание выполняется, аренда продлевается в фоне. Обработчик, потерявший аренду,
+// прекращает работу: отметка списка не выполняется, и его транзакция откатывается.
+// Задачи, не прошедшие валидацию, пропускаются и попадают в отчет задания
+
+const (
+	// значения по умолчанию для параметров из секции imports конфига
+	defaultImportPollInterval = 5 * time.Second
+	defaultImportLease        = 5 * time.Minute
+	// максимальная длина сохраняемого текста ошибки
+	maxImportErrorLength = 512
+)
+
+// параметры обработчика импорта:
+// PollInterval - интервал проверки заданий, Lease - время, на которое задание
+// захватывается, аренда продлевается после импорта каждого списка и каждую треть Lease
+type ImportConfig struct {
+	PollInterval time.Duration
+	Lease        time.Duration
+}
+
+type ImportRunner struct {
+	repos *repository.Repository
+	cfg   ImportConfig
+}
+
+// конструктор обработчика, незаданные параметры заменяются значениями по умолчанию
+func NewImportRunner(repos *repository.Repository, cfg ImportConfig) *ImportRunner {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultImportPollInterval
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = defaultImportLease
+	}
+
+	return &ImportRunner{repos: repos, cfg: cfg}
+}
+
+// метод запускает выполнение заданий, работает до отмены ctx
+func (r *ImportRunner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.runPending(ctx)
+		}
+	}
+}
+
+// выполнение заданий по одному, пока есть ожидающие
+func (r *ImportRunner) runPending(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := r.repos.Import.Claim(r.cfg.Lease)
+		if errors.Is(err, todo.ErrNotFound) {
+			return
+		}
+		if err != nil {
+			logrus.Errorf("imports claim: %s", err.Error())
+			return
+		}
+
+		status, runErr := r.run(ctx, job)
+		// импорт прерван остановкой приложения, задание будет продолжено после истечения аренды
+		if ctx.Err() != nil {
+			return
+		}
+		// задание продолжает другой обработчик
+		if errors.Is(runErr, repository.ErrLeaseLost) {
+			logrus.Warnf("imports job %d: %s", job.Id, runErr.Error())
+			continue
+		}
+
+		var message *string
+		if runErr != nil {
+			text := truncateError(runErr, maxImportErrorLength)
+			message = &text
+		}
+		if err := r.repos.Import.Finish(job.Id, status, message); err != nil {
+			logrus.Errorf("imports finish job %d: %s", job.Id, err.Error())
+		}
+	}
+}
+
+// метод импортирует списки задания, начиная с первого необработанного,
+// и возвращает итоговый статус задания
+func (r *ImportRunner) run(ctx context.Context, job repository.PendingImport) (string, error) {
+	lists, err := parseImport(job.Source, job.FileName, job.Data)
+	if err != nil {
+		return todo.ImportFailed, err
+	}
+
+	if job.ProcessedLists == 0 {
+		items := 0
+		for _, list := range lists {
+			items += list.ItemCount()
+		}
+		if err := r.repos.Import.SetTotals(job.Id, len(lists), items); err != nil {
+			return todo.ImportFailed, err
+		}
+	}
+
+	// импорт одного списка может длиться дольше аренды, поэтому она продлевается в фоне
+	leaseCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	leaseErr := make(chan error, 1)
+	go func() {
+		leaseErr <- r.extendLease(leaseCtx, job.Id)
+		cancel()
+	}()
+
+	for i := job.ProcessedLists; i < len(lists) && leaseCtx.Err() == nil; i++ {
+		err := r.repos.Transaction(func(repos *repository.Repository) error {
+			processed, imported, errs, err := importList(repos, job.UserId, lists[i])
+			if err != nil {
+				return err
+			}
+			return repos.Import.Checkpoint(job.Id, i, processed, imported, errs, r.cfg.Lease)
+		})
+		if errors.Is(err, repository.ErrLeaseLost) {
+			return todo.ImportFailed, err
+		}
+		if err != nil {
+			logrus.Errorf("imports job %d list %d: %s", job.Id, i, err.Error())
+			return todo.ImportFailed, err
+		}
+	}
+
+	// аренда потеряна во время импорта
+	cancel()
+	if err := <-leaseErr; err != nil {
+		return todo.ImportFailed, err
+	}
+
+	return todo.ImportSucceeded, nil
+}
+
+// метод продлевает аренду задания каждую треть Lease до отмены ctx.
+// Возвращает ErrLeaseLost, если аренду продлить не удалось,
+// ошибки БД только записываются в журнал: аренда продлится при следующей попытке
+func (r *ImportRunner) extendLease(ctx context.Context, jobId int) error {
+	ticker := time.NewTicker(r.cfg.Lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			err := r.repos.Import.ExtendLease(jobId, r.cfg.Lease)
+			if errors.Is(err, repository.ErrLeaseLost) {
+				return err
+			}
+			if err != nil {
+				logrus.Errorf("imports extend lease of job %d: %s", jobId, err.Error())
+			}
+		}
+	}
+}
+
+// функция импортирует список с задачами, возвращает число обработанных
+// и созданных задач и ошибки задач, которые не прошли валидацию
+func importList(repos *repository.Repository, userId int, list todo.ImportList) (int, int, todo.ImportErrors, error) {
+	listId, err := NewTodoListSevice(repos).Create(userId, list.TodoList())
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	items := newTodoItemService(repos)
+	processed, imported, position := 0, 0, 0
+	var errs todo.ImportErrors
+
+	create := func(title string, item todo.TodoItem, done bool) error {
+		processed++
+		item.Position = position
+
+		_, err := items.createWithDone(userId, listId, item, done)
+		var validationErr *todo.ValidationError
+		if errors.As(err, &validationErr) {
+			errs = append(errs, todo.ImportError{List: list.Title, Item: title, Message: err.Error()})
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		imported++
+		position++
+		return nil
+	}
+
+	for _, item := range list.Items {
+		if err := create(item.Title, item.TodoItem(), item.Done); err != nil {
+			return 0, 0, nil, err
+		}
+
+		// пункты чек-листа - задачи со ссылкой на свою задачу в описании
+		for _, checklistItem := range item.Checklist {
+			checkItem := todo.ImportItem{
+				Title:       checklistItem.Title,
+				Description: "Checklist item of: " + strings.TrimSpace(item.Title),
+			}
+			if err := create(checklistItem.Title, checkItem.TodoItem(), checklistItem.Done); err != nil {
+				return 0, 0, nil, err
+			}
+		}
+	}
+
+	return processed, imported, errs, nil
+}
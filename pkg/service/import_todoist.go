@@ -0,0 +1,356 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	todo "to-do-list"
+)
+
+// Импорт резервной копии Todoist.
+// Поддерживаются:
+// zip-архив резервной копии - csv-файл на каждый проект, имя файла становится названием списка;
+// отдельный csv-файл проекта, название списка - имя загруженного файла;
+// json с проектами и задачами в формате Sync API (projects, sections, items).
+// Разделы проектов становятся метками задач, подзадачи - пунктами чек-листа
+// задачи верхнего уровня, метки из текста задачи (@метка) - метками задачи
+
+// максимальный размер распакованного файла проекта в архиве
+const maxTodoistArchiveFileSize = todo.MaxImportFileSize
+
+func parseTodoist(fileName string, data []byte) ([]todo.ImportList, error) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return parseTodoistArchive(data)
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return parseTodoistJSON(trimmed)
+	}
+
+	list, err := parseTodoistCSV(listTitleFromFileName(fileName), data)
+	if err != nil {
+		return nil, err
+	}
+	return []todo.ImportList{list}, nil
+}
+
+func parseTodoistArchive(data []byte) ([]todo.ImportList, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	files := make([]*zip.File, 0, len(archive.File))
+	for _, file := range archive.File {
+		if strings.EqualFold(path.Ext(file.Name), ".csv") {
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	if len(files) == 0 {
+		return nil, fmt.Errorf("archive has no csv files")
+	}
+
+	lists := make([]todo.ImportList, 0, len(files))
+	for _, file := range files {
+		content, err := readZipFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+
+		list, err := parseTodoistCSV(listTitleFromFileName(file.Name), content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		lists = append(lists, list)
+	}
+
+	return lists, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(io.LimitReader(reader, maxTodoistArchiveFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxTodoistArchiveFileSize {
+		return nil, fmt.Errorf("file is too large")
+	}
+
+	return content, nil
+}
+
+// id проекта в имени файла резервной копии: "Покупки [2203306141].csv"
+var todoistFileId = regexp.MustCompile(`\s*\[\d+\]$`)
+
+// название списка из имени файла без пути, расширения и id проекта
+func listTitleFromFileName(fileName string) string {
+	title := strings.TrimSuffix(path.Base(strings.ReplaceAll(fileName, `\`, "/")), path.Ext(fileName))
+	title = strings.TrimSpace(todoistFileId.ReplaceAllString(title, ""))
+	if title == "" || title == "." || title == "/" {
+		return defaultImportListTitle
+	}
+
+	return title
+}
+
+// метки в тексте задачи Todoist: @метка
+var todoistLabel = regexp.MustCompile(`(^|\s)@([^\s@]+)`)
+
+// функция отделяет метки от текста задачи
+func todoistContent(content string) (string, todo.Labels) {
+	var labels todo.Labels
+	for _, match := range todoistLabel.FindAllStringSubmatch(content, -1) {
+		labels = append(labels, match[2])
+	}
+
+	title := strings.Join(strings.Fields(todoistLabel.ReplaceAllString(content, " ")), " ")
+	return title, labels
+}
+
+// csv проекта: строки task, section и note (комментарий к предыдущей задаче).
+// INDENT > 1 - подзадача, PRIORITY: 1 - наивысший приоритет (p1), 4 - без приоритета
+func parseTodoistCSV(title string, data []byte) (todo.ImportList, error) {
+	list := todo.ImportList{Title: title}
+
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return list, fmt.Errorf("invalid csv: %w", err)
+	}
+	if len(records) == 0 {
+		return list, fmt.Errorf("csv is empty")
+	}
+
+	columns := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		columns[strings.ToUpper(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["TYPE"]; !ok {
+		return list, fmt.Errorf("csv has no TYPE column")
+	}
+	if _, ok := columns["CONTENT"]; !ok {
+		return list, fmt.Errorf("csv has no CONTENT column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	section := ""
+	var last *todo.ImportItem
+	for _, record := range records[1:] {
+		switch strings.ToLower(field(record, "TYPE")) {
+		case "section":
+			section = field(record, "CONTENT")
+		case "note":
+			if last != nil {
+				last.Description = joinText(last.Description, field(record, "CONTENT"))
+			}
+		case "task":
+			content, labels := todoistContent(field(record, "CONTENT"))
+			if indent, _ := strconv.Atoi(field(record, "INDENT")); indent > 1 && last != nil {
+				last.Checklist = append(last.Checklist, todo.ImportChecklistItem{Title: content})
+				continue
+			}
+
+			if section != "" {
+				labels = append(labels, section)
+			}
+			priority, _ := strconv.Atoi(field(record, "PRIORITY"))
+			item := todo.ImportItem{
+				Title:       content,
+				Description: field(record, "DESCRIPTION"),
+				Priority:    todoistCSVPriority(priority),
+				Labels:      labels,
+			}
+			if due, ok := parseImportDate(field(record, "DATE")); ok {
+				item.DueDate = &due
+			}
+
+			list.Items = append(list.Items, item)
+			last = &list.Items[len(list.Items)-1]
+		}
+	}
+
+	return list, nil
+}
+
+func todoistCSVPriority(priority int) int {
+	switch priority {
+	case 1:
+		return todo.PriorityHigh
+	case 2:
+		return todo.PriorityMedium
+	case 3:
+		return todo.PriorityLow
+	default:
+		return todo.PriorityNone
+	}
+}
+
+// id в Sync API передаются строками, в старых резервных копиях - числами
+type todoistId string
+
+func (id *todoistId) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*id = ""
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = todoistId(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*id = todoistId(n.String())
+	return nil
+}
+
+type todoistBackup struct {
+	Projects []struct {
+		Id         todoistId `json:"id"`
+		Name       string    `json:"name"`
+		IsArchived bool      `json:"is_archived"`
+		IsDeleted  bool      `json:"is_deleted"`
+	} `json:"projects"`
+	Sections []struct {
+		Id   todoistId `json:"id"`
+		Name string    `json:"name"`
+	} `json:"sections"`
+	Items []struct {
+		Id          todoistId `json:"id"`
+		ProjectId   todoistId `json:"project_id"`
+		SectionId   todoistId `json:"section_id"`
+		ParentId    todoistId `json:"parent_id"`
+		Content     string    `json:"content"`
+		Description string    `json:"description"`
+		Checked     bool      `json:"checked"`
+		IsDeleted   bool      `json:"is_deleted"`
+		Priority    int       `json:"priority"`
+		Labels      []string  `json:"labels"`
+		ChildOrder  int       `json:"child_order"`
+		Due         *struct {
+			Date string `json:"date"`
+		} `json:"due"`
+	} `json:"items"`
+}
+
+// json в формате Sync API: priority 4 - наивысший приоритет (p1), 1 - без приоритета
+func parseTodoistJSON(data []byte) ([]todo.ImportList, error) {
+	var backup todoistBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	if len(backup.Projects) == 0 {
+		return nil, fmt.Errorf("json has no projects")
+	}
+
+	sections := make(map[todoistId]string, len(backup.Sections))
+	for _, section := range backup.Sections {
+		sections[section.Id] = section.Name
+	}
+
+	items := backup.Items
+	sort.SliceStable(items, func(i, j int) bool { return items[i].ChildOrder < items[j].ChildOrder })
+
+	// подзадачи любого уровня относятся к задаче верхнего уровня
+	parents := make(map[todoistId]todoistId, len(items))
+	for _, item := range items {
+		parents[item.Id] = item.ParentId
+	}
+	root := func(id todoistId) todoistId {
+		for depth := 0; parents[id] != "" && depth < len(items); depth++ {
+			id = parents[id]
+		}
+		return id
+	}
+
+	lists := make([]todo.ImportList, 0, len(backup.Projects))
+	index := make(map[todoistId]int, len(backup.Projects))
+	for _, project := range backup.Projects {
+		if project.IsArchived || project.IsDeleted {
+			continue
+		}
+		index[project.Id] = len(lists)
+		lists = append(lists, todo.ImportList{Title: project.Name})
+	}
+
+	// сначала задачи верхнего уровня, затем подзадачи
+	positions := make(map[todoistId][2]int, len(items))
+	for _, item := range items {
+		n, ok := index[item.ProjectId]
+		if !ok || item.IsDeleted || item.ParentId != "" {
+			continue
+		}
+
+		title, labels := todoistContent(item.Content)
+		labels = append(labels, item.Labels...)
+		if section := sections[item.SectionId]; section != "" {
+			labels = append(labels, section)
+		}
+
+		imported := todo.ImportItem{
+			Title:       title,
+			Description: item.Description,
+			Done:        item.Checked,
+			Priority:    todoistAPIPriority(item.Priority),
+			Labels:      labels,
+		}
+		if item.Due != nil {
+			if due, ok := parseImportDate(item.Due.Date); ok {
+				imported.DueDate = &due
+			}
+		}
+
+		positions[item.Id] = [2]int{n, len(lists[n].Items)}
+		lists[n].Items = append(lists[n].Items, imported)
+	}
+	for _, item := range items {
+		position, ok := positions[root(item.Id)]
+		if !ok || item.IsDeleted || item.ParentId == "" {
+			continue
+		}
+
+		title, _ := todoistContent(item.Content)
+		parent := &lists[position[0]].Items[position[1]]
+		parent.Checklist = append(parent.Checklist, todo.ImportChecklistItem{Title: title, Done: item.Checked})
+	}
+
+	return lists, nil
+}
+
+func todoistAPIPriority(priority int) int {
+	switch priority {
+	case 4:
+		return todo.PriorityHigh
+	case 3:
+		return todo.PriorityMedium
+	case 2:
+		return todo.PriorityLow
+	default:
+		return todo.PriorityNone
+	}
+}
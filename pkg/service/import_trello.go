@@ -0,0 +1,130 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	todo "to-do-list"
+)
+
+// Импорт доски Trello из json-экспорта доски (меню доски - "Печать, экспорт и совместное использование").
+// Доска становится списком, карточки - задачами. Название колонки карточки
+// и метки карточки становятся метками задачи (метка без названия - по её цвету),
+// чек-листы карточки - пунктами чек-листа задачи, dueComplete - выполнением задачи.
+// Архивные карточки и карточки архивных колонок не импортируются
+
+type trelloBoard struct {
+	Name  string `json:"name"`
+	Desc  string `json:"desc"`
+	Lists []struct {
+		Id     string  `json:"id"`
+		Name   string  `json:"name"`
+		Closed bool    `json:"closed"`
+		Pos    float64 `json:"pos"`
+	} `json:"lists"`
+	Cards []struct {
+		Id          string  `json:"id"`
+		Name        string  `json:"name"`
+		Desc        string  `json:"desc"`
+		IdList      string  `json:"idList"`
+		Closed      bool    `json:"closed"`
+		Pos         float64 `json:"pos"`
+		Due         *string `json:"due"`
+		DueComplete bool    `json:"dueComplete"`
+		Labels      []struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		} `json:"labels"`
+	} `json:"cards"`
+	Checklists []struct {
+		IdCard     string  `json:"idCard"`
+		Pos        float64 `json:"pos"`
+		CheckItems []struct {
+			Name  string  `json:"name"`
+			State string  `json:"state"`
+			Pos   float64 `json:"pos"`
+		} `json:"checkItems"`
+	} `json:"checklists"`
+}
+
+func parseTrello(data []byte) ([]todo.ImportList, error) {
+	var board trelloBoard
+	if err := json.Unmarshal(data, &board); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	if board.Name == "" && len(board.Lists) == 0 {
+		return nil, fmt.Errorf("json is not a trello board")
+	}
+
+	// порядок колонок для сортировки карточек
+	columns := make(map[string]int, len(board.Lists))
+	names := make(map[string]string, len(board.Lists))
+	sort.SliceStable(board.Lists, func(i, j int) bool { return board.Lists[i].Pos < board.Lists[j].Pos })
+	for i, column := range board.Lists {
+		if !column.Closed {
+			columns[column.Id] = i
+			names[column.Id] = column.Name
+		}
+	}
+
+	checklists := board.Checklists
+	sort.SliceStable(checklists, func(i, j int) bool { return checklists[i].Pos < checklists[j].Pos })
+
+	cards := board.Cards
+	sort.SliceStable(cards, func(i, j int) bool {
+		if columns[cards[i].IdList] != columns[cards[j].IdList] {
+			return columns[cards[i].IdList] < columns[cards[j].IdList]
+		}
+		return cards[i].Pos < cards[j].Pos
+	})
+
+	list := todo.ImportList{Title: board.Name, Description: board.Desc}
+	if list.Title == "" {
+		list.Title = defaultImportListTitle
+	}
+
+	for _, card := range cards {
+		if _, ok := columns[card.IdList]; !ok || card.Closed {
+			continue
+		}
+
+		item := todo.ImportItem{
+			Title:       card.Name,
+			Description: card.Desc,
+			Done:        card.DueComplete,
+		}
+		if name := names[card.IdList]; name != "" {
+			item.Labels = append(item.Labels, name)
+		}
+		for _, label := range card.Labels {
+			if label.Name != "" {
+				item.Labels = append(item.Labels, label.Name)
+			} else if label.Color != "" {
+				item.Labels = append(item.Labels, label.Color)
+			}
+		}
+		if card.Due != nil {
+			if due, ok := parseImportDate(*card.Due); ok {
+				item.DueDate = &due
+			}
+		}
+
+		for _, checklist := range checklists {
+			if checklist.IdCard != card.Id {
+				continue
+			}
+			checkItems := checklist.CheckItems
+			sort.SliceStable(checkItems, func(i, j int) bool { return checkItems[i].Pos < checkItems[j].Pos })
+			for _, checkItem := range checkItems {
+				item.Checklist = append(item.Checklist, todo.ImportChecklistItem{
+					Title: checkItem.Name,
+					Done:  checkItem.State == "complete",
+				})
+			}
+		}
+
+		list.Items = append(list.Items, item)
+	}
+
+	return []todo.ImportList{list}, nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+)
+
+// Методы сервиса вызывают соответствующие методы из модуля repository,
+// передаем данные на уровень ниже
+
+// repos используется для изменений, которые публикуют события (event.go)
+type ListMemberService struct {
+	repo  repository.ListMember
+	repos *repository.Repository
+}
+
+// конструктор для создания сервиса по работе с участниками списков
+func NewListMemberService(repos *repository.Repository) *ListMemberService {
+	return &ListMemberService{repo: repos.ListMember, repos: repos}
+}
+
+func (s *ListMemberService) GetMembers(userId, listId int) ([]todo.ListMember, error) {
+	return s.repo.GetMembers(userId, listId)
+}
+
+func (s *ListMemberService) SetMember(userId, listId int, input todo.ListMemberInput) (todo.ListMember, error) {
+	if err := input.Validate(); err != nil {
+		return todo.ListMember{}, err
+	}
+
+	// событие публикуется после изменения, чтобы его получил и новый участник
+	var member todo.ListMember
+	err := s.repos.Transaction(func(repos *repository.Repository) error {
+		var err error
+		if member, err = repos.ListMember.SetMember(userId, listId, input); err != nil {
+			return err
+		}
+		return repos.Event.Publish(todo.NewListEvent(todo.EventListMembers, userId, listId))
+	})
+
+	return member, err
+}
+
+// событие публикуется до исключения, чтобы его получил и исключаемый участник
+func (s *ListMemberService) DeleteMember(userId, listId, memberId int) error {
+	return s.repos.Transaction(func(repos *repository.Repository) error {
+		if err := repos.Event.Publish(todo.NewListEvent(todo.EventListMembers, userId, listId)); err != nil {
+			return err
+		}
+		return repos.ListMember.DeleteMember(userId, listId, memberId)
+	})
+}
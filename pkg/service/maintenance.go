@@ -0,0 +1,37 @@
+package service
+
+import (
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+)
+
+// Сервис обслуживания БД, используется командами обслуживания (cmd/commands.go)
+
+type MaintenanceService struct {
+	repos *repository.Repository
+}
+
+// конструктор для создания сервиса обслуживания
+func NewMaintenanceService(repos *repository.Repository) *MaintenanceService {
+	return &MaintenanceService{repos: repos}
+}
+
+// метод находит записи без связей и удаляет их,
+// при dryRun записи только находятся, отчет тот же
+func (s *MaintenanceService) CollectOrphans(dryRun bool) (todo.OrphanReport, error) {
+	var report todo.OrphanReport
+
+	err := s.repos.Transaction(func(repos *repository.Repository) error {
+		var err error
+		if report, err = repos.Maintenance.FindOrphans(); err != nil {
+			return err
+		}
+		if dryRun || report.Empty() {
+			return nil
+		}
+
+		return repos.Maintenance.DeleteOrphans(report)
+	})
+
+	return report, err
+}
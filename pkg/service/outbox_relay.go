@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Ретранслятор публикует сообщения outbox во внешние получатели (outbox_sinks.go).
+// Сообщения записываются в одной транзакции с изменением, поэтому после фиксации
+// транзакции событие будет опубликовано, даже если приложение сразу упадет.
+// Ретранслятор захватывает пачку сообщений в аренду на Lease (в короткой транзакции),
+// публикует их вне транзакции и отмечает каждое сообщение отдельным запросом.
+// Сообщения одного агрегата публикуются в порядке id: пока раннее сообщение захвачено
+// или ожидает повтора, следующие сообщения агрегата не захватываются.
+// Если получатель вернул ошибку, сообщение повторяется с задержкой.
+// Публикация пачки завершается до окончания аренды, необработанные сообщения освобождаются.
+// Доставка выполняется не менее одного раза: при повторе сообщение получат
+// и те получатели, которые уже приняли его
+
+const (
+	// значения по умолчанию для параметров из секции outbox конфига
+	defaultOutboxPollInterval = time.Second
+	defaultOutboxRetention    = 24 * time.Hour
+	defaultOutboxLease        = time.Minute
+	// число сообщений, захватываемых за один раз
+	outboxBatchSize = 100
+	// задержка перед первым повтором и максимальная задержка
+	outboxRetryBase = time.Second
+	outboxRetryMax  = 5 * time.Minute
+	// интервал удаления опубликованных сообщений
+	outboxCleanupInterval = time.Hour
+	// максимальная длина сохраняемого текста ошибки
+	maxOutboxErrorLength = 512
+)
+
+// получатель сообщений outbox. Publish возвращает ошибку,
+// если получатель не подтвердил прием сообщения
+type OutboxSink interface {
+	Name() string
+	Publish(ctx context.Context, message todo.OutboxMessage) error
+}
+
+// параметры ретранслятора: PollInterval - интервал проверки outbox,
+// Retention - время хранения опубликованных сообщений,
+// Lease - время аренды пачки, должно быть больше времени ожидания ответа получателей
+type OutboxConfig struct {
+	PollInterval time.Duration
+	Retention    time.Duration
+	Lease        time.Duration
+}
+
+type OutboxRelay struct {
+	repos *repository.Repository
+	sinks []OutboxSink
+	cfg   OutboxConfig
+}
+
+// конструктор ретранслятора, незаданные параметры заменяются значениями по умолчанию
+func NewOutboxRelay(repos *repository.Repository, sinks []OutboxSink, cfg OutboxConfig) *OutboxRelay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultOutboxPollInterval
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = defaultOutboxRetention
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = defaultOutboxLease
+	}
+
+	return &OutboxRelay{repos: repos, sinks: sinks, cfg: cfg}
+}
+
+// метод запускает публикацию сообщений, работает до отмены ctx
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(outboxCleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// публикуем пачками, пока в outbox есть сообщения к публикации
+			for ctx.Err() == nil {
+				count, err := r.relay(ctx)
+				if err != nil {
+					logrus.Errorf("outbox relay: %s", err.Error())
+				}
+				if err != nil || count < outboxBatchSize {
+					break
+				}
+			}
+		case <-cleanup.C:
+			if err := r.repos.Outbox.DeletePublishedBefore(time.Now().Add(-r.cfg.Retention)); err != nil {
+				logrus.Errorf("outbox cleanup: %s", err.Error())
+			}
+		}
+	}
+}
+
+// метод публикует одну пачку сообщений и возвращает их число
+func (r *OutboxRelay) relay(ctx context.Context) (int, error) {
+	// срок отсчитывается до захвата, поэтому он не позже срока аренды в БД
+	leaseCtx, cancel := context.WithDeadline(ctx, time.Now().Add(r.cfg.Lease))
+	defer cancel()
+
+	messages, err := r.repos.Outbox.Claim(outboxBatchSize, r.cfg.Lease)
+	if err != nil || len(messages) == 0 {
+		return 0, err
+	}
+
+	// агрегаты, сообщение которых не удалось опубликовать в этой пачке,
+	// и сообщения, которые освобождаются без публикации
+	failed := make(map[string]bool)
+	released := make([]int64, 0)
+	for _, message := range messages {
+		// следующие сообщения агрегата ждут повтора неудачного,
+		// после окончания аренды или при остановке сообщения не публикуются
+		if failed[message.AggregateKey()] || leaseCtx.Err() != nil {
+			released = append(released, message.Id)
+			continue
+		}
+
+		err := r.publish(leaseCtx, message.OutboxMessage)
+		if ctx.Err() != nil {
+			released = append(released, message.Id)
+			continue
+		}
+
+		if err == nil {
+			err = r.repos.Outbox.MarkPublished(message.Id, message.LeasedUntil)
+		} else {
+			failed[message.AggregateKey()] = true
+			err = r.repos.Outbox.MarkFailed(message.Id, message.LeasedUntil, truncateError(err, maxOutboxErrorLength), outboxRetryAt(message.Attempts+1))
+		}
+		// неотмеченные сообщения будут повторены после окончания аренды
+		if err != nil {
+			return len(messages), err
+		}
+	}
+
+	if len(released) > 0 {
+		if err := r.repos.Outbox.Release(released, messages[0].LeasedUntil); err != nil {
+			return len(messages), err
+		}
+	}
+
+	return len(messages), nil
+}
+
+// сообщение публикуется во все получатели, ошибка любого из них
+// приводит к повтору сообщения для всех
+func (r *OutboxRelay) publish(ctx context.Context, message todo.OutboxMessage) error {
+	for _, sink := range r.sinks {
+		if err := sink.Publish(ctx, message); err != nil {
+			return fmt.Errorf("%s: %w", sink.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// время следующей попытки после attempts неудачных
+func outboxRetryAt(attempts int) time.Time {
+	delay := outboxRetryBase
+	for i := 1; i < attempts && delay < outboxRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > outboxRetryMax {
+		delay = outboxRetryMax
+	}
+
+	return time.Now().Add(delay)
+}
+
+// функция возвращает текст ошибки, обрезанный до limit байт
+func truncateError(err error, limit int) string {
+	message := err.Error()
+	if len(message) > limit {
+		message = message[:limit]
+	}
+
+	return message
+}
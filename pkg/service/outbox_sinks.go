@@ -0,0 +1,173 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	todo "to-do-list"
+
+	"github.com/nats-io/nats.go"
+)
+
+// В данном файле описаны получатели сообщений outbox (outbox_relay.go):
+// шина внутри приложения, HTTP-endpoint и сервер NATS.
+// Получатели подключаются в main.go по секции outbox конфига
+
+// время ожидания ответа внешних получателей по умолчанию
+const defaultOutboxSinkTimeout = 10 * time.Second
+
+// обработчик сообщений шины, ошибка приводит к повтору сообщения
+type OutboxHandler func(ctx context.Context, message todo.OutboxMessage) error
+
+// шина внутри приложения: сообщения передаются подписанным обработчикам
+// по очереди, синхронно в горутине ретранслятора
+type OutboxBus struct {
+	mu       sync.RWMutex
+	handlers []OutboxHandler
+}
+
+func NewOutboxBus() *OutboxBus {
+	return &OutboxBus{}
+}
+
+// подписка обработчика на все сообщения
+func (b *OutboxBus) Subscribe(handler OutboxHandler) {
+	b.mu.Lock()
+	b.handlers = append(b.handlers, handler)
+	b.mu.Unlock()
+}
+
+func (b *OutboxBus) Name() string {
+	return "bus"
+}
+
+func (b *OutboxBus) Publish(ctx context.Context, message todo.OutboxMessage) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, handler := range b.handlers {
+		if err := handler(ctx, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// получатель, которому сообщение отправляется POST-запросом с json сообщения.
+// Заголовок X-Outbox-Message-Id позволяет получателю отбрасывать повторы,
+// сообщение принято, если получатель ответил 2xx
+type HTTPOutboxSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPOutboxSink(url string, timeout time.Duration) *HTTPOutboxSink {
+	if timeout <= 0 {
+		timeout = defaultOutboxSinkTimeout
+	}
+
+	return &HTTPOutboxSink{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (s *HTTPOutboxSink) Name() string {
+	return "http"
+}
+
+func (s *HTTPOutboxSink) Publish(ctx context.Context, message todo.OutboxMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Outbox-Message-Id", strconv.FormatInt(message.Id, 10))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// тело ответа не используется, дочитываем его для повторного использования соединения
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// получатель, публикующий сообщения в NATS (или совместимый сервер) клиентом nats.go.
+// Сообщение публикуется в subject <subject>.<тип события>, например todo.events.item.created.
+// Прием подтверждается сервером в ответ на Flush после публикации.
+// Если сервер поддерживает заголовки, добавляется Nats-Msg-Id,
+// по которому JetStream отбрасывает повторы.
+// Клиент сам переподключается к серверу, недоступность сервера при запуске не считается ошибкой
+type NATSOutboxSink struct {
+	conn    *nats.Conn
+	subject string
+	timeout time.Duration
+}
+
+// конструктор получателя, url - адрес вида nats://[user:password@]host:4222
+func NewNATSOutboxSink(url, subject string, timeout time.Duration) (*NATSOutboxSink, error) {
+	if timeout <= 0 {
+		timeout = defaultOutboxSinkTimeout
+	}
+
+	conn, err := nats.Connect(url,
+		nats.Name("to-do-list-outbox"),
+		nats.Timeout(timeout),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NATSOutboxSink{conn: conn, subject: subject, timeout: timeout}, nil
+}
+
+func (s *NATSOutboxSink) Name() string {
+	return "nats"
+}
+
+func (s *NATSOutboxSink) Publish(ctx context.Context, message todo.OutboxMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(s.subject + "." + message.Type)
+	msg.Data = data
+	if s.conn.HeadersSupported() {
+		msg.Header.Set(nats.MsgIdHdr, fmt.Sprintf("outbox-%d", message.Id))
+	}
+
+	if err := s.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.conn.FlushWithContext(ctx)
+}
+
+// закрытие соединения, неотправленные сообщения отбрасываются
+// и будут опубликованы повторно из outbox
+func (s *NATSOutboxSink) Close() {
+	s.conn.Close()
+}
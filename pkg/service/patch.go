@@ -0,0 +1,173 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	todo "to-do-list"
+	"to-do-list/pkg/jsonpatch"
+)
+
+// Патч применяется к json-документу записи, после чего документ
+// проверяется на соответствие схеме списка или задачи
+// и теми же правилами валидации, что и при создании.
+// Поле done в документе задачи - логическое,
+// поля id, version, поля аудита и completed_at доступны только для чтения.
+// Значение null в description очищает описание: в документе поле становится пустой строкой,
+// и в БД записывается "" (колонка допускает NULL, но приложение хранит отсутствие описания
+// пустой строкой так же, как при создании записи без описания),
+// null в due_date удаляет срок задачи.
+
+type listDocument struct {
+	Id          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Position    int    `json:"position"`
+	Version     int    `json:"version"`
+	todo.Audit
+}
+
+type itemDocument struct {
+	Id          int         `json:"id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Done        bool        `json:"done"`
+	Position    int         `json:"position"`
+	DueDate     *time.Time  `json:"due_date"`
+	Priority    int         `json:"priority"`
+	Labels      todo.Labels `json:"labels"`
+	Version     int         `json:"version"`
+	CompletedAt *time.Time  `json:"completed_at"`
+	todo.Audit
+}
+
+func patchList(list todo.TodoList, patch todo.Patch) (todo.TodoList, error) {
+	doc := listDocument{
+		Id:          list.Id,
+		Title:       list.Title,
+		Description: list.Description,
+		Position:    list.Position,
+		Version:     list.Version,
+		Audit:       list.Audit,
+	}
+
+	var patched listDocument
+	if err := applyPatch(doc, patch, &patched); err != nil {
+		return list, err
+	}
+
+	if patched.Id != doc.Id || patched.Version != doc.Version || !patched.Audit.Equal(doc.Audit) {
+		return list, fmt.Errorf("%w: id, version and audit fields are read-only", todo.ErrInvalidDocument)
+	}
+
+	list.Title = patched.Title
+	list.Description = patched.Description
+	list.Position = patched.Position
+
+	if err := list.Validate(); err != nil {
+		return list, invalidDocument(err)
+	}
+
+	return list, nil
+}
+
+func patchItem(item todo.TodoItem, patch todo.Patch) (todo.TodoItem, error) {
+	done, _ := strconv.ParseBool(item.Done)
+	doc := itemDocument{
+		Id:          item.Id,
+		Title:       item.Title,
+		Description: item.Description,
+		Done:        done,
+		Position:    item.Position,
+		DueDate:     item.DueDate,
+		Priority:    item.Priority,
+		Labels:      item.Labels,
+		Version:     item.Version,
+		CompletedAt: item.CompletedAt,
+		Audit:       item.Audit,
+	}
+
+	var patched itemDocument
+	if err := applyPatch(doc, patch, &patched); err != nil {
+		return item, err
+	}
+
+	if patched.Id != doc.Id || patched.Version != doc.Version || !patched.Audit.Equal(doc.Audit) ||
+		!todo.EqualTimes(patched.CompletedAt, doc.CompletedAt) {
+		return item, fmt.Errorf("%w: id, version, completed_at and audit fields are read-only", todo.ErrInvalidDocument)
+	}
+
+	item.Title = patched.Title
+	item.Description = patched.Description
+	item.Done = strconv.FormatBool(patched.Done)
+	item.Position = patched.Position
+	item.DueDate = patched.DueDate
+	item.Priority = patched.Priority
+	item.Labels = patched.Labels
+
+	if err := item.Validate(); err != nil {
+		return item, invalidDocument(err)
+	}
+
+	return item, nil
+}
+
+// функция применяет патч к документу doc и записывает результат в target.
+// Результат декодируется строго: неизвестные поля и неверные типы - ошибка схемы
+func applyPatch(doc interface{}, patch todo.Patch, target interface{}) error {
+	original, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	switch patch.ContentType {
+	case todo.MergePatchType:
+		patched, err = jsonpatch.MergePatch(original, patch.Body)
+	case todo.JSONPatchType:
+		patched, err = jsonpatch.ApplyPatch(original, patch.Body)
+	default:
+		return todo.ErrUnsupportedPatch
+	}
+
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return fmt.Errorf("%w: %s", todo.ErrPatchTestFailed, err.Error())
+		}
+		return fmt.Errorf("%w: %s", todo.ErrInvalidPatch, err.Error())
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("%w: %s", todo.ErrInvalidDocument, err.Error())
+	}
+
+	return nil
+}
+
+// ошибка валидации документа после применения патча:
+// errors.Is возвращает true для ErrInvalidDocument, ошибки по полям
+// доступны через errors.As как *todo.ValidationError
+type documentError struct {
+	*todo.ValidationError
+}
+
+func (e documentError) Is(target error) bool {
+	return target == todo.ErrInvalidDocument
+}
+
+func (e documentError) Unwrap() error {
+	return e.ValidationError
+}
+
+func invalidDocument(err error) error {
+	var validationErr *todo.ValidationError
+	if errors.As(err, &validationErr) {
+		return documentError{validationErr}
+	}
+	return fmt.Errorf("%w: %s", todo.ErrInvalidDocument, err.Error())
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+)
+
+// Методы сервиса вызывают соответствующие методы из модуля repository,
+// передаем данные на уровень ниже
+
+type SavedFilterService struct {
+	repo repository.SavedFilter
+}
+
+// конструктор для создания сервиса по работе с сохраненными фильтрами
+func NewSavedFilterService(repo repository.SavedFilter) *SavedFilterService {
+	return &SavedFilterService{repo: repo}
+}
+
+func (s *SavedFilterService) Create(userId int, filter todo.SavedFilter) (int, error) {
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+	return s.repo.Create(userId, filter)
+}
+
+func (s *SavedFilterService) GetAll(userId int) ([]todo.SavedFilter, error) {
+	return s.repo.GetAll(userId)
+}
+
+func (s *SavedFilterService) GetById(userId, filterId int) (todo.SavedFilter, error) {
+	return s.repo.GetById(userId, filterId)
+}
+
+func (s *SavedFilterService) Update(userId, filterId int, input todo.UpdateSavedFilterInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+	return s.repo.Update(userId, filterId, input)
+}
+
+func (s *SavedFilterService) Delete(userId, filterId int) error {
+	return s.repo.Delete(userId, filterId)
+}
+
+// задачи фильтра вычисляются с правами вызывающего пользователя:
+// сначала находим фильтр пользователя, затем применяем его выражение
+func (s *SavedFilterService) GetItems(userId, filterId int, query todo.PageQuery) ([]todo.TodoItem, string, error) {
+	filter, err := s.repo.GetById(userId, filterId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return s.repo.GetItems(userId, filter.Filter, query)
+}
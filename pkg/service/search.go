@@ -0,0 +1,22 @@
+package service
+
+import (
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+)
+
+// Методы сервиса вызывают соответствующие методы из модуля repository,
+// передаем данные на уровень ниже
+
+type SearchService struct {
+	repo repository.Search
+}
+
+// конструктор для создания сервиса полнотекстового поиска
+func NewSearchService(repo repository.Search) *SearchService {
+	return &SearchService{repo: repo}
+}
+
+func (s *SearchService) Search(userId int, query todo.SearchQuery) ([]todo.SearchResult, error) {
+	return s.repo.Search(userId, query)
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"time"
 	todo "to-do-list"
 	"to-do-list/pkg/repository"
 )
@@ -12,40 +13,159 @@ import (
 // Это и есть внедрение зависимостей
 type Authorization interface {
 	CreateUser(user todo.User) (int, error)
+	Authenticate(username, password string) (int, error)
 	GenerateToken(username, password string) (string, error)
 	ParseToken(token string) (int, error)
+	GenerateStreamToken(userId int) (string, error)
+	ParseStreamToken(token string) (int, error)
 }
 type TodoList interface {
 	Create(userId int, list todo.TodoList) (int, error)
-	GetAll(userId int) ([]todo.TodoList, error)
+	GetAll(userId int, query todo.PageQuery) ([]todo.TodoList, string, error)
 	GetById(userId, listId int) (todo.TodoList, error)
-	DeleteList(userId, listId int) error
+	DeleteList(userId, listId int, version *int) error
 	UpdateList(userId, listId int, input todo.UpdateListInput) error
+	PatchList(userId, listId int, patch todo.Patch) (todo.TodoList, error)
+}
+type ListMember interface {
+	GetMembers(userId, listId int) ([]todo.ListMember, error)
+	SetMember(userId, listId int, input todo.ListMemberInput) (todo.ListMember, error)
+	DeleteMember(userId, listId, memberId int) error
 }
 type TodoItem interface {
 	CreateItem(userId, listId int, input todo.TodoItem) (int, error)
-	GetAllItems(userId, listId int) ([]todo.TodoItem, error)
+	GetAllItems(userId, listId int, query todo.PageQuery) ([]todo.TodoItem, string, error)
 	GetItemById(userId, itemId int) (todo.TodoItem, error)
 	UpdateItem(userId, itemId int, input todo.UpdateItemInput) error
-	DeleteItem(userId, itemId int) error
+	PatchItem(userId, itemId int, patch todo.Patch) (todo.TodoItem, error)
+	DeleteItem(userId, itemId int, version *int) error
+	BulkItems(userId int, input todo.BulkItemsInput) ([]todo.BulkItemResult, error)
+}
+type SavedFilter interface {
+	Create(userId int, filter todo.SavedFilter) (int, error)
+	GetAll(userId int) ([]todo.SavedFilter, error)
+	GetById(userId, filterId int) (todo.SavedFilter, error)
+	Update(userId, filterId int, input todo.UpdateSavedFilterInput) error
+	Delete(userId, filterId int) error
+	GetItems(userId, filterId int, query todo.PageQuery) ([]todo.TodoItem, string, error)
+}
+type Idempotency interface {
+	Begin(userId int, key, requestHash string) (todo.IdempotencyRecord, bool, error)
+	Complete(userId int, key string, startedAt time.Time, statusCode int, body []byte, headers todo.ResponseHeaders) error
+	Release(userId int, key string, startedAt time.Time) error
+}
+type Search interface {
+	Search(userId int, query todo.SearchQuery) ([]todo.SearchResult, error)
+}
+type Events interface {
+	Subscribe(userId int) *Subscription
+	Unsubscribe(sub *Subscription)
+	Replay(userId int, cursor string, afterId int64, limit int) ([]todo.Event, string, error)
+}
+type Webhook interface {
+	Create(userId int, input todo.WebhookInput) (todo.Webhook, error)
+	GetAll(userId int) ([]todo.Webhook, error)
+	GetById(userId, webhookId int) (todo.Webhook, error)
+	Update(userId, webhookId int, input todo.UpdateWebhookInput) error
+	Delete(userId, webhookId int) error
+	GetDeliveries(userId, webhookId int, query todo.DeliveryQuery) ([]todo.WebhookDelivery, string, error)
+	Redeliver(userId, webhookId int, deliveryId int64) (todo.WebhookDelivery, error)
+}
+type Sync interface {
+	Changes(userId int, since string) (todo.SyncChanges, error)
+	Push(userId int, input todo.SyncPushInput) ([]todo.SyncResult, error)
+}
+type Calendar interface {
+	CreateFeed(userId, listId int) (string, error)
+	DeleteFeed(userId, listId int) error
+	GetFeed(token string) (todo.Calendar, []todo.CalendarItem, error)
+	GetCalendars(userId int) ([]todo.Calendar, error)
+	GetCalendar(userId, listId int) (todo.Calendar, error)
+	GetItems(userId, listId int) ([]todo.CalendarItem, error)
+	GetItem(userId, listId int, name string) (todo.CalendarItem, error)
+	PutItem(userId, listId int, input todo.PutCalendarItemInput) (todo.CalendarItem, bool, error)
+	DeleteItem(userId, listId int, name string, version *int) error
+}
+type Import interface {
+	Create(userId int, input todo.ImportInput) (todo.ImportJob, error)
+	GetAll(userId int) ([]todo.ImportJob, error)
+	GetById(userId, jobId int) (todo.ImportJob, error)
+}
+type Export interface {
+	Export(userId int, w ExportWriter) error
+}
+type Account interface {
+	GetDeletion(userId int) (todo.AccountDeletion, error)
+	ScheduleDeletion(userId int, input todo.DeleteAccountInput) (todo.AccountDeletion, error)
+	CancelDeletion(userId int) error
+}
+
+// параметры бизнес-логики, задаются в configs/config.yml.
+// Events - брокер событий (event_broker.go), общий для всех экземпляров Service,
+// в том числе созданных для транзакций.
+// TombstoneRetention - время хранения надгробий для синхронизации (sync.go),
+// DeletionGracePeriod - отсрочка удаления аккаунта (account.go)
+type Config struct {
+	IdempotencyTTL      time.Duration
+	IdempotencyLease    time.Duration
+	Events              Events
+	TombstoneRetention  time.Duration
+	DeletionGracePeriod time.Duration
 }
 
 // описываем струтуру сервиса, состоящую из интерфейсов
+// repos и cfg хранятся для запуска транзакций, охватывающих несколько сервисов
 type Service struct {
+	repos *repository.Repository
+	cfg   Config
+
 	Authorization
 	TodoList
+	ListMember
 	TodoItem
+	SavedFilter
+	Idempotency
+	Search
+	Events
+	Webhook
+	Sync
+	Calendar
+	Import
+	Export
+	Account
 }
 
 // конструктор сервиса, в котором инициализируются сервисы авторизации,
 // сервисы работы со списками и задачами.
 // данные уходят на слой ниже, в repository.
-// в сервис TodoItem передается два аргумента, для связи задач и списков
-func NewService(repos *repository.Repository) *Service {
+// сервисы, изменяющие списки и задачи, получают все репозитории,
+// чтобы публиковать события в одной транзакции с изменением
+func NewService(repos *repository.Repository, cfg Config) *Service {
 	// инициализация сервиса
 	return &Service{
+		repos:         repos,
+		cfg:           cfg,
 		Authorization: NewAuthService(repos.Authorization),
-		TodoList:      NewTodoListSevice(repos.TodoList),
-		TodoItem:      newTodoItemService(repos.TodoItem, repos.TodoList),
+		TodoList:      NewTodoListSevice(repos),
+		ListMember:    NewListMemberService(repos),
+		TodoItem:      newTodoItemService(repos),
+		SavedFilter:   NewSavedFilterService(repos.SavedFilter),
+		Idempotency:   NewIdempotencyService(repos.Idempotency, cfg.IdempotencyTTL, cfg.IdempotencyLease),
+		Search:        NewSearchService(repos.Search),
+		Events:        cfg.Events,
+		Webhook:       NewWebhookService(repos.Webhook, repos.TodoList),
+		Sync:          NewSyncService(repos, cfg.TombstoneRetention),
+		Calendar:      NewCalendarService(repos),
+		Import:        NewImportService(repos.Import),
+		Export:        NewExportService(repos),
+		Account:       NewAccountService(repos, cfg.DeletionGracePeriod),
 	}
 }
+
+// метод выполняет fn с сервисами, все обращения которых к БД
+// выполняются в одной транзакции. Если fn вернула ошибку, изменения откатываются
+func (s *Service) Transaction(fn func(services *Service) error) error {
+	return s.repos.Transaction(func(repos *repository.Repository) error {
+		return fn(NewService(repos, s.cfg))
+	})
+}
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+)
+
+// Сервис синхронизации офлайн-клиентов, протокол описан в sync.go корневого пакета.
+// Мутации применяются по одной, каждая в своей транзакции через сервисы списков и задач,
+// поэтому для них проверяются права и публикуются события, как для запросов api.
+// Если мутация завершилась внутренней ошибкой, предыдущие мутации остаются примененными:
+// клиент повторяет запрос целиком, повтор безопасен благодаря client_id и правилу
+// "последний записавший побеждает"
+
+// время хранения надгробий, если в конфиге не задано sync.tombstone_retention
+const defaultTombstoneRetention = 30 * 24 * time.Hour
+
+// поля списков и задач, которые можно изменять мутацией update
+var (
+	syncListFields = []string{"title", "description", "position"}
+	syncItemFields = []string{"title", "description", "done", "position", "due_date", "priority", "labels"}
+)
+
+// содержимое курсора синхронизации: x - id транзакции, t - время выдачи курсора
+type syncCursor struct {
+	Txid     int64 `json:"x"`
+	IssuedAt int64 `json:"t"`
+}
+
+func encodeSyncCursor(c syncCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSyncCursor(s string) (syncCursor, error) {
+	var c syncCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, todo.ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.Txid <= 0 {
+		return c, todo.ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+type SyncService struct {
+	repos     *repository.Repository
+	retention time.Duration
+}
+
+// конструктор для создания сервиса синхронизации,
+// retention - время хранения надгробий удаленных записей
+func NewSyncService(repos *repository.Repository, retention time.Duration) *SyncService {
+	if retention <= 0 {
+		retention = defaultTombstoneRetention
+	}
+
+	return &SyncService{repos: repos, retention: retention}
+}
+
+// метод возвращает изменения после курсора since, пустой since - полный снимок.
+// Надгробия хранятся retention, поэтому по более старому курсору
+// изменения восстановить нельзя и возвращается полный снимок
+func (s *SyncService) Changes(userId int, since string) (todo.SyncChanges, error) {
+	var cursor syncCursor
+	if since != "" {
+		var err error
+		if cursor, err = decodeSyncCursor(since); err != nil {
+			return todo.SyncChanges{}, err
+		}
+	}
+
+	now := time.Now()
+	reset := false
+	if cursor.Txid > 0 && now.Sub(time.Unix(cursor.IssuedAt, 0)) > s.retention {
+		cursor.Txid, reset = 0, true
+	}
+
+	if err := s.repos.Sync.DeleteTombstonesBefore(now.Add(-s.retention)); err != nil {
+		return todo.SyncChanges{}, err
+	}
+
+	changes, txid, err := s.repos.Sync.Changes(userId, cursor.Txid)
+	if err != nil {
+		return changes, err
+	}
+
+	changes.Reset = reset
+	changes.Cursor = encodeSyncCursor(syncCursor{Txid: txid, IssuedAt: now.Unix()})
+
+	return changes, nil
+}
+
+// метод применяет мутации клиента в порядке их выполнения
+func (s *SyncService) Push(userId int, input todo.SyncPushInput) ([]todo.SyncResult, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+
+	results := make([]todo.SyncResult, 0, len(input.Mutations))
+	for index, mutation := range input.Mutations {
+		result := todo.SyncResult{Index: index, ClientId: mutation.ClientId}
+
+		// время изменения на клиенте не может быть позже времени сервера
+		if now := time.Now(); mutation.ChangedAt.After(now) {
+			mutation.ChangedAt = now
+		}
+
+		err := s.repos.Transaction(func(repos *repository.Repository) error {
+			return applyMutation(repos, userId, mutation, &result)
+		})
+
+		var validationErr *todo.ValidationError
+		switch {
+		case err == nil:
+		case errors.As(err, &validationErr):
+			result.Status, result.Errors = todo.SyncStatusInvalid, validationErr.Fields
+		case errors.Is(err, todo.ErrNotFound):
+			result.Status = todo.SyncStatusNotFound
+		case errors.Is(err, todo.ErrForbidden):
+			result.Status = todo.SyncStatusForbidden
+		default:
+			return nil, err
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// функция применяет мутацию с репозиториями транзакции и заполняет результат
+func applyMutation(repos *repository.Repository, userId int, mutation todo.SyncMutation, result *todo.SyncResult) error {
+	if mutation.Op == todo.SyncOpCreate {
+		return applyCreate(repos, userId, mutation, result)
+	}
+
+	id, err := resolveSyncId(repos, userId, mutation.Entity, mutation.Id, mutation.ClientId)
+	if err != nil {
+		return err
+	}
+	result.Id = id
+
+	if mutation.Op == todo.SyncOpDelete {
+		result.Status = todo.SyncStatusApplied
+		if mutation.Entity == todo.SyncEntityList {
+			return NewTodoListSevice(repos).DeleteList(userId, id, nil)
+		}
+		return newTodoItemService(repos).DeleteItem(userId, id, nil)
+	}
+
+	return applyUpdate(repos, userId, id, mutation, result)
+}
+
+// создание записи выполняется один раз для client_id,
+// повторная мутация возвращает id уже созданной записи
+func applyCreate(repos *repository.Repository, userId int, mutation todo.SyncMutation, result *todo.SyncResult) error {
+	id, err := repos.Sync.ResolveClientId(userId, mutation.Entity, mutation.ClientId)
+	if err == nil {
+		result.Id, result.Status = id, todo.SyncStatusApplied
+		return nil
+	}
+	if !errors.Is(err, todo.ErrNotFound) {
+		return err
+	}
+
+	if mutation.Entity == todo.SyncEntityList {
+		var list todo.TodoList
+		if err := decodeSyncFields(mutation.Fields, &list); err != nil {
+			return err
+		}
+		if id, err = NewTodoListSevice(repos).Create(userId, list); err != nil {
+			return err
+		}
+	} else {
+		listId, err := resolveSyncId(repos, userId, todo.SyncEntityList, mutation.ListId, mutation.ListClientId)
+		if err != nil {
+			return err
+		}
+
+		var item todo.TodoItem
+		if err := decodeSyncFields(mutation.Fields, &item); err != nil {
+			return err
+		}
+		if id, err = newTodoItemService(repos).CreateItem(userId, listId, item); err != nil {
+			return err
+		}
+	}
+
+	result.Id, result.Status = id, todo.SyncStatusApplied
+	return repos.Sync.SaveClientId(userId, mutation.Entity, mutation.ClientId, id)
+}
+
+// изменение применяется к полям, измененным на сервере раньше мутации,
+// остальные поля возвращаются в Ignored
+func applyUpdate(repos *repository.Repository, userId, id int, mutation todo.SyncMutation, result *todo.SyncResult) error {
+	allowed := syncListFields
+	if mutation.Entity == todo.SyncEntityItem {
+		allowed = syncItemFields
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(mutation.Fields, &fields); err != nil {
+		return todo.NewValidationError("fields", todo.CodeInvalid, "fields must be an object")
+	}
+
+	known := make(map[string]bool, len(allowed))
+	for _, field := range allowed {
+		known[field] = true
+	}
+
+	errs := &todo.ValidationError{}
+	for field := range fields {
+		if !known[field] {
+			errs.Add("fields."+field, todo.CodeUnsupported, "field can not be changed by sync")
+		}
+	}
+	if err := errs.Err(); err != nil {
+		return err
+	}
+
+	// проверяем мутацию целиком, чтобы некорректные поля не считались устаревшими
+	if err := validateSyncUpdate(mutation.Entity, mutation.Fields); err != nil {
+		return err
+	}
+
+	var times todo.FieldTimes
+	var err error
+	if mutation.Entity == todo.SyncEntityList {
+		times, err = repos.Sync.ListFieldTimes(userId, id)
+	} else {
+		times, err = repos.Sync.ItemFieldTimes(userId, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	applied := make(map[string]json.RawMessage, len(fields))
+	for field, value := range fields {
+		if mutation.ChangedAt.After(times.Get(field)) {
+			applied[field] = value
+			result.Applied = append(result.Applied, field)
+		} else {
+			result.Ignored = append(result.Ignored, field)
+		}
+	}
+	sort.Strings(result.Applied)
+	sort.Strings(result.Ignored)
+
+	if len(applied) == 0 {
+		result.Status = todo.SyncStatusIgnored
+		return nil
+	}
+	result.Status = todo.SyncStatusApplied
+
+	data, err := json.Marshal(applied)
+	if err != nil {
+		return err
+	}
+
+	if mutation.Entity == todo.SyncEntityList {
+		var input todo.UpdateListInput
+		if err := decodeSyncFields(data, &input); err != nil {
+			return err
+		}
+		if err := NewTodoListSevice(repos).UpdateList(userId, id, input); err != nil {
+			return err
+		}
+	} else {
+		var input todo.UpdateItemInput
+		if err := decodeSyncFields(data, &input); err != nil {
+			return err
+		}
+		if err := newTodoItemService(repos).UpdateItem(userId, id, input); err != nil {
+			return err
+		}
+	}
+
+	return repos.Sync.SetFieldTimes(mutation.Entity, id, result.Applied, mutation.ChangedAt)
+}
+
+// функция проверяет поля мутации update так же, как тело запроса PUT
+func validateSyncUpdate(entity string, fields json.RawMessage) error {
+	if entity == todo.SyncEntityList {
+		var input todo.UpdateListInput
+		if err := decodeSyncFields(fields, &input); err != nil {
+			return err
+		}
+		return input.Validate()
+	}
+
+	var input todo.UpdateItemInput
+	if err := decodeSyncFields(fields, &input); err != nil {
+		return err
+	}
+	return input.Validate()
+}
+
+// функция возвращает id записи на сервере по id или client_id мутации
+func resolveSyncId(repos *repository.Repository, userId int, entity string, id *int, clientId string) (int, error) {
+	if id != nil {
+		return *id, nil
+	}
+
+	return repos.Sync.ResolveClientId(userId, entity, clientId)
+}
+
+func decodeSyncFields(fields json.RawMessage, dest interface{}) error {
+	if err := json.Unmarshal(fields, dest); err != nil {
+		return todo.NewValidationError("fields", todo.CodeInvalid, err.Error())
+	}
+	return nil
+}
@@ -9,40 +9,188 @@ import (
 // передаем данные на уровень ниже
 
 // структура сервиса по работе с задачами
-// содержит два репозитория, для связи задач с их списками
+// содержит два репозитория, для связи задач с их списками,
+// repos используется для изменений, которые публикуют события (event.go)
 type TodoItemService struct {
 	repo     repository.TodoItem
 	listRepo repository.TodoList
+	repos    *repository.Repository
 }
 
 // конструктор для создания сервиса по работе с задачами
-func newTodoItemService(repo repository.TodoItem, listRepo repository.TodoList) *TodoItemService {
-	return &TodoItemService{repo: repo, listRepo: listRepo}
+func newTodoItemService(repos *repository.Repository) *TodoItemService {
+	return &TodoItemService{repo: repos.TodoItem, listRepo: repos.TodoList, repos: repos}
 }
 
 func (s *TodoItemService) CreateItem(userId, listId int, item todo.TodoItem) (int, error) {
+	// валидируем и нормализуем данные запроса
+	if err := item.Validate(); err != nil {
+		return 0, err
+	}
+
 	// осуществляем проверку на наличие соотвтетствующего списка
-	_, err := s.listRepo.GetById(userId, listId)
-	if err != nil {
-		// если лист не существует
+	// и права пользователя добавлять в него задачи
+	if err := s.listRepo.CheckAccess(userId, listId, todo.RoleEditor); err != nil {
+		// если лист не существует или роль пользователя слабее editor
 		return 0, err
 	}
 
-	return s.repo.CreateItem(listId, item)
+	// задача и событие о ней сохраняются в одной транзакции
+	var id int
+	err := s.repos.Transaction(func(repos *repository.Repository) error {
+		var err error
+		if id, err = repos.TodoItem.CreateItem(userId, listId, item); err != nil {
+			return err
+		}
+		return repos.Event.Publish(todo.NewItemEvent(todo.EventItemCreated, userId, listId, id))
+	})
+
+	return id, err
+}
+
+// создание задачи, выполненной сразу при создании (календарь, импорт).
+// Задача создается невыполненной, поэтому затем отмечается выполненной
+func (s *TodoItemService) createWithDone(userId, listId int, item todo.TodoItem, done bool) (int, error) {
+	id, err := s.CreateItem(userId, listId, item)
+	if err != nil || !done {
+		return id, err
+	}
+
+	return id, s.UpdateItem(userId, id, todo.UpdateItemInput{Done: &done})
 }
 
-func (s *TodoItemService) GetAllItems(userId, listId int) ([]todo.TodoItem, error) {
-	return s.repo.GetAllItems(userId, listId)
+func (s *TodoItemService) GetAllItems(userId, listId int, query todo.PageQuery) ([]todo.TodoItem, string, error) {
+	return s.repo.GetAllItems(userId, listId, query)
 }
 
 func (s *TodoItemService) GetItemById(userId, itemId int) (todo.TodoItem, error) {
 	return s.repo.GetItemById(userId, itemId)
 }
 
-func (s *TodoItemService) DeleteItem(userId, itemId int) error {
-	return s.repo.DeleteItem(userId, itemId)
+// список задачи определяется до удаления, пока существует связь задачи со списком
+func (s *TodoItemService) DeleteItem(userId, itemId int, version *int) error {
+	return s.repos.Transaction(func(repos *repository.Repository) error {
+		states, err := repos.Event.ItemStates([]int{itemId})
+		if err != nil {
+			return err
+		}
+		if err := repos.TodoItem.DeleteItem(userId, itemId, version); err != nil {
+			return err
+		}
+		return publishItemEvent(repos, todo.EventItemDeleted, userId, itemId, states)
+	})
 }
 
 func (s *TodoItemService) UpdateItem(userId, itemId int, input todo.UpdateItemInput) error {
-	return s.repo.UpdateItem(userId, itemId, input)
+	// валидируем данные запроса на nil и заданные поля
+	if err := input.Validate(); err != nil {
+		return err
+	}
+
+	return s.repos.Transaction(func(repos *repository.Repository) error {
+		before, err := repos.Event.ItemStates([]int{itemId})
+		if err != nil {
+			return err
+		}
+		if err := repos.TodoItem.UpdateItem(userId, itemId, input); err != nil {
+			return err
+		}
+		return publishUpdatedItem(repos, userId, itemId, before)
+	})
+}
+
+func (s *TodoItemService) BulkItems(userId int, input todo.BulkItemsInput) ([]todo.BulkItemResult, error) {
+	// право добавлять задачи в целевой список при переносе проверяет репозиторий в транзакции операции
+	var results []todo.BulkItemResult
+	err := s.repos.Transaction(func(repos *repository.Repository) error {
+		// состояния задач до выполнения операции: удаление и перенос меняют списки задач
+		states, err := repos.Event.ItemStates(input.Ids)
+		if err != nil {
+			return err
+		}
+
+		if results, err = repos.TodoItem.BulkItems(userId, input); err != nil {
+			return err
+		}
+
+		// события публикуются только для задач, к которым операция применена
+		for _, result := range results {
+			if result.Status != todo.BulkStatusOk {
+				continue
+			}
+
+			var err error
+			switch input.Action {
+			case todo.BulkDelete:
+				err = publishItemEvent(repos, todo.EventItemDeleted, userId, result.Id, states)
+			case todo.BulkMove:
+				event := todo.NewItemEvent(todo.EventItemMoved, userId, input.ListId, result.Id)
+				fromListId := states[result.Id].ListId
+				event.FromListId = &fromListId
+				err = repos.Event.Publish(event)
+			default:
+				err = publishItemEvent(repos, todo.EventItemUpdated, userId, result.Id, states)
+				// задача, которая не была выполнена, переведена в выполненные
+				if err == nil && input.Action == todo.BulkComplete && !states[result.Id].Done {
+					err = publishItemEvent(repos, todo.EventItemCompleted, userId, result.Id, states)
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return results, err
+}
+
+// патч применяется к текущему состоянию задачи внутри транзакции репозитория
+func (s *TodoItemService) PatchItem(userId, itemId int, patch todo.Patch) (todo.TodoItem, error) {
+	var item todo.TodoItem
+	err := s.repos.Transaction(func(repos *repository.Repository) error {
+		before, err := repos.Event.ItemStates([]int{itemId})
+		if err != nil {
+			return err
+		}
+		item, err = repos.TodoItem.PatchItem(userId, itemId, patch.Version, func(item todo.TodoItem) (todo.TodoItem, error) {
+			return patchItem(item, patch)
+		})
+		if err != nil {
+			return err
+		}
+		return publishUpdatedItem(repos, userId, itemId, before)
+	})
+
+	return item, err
+}
+
+// функция публикует событие об изменении задачи в её текущем списке,
+// before - состояние задачи до изменения: если задача стала выполненной,
+// дополнительно публикуется item.completed
+func publishUpdatedItem(repos *repository.Repository, userId, itemId int, before map[int]repository.ItemState) error {
+	states, err := repos.Event.ItemStates([]int{itemId})
+	if err != nil {
+		return err
+	}
+
+	if err := publishItemEvent(repos, todo.EventItemUpdated, userId, itemId, states); err != nil {
+		return err
+	}
+	if states[itemId].Done && !before[itemId].Done {
+		return publishItemEvent(repos, todo.EventItemCompleted, userId, itemId, states)
+	}
+
+	return nil
+}
+
+// функция публикует событие задачи, states - состояния задач по их id
+func publishItemEvent(repos *repository.Repository, eventType string, userId, itemId int, states map[int]repository.ItemState) error {
+	state, ok := states[itemId]
+	if !ok {
+		return nil
+	}
+
+	return repos.Event.Publish(todo.NewItemEvent(eventType, userId, state.ListId, itemId))
 }
@@ -8,35 +8,82 @@ import (
 // Методы сервиса вызывают соответствующие методы из модуля repository,
 // передаем данные на уровень ниже
 
+// repos используется для изменений, которые публикуют события (event.go)
 type TodoListService struct {
-	repo repository.TodoList
+	repo  repository.TodoList
+	repos *repository.Repository
 }
 
 // конструктор для создания сервиса по работе со списками
-func NewTodoListSevice(repo repository.TodoList) *TodoListService {
-	return &TodoListService{repo: repo}
+func NewTodoListSevice(repos *repository.Repository) *TodoListService {
+	return &TodoListService{repo: repos.TodoList, repos: repos}
 }
 
 func (s *TodoListService) Create(userId int, list todo.TodoList) (int, error) {
-	return s.repo.Create(userId, list)
+	// валидируем и нормализуем данные запроса
+	if err := list.Validate(); err != nil {
+		return 0, err
+	}
+
+	// список и событие о нем сохраняются в одной транзакции
+	var id int
+	err := s.repos.Transaction(func(repos *repository.Repository) error {
+		var err error
+		if id, err = repos.TodoList.Create(userId, list); err != nil {
+			return err
+		}
+		return repos.Event.Publish(todo.NewListEvent(todo.EventListCreated, userId, id))
+	})
+
+	return id, err
 }
 
-func (s *TodoListService) GetAll(userId int) ([]todo.TodoList, error) {
-	return s.repo.GetAll(userId)
+func (s *TodoListService) GetAll(userId int, query todo.PageQuery) ([]todo.TodoList, string, error) {
+	return s.repo.GetAll(userId, query)
 }
 
 func (s *TodoListService) GetById(userId, listId int) (todo.TodoList, error) {
 	return s.repo.GetById(userId, listId)
 }
 
-func (s *TodoListService) DeleteList(userId, listId int) error {
-	return s.repo.DeleteList(userId, listId)
+// событие публикуется до удаления, пока известны участники списка,
+// если удаление не выполнено, событие откатывается вместе с транзакцией
+func (s *TodoListService) DeleteList(userId, listId int, version *int) error {
+	return s.repos.Transaction(func(repos *repository.Repository) error {
+		if err := repos.Event.Publish(todo.NewListEvent(todo.EventListDeleted, userId, listId)); err != nil {
+			return err
+		}
+		return repos.TodoList.DeleteList(userId, listId, version)
+	})
 }
 
 func (s *TodoListService) UpdateList(userId, listId int, input todo.UpdateListInput) error {
-	// валидируем данные запроса на nil
+	// валидируем данные запроса на nil и заданные поля
 	if err := input.Validate(); err != nil {
 		return err
 	}
-	return s.repo.UpdateList(userId, listId, input)
+
+	return s.repos.Transaction(func(repos *repository.Repository) error {
+		if err := repos.TodoList.UpdateList(userId, listId, input); err != nil {
+			return err
+		}
+		return repos.Event.Publish(todo.NewListEvent(todo.EventListUpdated, userId, listId))
+	})
+}
+
+// патч применяется к текущему состоянию списка внутри транзакции репозитория
+func (s *TodoListService) PatchList(userId, listId int, patch todo.Patch) (todo.TodoList, error) {
+	var list todo.TodoList
+	err := s.repos.Transaction(func(repos *repository.Repository) error {
+		var err error
+		list, err = repos.TodoList.PatchList(userId, listId, patch.Version, func(list todo.TodoList) (todo.TodoList, error) {
+			return patchList(list, patch)
+		})
+		if err != nil {
+			return err
+		}
+		return repos.Event.Publish(todo.NewListEvent(todo.EventListUpdated, userId, listId))
+	})
+
+	return list, err
 }
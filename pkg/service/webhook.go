@@ -0,0 +1,96 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+)
+
+// Методы сервиса вызывают соответствующие методы из модуля repository,
+// передаем данные на уровень ниже
+
+// длина секрета вебхука в байтах, в hex - 64 символа
+const webhookSecretBytes = 32
+
+// структура сервиса по работе с вебхуками,
+// listRepo используется для проверки доступа к списку вебхука
+type WebhookService struct {
+	repo     repository.Webhook
+	listRepo repository.TodoList
+}
+
+// конструктор для создания сервиса по работе с вебхуками
+func NewWebhookService(repo repository.Webhook, listRepo repository.TodoList) *WebhookService {
+	return &WebhookService{repo: repo, listRepo: listRepo}
+}
+
+// метод создает вебхук и возвращает его вместе с секретом,
+// секрет больше нигде не возвращается
+func (s *WebhookService) Create(userId int, input todo.WebhookInput) (todo.Webhook, error) {
+	if err := input.Validate(); err != nil {
+		return todo.Webhook{}, err
+	}
+
+	// вебхук списка может создать любой его участник
+	if input.ListId != nil {
+		if err := s.listRepo.CheckAccess(userId, *input.ListId, todo.RoleViewer); err != nil {
+			return todo.Webhook{}, err
+		}
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return todo.Webhook{}, err
+	}
+
+	id, err := s.repo.Create(userId, todo.Webhook{Url: input.Url, ListId: input.ListId, Events: input.Events, Secret: secret})
+	if err != nil {
+		return todo.Webhook{}, err
+	}
+
+	webhook, err := s.repo.GetById(userId, id)
+	webhook.Secret = secret
+
+	return webhook, err
+}
+
+func (s *WebhookService) GetAll(userId int) ([]todo.Webhook, error) {
+	return s.repo.GetAll(userId)
+}
+
+func (s *WebhookService) GetById(userId, webhookId int) (todo.Webhook, error) {
+	return s.repo.GetById(userId, webhookId)
+}
+
+func (s *WebhookService) Update(userId, webhookId int, input todo.UpdateWebhookInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+	return s.repo.Update(userId, webhookId, input)
+}
+
+func (s *WebhookService) Delete(userId, webhookId int) error {
+	return s.repo.Delete(userId, webhookId)
+}
+
+func (s *WebhookService) GetDeliveries(userId, webhookId int, query todo.DeliveryQuery) ([]todo.WebhookDelivery, string, error) {
+	if err := query.Validate(); err != nil {
+		return nil, "", err
+	}
+	return s.repo.GetDeliveries(userId, webhookId, query)
+}
+
+func (s *WebhookService) Redeliver(userId, webhookId int, deliveryId int64) (todo.WebhookDelivery, error) {
+	return s.repo.Redeliver(userId, webhookId, deliveryId)
+}
+
+// функция генерирует случайный секрет вебхука
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+	todo "to-do-list"
+	"to-do-list/pkg/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Диспетчер отправляет доставки вебхуков из очереди webhook_deliveries.
+// Доставки создаются в одной транзакции с событием, поэтому событие не теряется
+// при падении приложения. Каждый экземпляр приложения периодически захватывает
+// доставки, время отправки которых наступило, и отправляет их POST-запросом.
+// Неудачная доставка повторяется с экспоненциальной задержкой,
+// после MaxAttempts попыток доставка помечается failed.
+// После DisableAfter неудач подряд вебхук отключается, ожидающие доставки отменяются.
+//
+// Запрос подписывается заголовком
+//
+//	X-Webhook-Signature: t=<unix-время>,v1=<hex HMAC-SHA256(секрет, "<t>.<тело запроса>")>
+//
+// получатель проверяет подпись и отклоняет запросы со старым t для защиты от повтора
+
+const (
+	// значения по умолчанию для параметров из секции webhooks конфига
+	defaultWebhookPollInterval = 5 * time.Second
+	defaultWebhookTimeout      = 10 * time.Second
+	defaultWebhookMaxAttempts  = 8
+	defaultWebhookDisableAfter = 20
+
+	// число доставок, захватываемых за один раз
+	webhookBatchSize = 20
+	// задержка перед первым повтором и максимальная задержка
+	webhookRetryBase = 30 * time.Second
+	webhookRetryMax  = 6 * time.Hour
+	// максимальная длина сохраняемого текста ошибки
+	maxWebhookErrorLength = 512
+)
+
+// параметры доставки вебхуков:
+// PollInterval - интервал проверки очереди, Timeout - время ожидания ответа получателя,
+// MaxAttempts - число попыток доставки, DisableAfter - число неудач подряд,
+// после которого вебхук отключается
+type WebhookConfig struct {
+	PollInterval time.Duration
+	Timeout      time.Duration
+	MaxAttempts  int
+	DisableAfter int
+}
+
+type WebhookDispatcher struct {
+	repo   repository.Webhook
+	cfg    WebhookConfig
+	client *http.Client
+}
+
+// конструктор диспетчера, незаданные параметры заменяются значениями по умолчанию
+func NewWebhookDispatcher(repo repository.Webhook, cfg WebhookConfig) *WebhookDispatcher {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultWebhookPollInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultWebhookTimeout
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultWebhookMaxAttempts
+	}
+	if cfg.DisableAfter <= 0 {
+		cfg.DisableAfter = defaultWebhookDisableAfter
+	}
+
+	return &WebhookDispatcher{
+		repo:   repo,
+		cfg:    cfg,
+		client: newWebhookClient(cfg.Timeout),
+	}
+}
+
+// ошибка подключения к адресу, на который вебхуки не отправляются
+var errWebhookAddress = errors.New("webhook address is not public")
+
+// клиент доставок вебхуков. Адрес получателя проверяется при подключении,
+// после разрешения имени (todo.PublicIP), поэтому имя, которое указывает
+// или начинает указывать на внутренний адрес (DNS rebinding), тоже отклоняется.
+// Перенаправления не выполняются: ответ 3xx считается неудачной доставкой,
+// прокси из окружения не используется, чтобы проверялся адрес получателя
+func newWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !todo.PublicIP(ip) {
+				return fmt.Errorf("%w: %s", errWebhookAddress, host)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: webhookBatchSize,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// метод запускает отправку доставок, работает до отмены ctx.
+// Прерванные при остановке доставки будут повторены после истечения аренды
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.dispatch(ctx)
+		}
+	}
+}
+
+// отправка очереди пачками, пока в ней есть доставки к отправке
+func (d *WebhookDispatcher) dispatch(ctx context.Context) {
+	// аренда с запасом покрывает отправку всей пачки
+	lease := 2 * d.cfg.Timeout
+
+	for ctx.Err() == nil {
+		deliveries, err := d.repo.ClaimDeliveries(webhookBatchSize, lease)
+		if err != nil {
+			logrus.Errorf("webhooks claim: %s", err.Error())
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func(delivery repository.PendingDelivery) {
+				defer wg.Done()
+				d.deliver(ctx, delivery)
+			}(delivery)
+		}
+		wg.Wait()
+
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery repository.PendingDelivery) {
+	statusCode, err := d.send(ctx, delivery)
+	// отправка прервана остановкой приложения, результат не сохраняем
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err == nil {
+		if err := d.repo.CompleteDelivery(delivery.Id, statusCode); err != nil {
+			logrus.Errorf("webhooks complete delivery %d: %s", delivery.Id, err.Error())
+		}
+		return
+	}
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+
+	message := truncateError(err, maxWebhookErrorLength)
+	if err := d.repo.FailDelivery(delivery.Id, code, message, d.retryAt(delivery.Attempts+1), d.cfg.DisableAfter); err != nil {
+		logrus.Errorf("webhooks fail delivery %d: %s", delivery.Id, err.Error())
+	}
+}
+
+// метод отправляет доставку, успешной считается доставка с ответом 2xx
+func (d *WebhookDispatcher) send(ctx context.Context, delivery repository.PendingDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "to-do-list-webhooks")
+	req.Header.Set("X-Webhook-Id", strconv.Itoa(delivery.WebhookId))
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Signature", fmt.Sprintf("t=%d,v1=%s", timestamp, webhookSignature(delivery.Secret, timestamp, delivery.Payload)))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// тело ответа не используется, дочитываем его для повторного использования соединения
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// время следующей попытки после attempts неудачных,
+// nil - попытки исчерпаны
+func (d *WebhookDispatcher) retryAt(attempts int) *time.Time {
+	if attempts >= d.cfg.MaxAttempts {
+		return nil
+	}
+
+	delay := webhookRetryBase
+	for i := 1; i < attempts && delay < webhookRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMax {
+		delay = webhookRetryMax
+	}
+
+	retryAt := time.Now().Add(delay)
+	return &retryAt
+}
+
+// функция вычисляет подпись тела запроса
+func webhookSignature(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
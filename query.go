@@ -0,0 +1,91 @@
+package todo
+
+import (
+	"errors"
+	"time"
+)
+
+// Описываем параметры выборки для коллекций (списков и задач):
+// курсорную пагинацию, сортировку и фильтры.
+// Структура заполняется из строки запроса клиента (form-теги).
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 100
+
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// ошибка возвращается репозиторием, если курсор поврежден или относится к другой сортировке
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// поля, по которым разрешена сортировка списков и задач.
+// created - порядок создания (по id), updated - время последнего изменения (то же, что updated_at)
+var (
+	ListSortFields = []string{"created", "updated", "title", "position", "created_at", "updated_at"}
+	ItemSortFields = []string{"created", "updated", "title", "due_date", "position", "created_at", "updated_at", "completed_at"}
+)
+
+// Фильтры по времени задаются в формате RFC 3339,
+// нижняя граница (*_after) включается в выборку, верхняя (*_before) - нет.
+// Done, CompletedAfter и CompletedBefore применяются только к задачам
+type PageQuery struct {
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
+	Sort   string `form:"sort"`
+	Order  string `form:"order"`
+	Title  string `form:"title"`
+	Done   *bool  `form:"done"`
+
+	CreatedAfter    *time.Time `form:"created_after"`
+	CreatedBefore   *time.Time `form:"created_before"`
+	UpdatedAfter    *time.Time `form:"updated_after"`
+	UpdatedBefore   *time.Time `form:"updated_before"`
+	CompletedAfter  *time.Time `form:"completed_after"`
+	CompletedBefore *time.Time `form:"completed_before"`
+	CreatedBy       *int       `form:"created_by"`
+	UpdatedBy       *int       `form:"updated_by"`
+}
+
+// метод валидации параметров выборки, проставляет значения по умолчанию
+// вызывается в обработчиках при разборе параметров запроса (handler/pagination.go),
+// содержимое курсора проверяет репозиторий при построении запроса
+func (q *PageQuery) Validate(sortFields []string) error {
+	if q.Limit == 0 {
+		q.Limit = DefaultPageLimit
+	}
+	if q.Limit < 0 || q.Limit > MaxPageLimit {
+		return NewValidationError("limit", CodeOutOfRange, "limit must be between 1 and 100")
+	}
+
+	if q.Sort == "" {
+		q.Sort = sortFields[0]
+	}
+	if !contains(sortFields, q.Sort) {
+		return NewValidationError("sort", CodeUnsupported, "unsupported sort field")
+	}
+
+	if q.Order == "" {
+		q.Order = SortAsc
+	}
+	if q.Order != SortAsc && q.Order != SortDesc {
+		return NewValidationError("order", CodeUnsupported, "order must be asc or desc")
+	}
+
+	errs := &ValidationError{}
+	errs.timeRange("created_after", "created_before", q.CreatedAfter, q.CreatedBefore)
+	errs.timeRange("updated_after", "updated_before", q.UpdatedAfter, q.UpdatedBefore)
+	errs.timeRange("completed_after", "completed_before", q.CompletedAfter, q.CompletedBefore)
+
+	return errs.Err()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,6 @@
+ALTER TABLE todo_items
+    DROP COLUMN due_date,
+    DROP COLUMN position;
+
+ALTER TABLE todo_lists
+    DROP COLUMN position;
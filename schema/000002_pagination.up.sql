@@ -0,0 +1,6 @@
+ALTER TABLE todo_lists
+    ADD COLUMN position int not null default 0;
+
+ALTER TABLE todo_items
+    ADD COLUMN position int not null default 0,
+    ADD COLUMN due_date timestamptz;
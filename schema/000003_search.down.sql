@@ -0,0 +1,13 @@
+DROP TRIGGER todo_items_search_vector ON todo_items;
+
+DROP TRIGGER todo_lists_search_vector ON todo_lists;
+
+ALTER TABLE todo_items
+    DROP COLUMN search_vector;
+
+ALTER TABLE todo_lists
+    DROP COLUMN search_vector;
+
+DROP FUNCTION todo_search_vector_update();
+
+DROP FUNCTION todo_search_vector(text, text);
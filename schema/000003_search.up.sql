@@ -0,0 +1,45 @@
+-- поисковые векторы строятся сразу для русского и английского стемминга,
+-- название имеет больший вес (A), чем описание (B)
+CREATE FUNCTION todo_search_vector(title text, description text) RETURNS tsvector AS
+$$
+SELECT setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+       setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+       setweight(to_tsvector('russian', coalesce(description, '')), 'B') ||
+       setweight(to_tsvector('english', coalesce(description, '')), 'B')
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE FUNCTION todo_search_vector_update() RETURNS trigger AS
+$$
+BEGIN
+    NEW.search_vector := todo_search_vector(NEW.title, NEW.description);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE todo_lists
+    ADD COLUMN search_vector tsvector;
+
+ALTER TABLE todo_items
+    ADD COLUMN search_vector tsvector;
+
+UPDATE todo_lists
+SET search_vector = todo_search_vector(title, description);
+
+UPDATE todo_items
+SET search_vector = todo_search_vector(title, description);
+
+CREATE TRIGGER todo_lists_search_vector
+    BEFORE INSERT OR UPDATE OF title, description
+    ON todo_lists
+    FOR EACH ROW
+EXECUTE FUNCTION todo_search_vector_update();
+
+CREATE TRIGGER todo_items_search_vector
+    BEFORE INSERT OR UPDATE OF title, description
+    ON todo_items
+    FOR EACH ROW
+EXECUTE FUNCTION todo_search_vector_update();
+
+CREATE INDEX todo_lists_search_vector_idx ON todo_lists USING gin (search_vector);
+
+CREATE INDEX todo_items_search_vector_idx ON todo_items USING gin (search_vector);
@@ -0,0 +1,7 @@
+DROP TABLE saved_filters;
+
+DROP INDEX todo_items_labels_idx;
+
+ALTER TABLE todo_items
+    DROP COLUMN labels,
+    DROP COLUMN priority;
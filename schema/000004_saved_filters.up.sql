@@ -0,0 +1,13 @@
+ALTER TABLE todo_items
+    ADD COLUMN priority smallint not null default 0,
+    ADD COLUMN labels   text[]   not null default '{}';
+
+CREATE INDEX todo_items_labels_idx ON todo_items USING gin (labels);
+
+CREATE TABLE saved_filters
+(
+    id      serial                                      not null unique,
+    user_id int references users (id) on delete cascade not null,
+    name    varchar(255)                                not null,
+    filter  jsonb                                       not null
+);
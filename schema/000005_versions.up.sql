@@ -0,0 +1,5 @@
+ALTER TABLE todo_lists
+    ADD COLUMN version int not null default 1;
+
+ALTER TABLE todo_items
+    ADD COLUMN version int not null default 1;
@@ -0,0 +1,12 @@
+-- status_code и response_body заполняются после завершения обработки запроса,
+-- до этого ключ считается занятым запросом, который еще выполняется
+CREATE TABLE idempotency_keys
+(
+    user_id       int references users (id) on delete cascade not null,
+    key           varchar(255)                                not null,
+    request_hash  varchar(64)                                 not null,
+    status_code   int,
+    response_body bytea,
+    created_at    timestamptz                                 not null default now(),
+    primary key (user_id, key)
+);
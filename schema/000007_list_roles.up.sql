@@ -0,0 +1,5 @@
+-- роль пользователя в списке: owner - создатель, editor и viewer - участники,
+-- которым owner открыл доступ. Существующие связи принадлежат создателям списков
+ALTER TABLE users_lists
+    ADD COLUMN role varchar(16) not null default 'owner'
+        CONSTRAINT users_lists_role_check CHECK (role IN ('owner', 'editor', 'viewer'));
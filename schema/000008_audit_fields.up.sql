@@ -0,0 +1,42 @@
+-- время создания и последнего изменения записей и их авторы.
+-- Существующим записям проставляется время миграции,
+-- авторами списков и задач считаются владельцы списков
+ALTER TABLE users
+    ADD COLUMN created_at timestamptz not null default now(),
+    ADD COLUMN updated_at timestamptz not null default now(),
+    ADD COLUMN created_by int references users (id) on delete set null,
+    ADD COLUMN updated_by int references users (id) on delete set null;
+
+UPDATE users
+SET created_by = id,
+    updated_by = id;
+
+ALTER TABLE todo_lists
+    ADD COLUMN created_at timestamptz not null default now(),
+    ADD COLUMN updated_at timestamptz not null default now(),
+    ADD COLUMN created_by int references users (id) on delete set null,
+    ADD COLUMN updated_by int references users (id) on delete set null;
+
+UPDATE todo_lists tl
+SET created_by = ul.user_id,
+    updated_by = ul.user_id
+FROM users_lists ul
+WHERE ul.list_id = tl.id
+  AND ul.role = 'owner';
+
+-- completed_at - время перевода задачи в выполненные,
+-- для уже выполненных задач оно неизвестно
+ALTER TABLE todo_items
+    ADD COLUMN created_at   timestamptz not null default now(),
+    ADD COLUMN updated_at   timestamptz not null default now(),
+    ADD COLUMN created_by   int references users (id) on delete set null,
+    ADD COLUMN updated_by   int references users (id) on delete set null,
+    ADD COLUMN completed_at timestamptz;
+
+UPDATE todo_items ti
+SET created_by = tl.created_by,
+    updated_by = tl.created_by
+FROM lists_items li,
+     todo_lists tl
+WHERE li.item_id = ti.id
+  AND tl.id = li.list_id;
@@ -0,0 +1,16 @@
+-- журнал событий для потока /api/stream и повтора пропущенных событий по Last-Event-ID.
+-- Ссылок на списки и задачи нет: события хранятся и после удаления записей,
+-- recipients - участники списка на момент публикации
+CREATE TABLE events
+(
+    id           bigserial primary key,
+    type         varchar(32) not null,
+    list_id      int         not null,
+    item_id      int,
+    from_list_id int,
+    user_id      int         not null,
+    recipients   int[]       not null,
+    created_at   timestamptz not null default now()
+);
+
+CREATE INDEX events_created_at_idx ON events (created_at);
@@ -0,0 +1,41 @@
+-- вебхуки пользователей, list_id ограничивает вебхук одним списком,
+-- events - фильтр по типам событий (пустой массив - все события)
+CREATE TABLE webhooks
+(
+    id            serial primary key,
+    user_id       int references users (id) on delete cascade      not null,
+    list_id       int references todo_lists (id) on delete cascade,
+    url           varchar(2048)                                    not null,
+    secret        varchar(64)                                      not null,
+    events        text[]                                           not null default '{}',
+    active        boolean                                          not null default true,
+    failure_count int                                              not null default 0,
+    disabled_at   timestamptz,
+    created_at    timestamptz                                      not null default now(),
+    updated_at    timestamptz                                      not null default now()
+);
+
+CREATE INDEX webhooks_user_id_idx ON webhooks (user_id);
+
+-- очередь и журнал доставок. Доставки создаются в одной транзакции с событием,
+-- next_attempt_at - время следующей попытки, при захвате доставки обработчиком
+-- сдвигается на время аренды, чтобы доставку не взял другой экземпляр
+CREATE TABLE webhook_deliveries
+(
+    id               bigserial primary key,
+    webhook_id       int references webhooks (id) on delete cascade not null,
+    event_id         bigint                                         not null,
+    event_type       varchar(32)                                    not null,
+    payload          jsonb                                          not null,
+    status           varchar(16)                                    not null default 'pending'
+        CONSTRAINT webhook_deliveries_status_check CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts         int                                            not null default 0,
+    next_attempt_at  timestamptz                                             default now(),
+    last_status_code int,
+    last_error       text,
+    created_at       timestamptz                                    not null default now(),
+    delivered_at     timestamptz
+);
+
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, id);
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,23 @@
+-- исходящие сообщения о событиях для внешних получателей (transactional outbox).
+-- Сообщение пишется в одной транзакции с изменением и событием, поэтому
+-- не теряется при падении приложения сразу после фиксации транзакции.
+-- aggregate_type и aggregate_id определяют порядок: сообщения одного списка
+-- или одной задачи публикуются строго в порядке id
+CREATE TABLE outbox
+(
+    id              bigserial primary key,
+    event_id        bigint      not null,
+    aggregate_type  varchar(16) not null,
+    aggregate_id    int         not null,
+    type            varchar(32) not null,
+    payload         jsonb       not null,
+    attempts        int         not null default 0,
+    next_attempt_at timestamptz not null default now(),
+    last_error      text,
+    created_at      timestamptz not null default now(),
+    published_at    timestamptz
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (id) WHERE published_at IS NULL;
+CREATE INDEX outbox_aggregate_idx ON outbox (aggregate_type, aggregate_id, id) WHERE published_at IS NULL;
+CREATE INDEX outbox_published_at_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
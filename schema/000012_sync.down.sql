@@ -0,0 +1,29 @@
+DROP TABLE sync_client_ids;
+
+DROP TRIGGER lists_items_tombstone ON lists_items;
+DROP TRIGGER users_lists_tombstone ON users_lists;
+DROP TRIGGER todo_items_tombstone ON todo_items;
+DROP TRIGGER todo_lists_tombstone ON todo_lists;
+DROP FUNCTION sync_tombstone();
+
+DROP TABLE sync_tombstones;
+
+DROP TRIGGER lists_items_sync ON lists_items;
+DROP TRIGGER users_lists_sync ON users_lists;
+DROP TRIGGER todo_items_sync ON todo_items;
+DROP TRIGGER todo_lists_sync ON todo_lists;
+DROP FUNCTION sync_track_changes();
+
+ALTER TABLE lists_items
+    DROP COLUMN sync_txid;
+
+ALTER TABLE users_lists
+    DROP COLUMN sync_txid;
+
+ALTER TABLE todo_items
+    DROP COLUMN sync_txid,
+    DROP COLUMN field_updated_at;
+
+ALTER TABLE todo_lists
+    DROP COLUMN sync_txid,
+    DROP COLUMN field_updated_at;
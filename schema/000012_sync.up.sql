@@ -0,0 +1,150 @@
+-- протокол синхронизации для офлайн-клиентов (GET/POST /api/sync).
+-- sync_txid - id транзакции последнего изменения строки (txid_current()),
+-- курсор синхронизации - нижняя граница незавершенных транзакций на момент выборки,
+-- поэтому изменения, зафиксированные позже выборки, не пропускаются.
+-- Колонки заполняются триггерами, чтобы их не нужно было обновлять во всех запросах
+ALTER TABLE todo_lists
+    ADD COLUMN sync_txid        bigint not null default txid_current(),
+    ADD COLUMN field_updated_at jsonb  not null default '{}';
+
+ALTER TABLE todo_items
+    ADD COLUMN sync_txid        bigint not null default txid_current(),
+    ADD COLUMN field_updated_at jsonb  not null default '{}';
+
+ALTER TABLE users_lists
+    ADD COLUMN sync_txid bigint not null default txid_current();
+
+ALTER TABLE lists_items
+    ADD COLUMN sync_txid bigint not null default txid_current();
+
+-- триггер запоминает id транзакции изменения, а для списков и задач -
+-- время изменения каждого поля из аргументов триггера (field_updated_at).
+-- Время поля не перезаписывается, если запрос сам задал его
+-- (так POST /api/sync сохраняет время изменения на клиенте)
+CREATE FUNCTION sync_track_changes() RETURNS trigger AS
+$$
+DECLARE
+    field   text;
+    changed jsonb := '{}';
+BEGIN
+    NEW.sync_txid := txid_current();
+
+    IF TG_OP = 'UPDATE' AND TG_NARGS > 0 THEN
+        FOREACH field IN ARRAY TG_ARGV
+            LOOP
+                IF (to_jsonb(NEW) -> field) IS DISTINCT FROM (to_jsonb(OLD) -> field)
+                    AND (NEW.field_updated_at -> field) IS NOT DISTINCT FROM (OLD.field_updated_at -> field) THEN
+                    changed := changed || jsonb_build_object(field, now());
+                END IF;
+            END LOOP;
+        NEW.field_updated_at := NEW.field_updated_at || changed;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER todo_lists_sync
+    BEFORE INSERT OR UPDATE
+    ON todo_lists
+    FOR EACH ROW
+EXECUTE PROCEDURE sync_track_changes('title', 'description', 'position');
+
+CREATE TRIGGER todo_items_sync
+    BEFORE INSERT OR UPDATE
+    ON todo_items
+    FOR EACH ROW
+EXECUTE PROCEDURE sync_track_changes('title', 'description', 'done', 'position', 'due_date', 'priority', 'labels');
+
+CREATE TRIGGER users_lists_sync
+    BEFORE INSERT OR UPDATE
+    ON users_lists
+    FOR EACH ROW
+EXECUTE PROCEDURE sync_track_changes();
+
+CREATE TRIGGER lists_items_sync
+    BEFORE INSERT OR UPDATE
+    ON lists_items
+    FOR EACH ROW
+EXECUTE PROCEDURE sync_track_changes();
+
+-- надгробия удаленных записей: entity - list, item или member
+-- (для member entity_id - id пользователя, исключенного из списка).
+-- recipients - участники списка на момент удаления
+CREATE TABLE sync_tombstones
+(
+    id         bigserial primary key,
+    entity     varchar(16) not null,
+    entity_id  int         not null,
+    list_id    int         not null,
+    recipients int[]       not null,
+    sync_txid  bigint      not null default txid_current(),
+    deleted_at timestamptz not null default now()
+);
+
+CREATE INDEX sync_tombstones_recipients_idx ON sync_tombstones USING gin (recipients);
+CREATE INDEX sync_tombstones_deleted_at_idx ON sync_tombstones (deleted_at);
+
+-- надгробия создаются до удаления, пока связи с участниками списка существуют.
+-- Перенос задачи в другой список - надгробие для участников исходного списка
+CREATE FUNCTION sync_tombstone() RETURNS trigger AS
+$$
+BEGIN
+    IF TG_TABLE_NAME = 'todo_lists' THEN
+        INSERT INTO sync_tombstones (entity, entity_id, list_id, recipients)
+        VALUES ('list', OLD.id, OLD.id, ARRAY(SELECT user_id FROM users_lists WHERE list_id = OLD.id));
+    ELSIF TG_TABLE_NAME = 'todo_items' THEN
+        INSERT INTO sync_tombstones (entity, entity_id, list_id, recipients)
+        SELECT 'item', OLD.id, li.list_id, ARRAY(SELECT user_id FROM users_lists WHERE list_id = li.list_id)
+        FROM lists_items li
+        WHERE li.item_id = OLD.id;
+    ELSIF TG_TABLE_NAME = 'users_lists' THEN
+        INSERT INTO sync_tombstones (entity, entity_id, list_id, recipients)
+        VALUES ('member', OLD.user_id, OLD.list_id, ARRAY(SELECT user_id FROM users_lists WHERE list_id = OLD.list_id));
+    ELSIF TG_TABLE_NAME = 'lists_items' THEN
+        IF NEW.list_id IS DISTINCT FROM OLD.list_id THEN
+            INSERT INTO sync_tombstones (entity, entity_id, list_id, recipients)
+            VALUES ('item', OLD.item_id, OLD.list_id, ARRAY(SELECT user_id FROM users_lists WHERE list_id = OLD.list_id));
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER todo_lists_tombstone
+    BEFORE DELETE
+    ON todo_lists
+    FOR EACH ROW
+EXECUTE PROCEDURE sync_tombstone();
+
+CREATE TRIGGER todo_items_tombstone
+    BEFORE DELETE
+    ON todo_items
+    FOR EACH ROW
+EXECUTE PROCEDURE sync_tombstone();
+
+CREATE TRIGGER users_lists_tombstone
+    BEFORE DELETE
+    ON users_lists
+    FOR EACH ROW
+EXECUTE PROCEDURE sync_tombstone();
+
+CREATE TRIGGER lists_items_tombstone
+    BEFORE UPDATE
+    ON lists_items
+    FOR EACH ROW
+EXECUTE PROCEDURE sync_tombstone();
+
+-- соответствие id, сгенерированных клиентом при создании записей офлайн, id на сервере.
+-- Повторная отправка той же мутации не создает запись второй раз
+CREATE TABLE sync_client_ids
+(
+    user_id    int references users (id) on delete cascade not null,
+    entity     varchar(16)                                 not null,
+    client_id  varchar(64)                                 not null,
+    entity_id  int                                         not null,
+    created_at timestamptz                                 not null default now(),
+    primary key (user_id, entity, client_id)
+);
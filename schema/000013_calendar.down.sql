@@ -0,0 +1,5 @@
+ALTER TABLE todo_items
+    DROP COLUMN ical_uid,
+    DROP COLUMN ical_name;
+
+DROP TABLE calendar_feeds;
@@ -0,0 +1,18 @@
+-- секретные ссылки на ленты задач списков в формате iCalendar (GET /calendar/feeds/<token>.ics).
+-- Хранится только sha-256 токена, у пользователя одна ссылка на список:
+-- создание новой ссылки отзывает предыдущую
+CREATE TABLE calendar_feeds
+(
+    id         serial primary key,
+    user_id    int references users (id) on delete cascade      not null,
+    list_id    int references todo_lists (id) on delete cascade not null,
+    token_hash varchar(64)                                      not null unique,
+    created_at timestamptz                                      not null default now(),
+    unique (user_id, list_id)
+);
+
+-- UID компонента VTODO и имя ресурса CalDAV, под которым клиент сохранил задачу.
+-- Для задач, созданных не через CalDAV, колонки пустые, UID и имя строятся из id задачи
+ALTER TABLE todo_items
+    ADD COLUMN ical_uid  varchar(255),
+    ADD COLUMN ical_name varchar(255);
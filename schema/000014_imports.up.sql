@@ -0,0 +1,29 @@
+-- задания импорта данных из других сервисов (POST /api/import).
+-- data - загруженный файл, удаляется после завершения задания.
+-- Задание выполняется по одному списку в транзакции, processed_lists - число
+-- полностью импортированных списков: после падения приложения задание продолжается с него.
+-- locked_until - время аренды задания обработчиком, чтобы его не взял другой экземпляр
+CREATE TABLE import_jobs
+(
+    id              serial primary key,
+    user_id         int references users (id) on delete cascade not null,
+    source          varchar(32)                                 not null,
+    file_name       varchar(255)                                not null default '',
+    data            bytea,
+    status          varchar(16)                                 not null default 'pending'
+        CONSTRAINT import_jobs_status_check CHECK (status IN ('pending', 'running', 'succeeded', 'failed')),
+    total_lists     int                                         not null default 0,
+    processed_lists int                                         not null default 0,
+    total_items     int                                         not null default 0,
+    processed_items int                                         not null default 0,
+    imported_items  int                                         not null default 0,
+    errors          jsonb                                       not null default '[]',
+    error           text,
+    locked_until    timestamptz,
+    created_at      timestamptz                                 not null default now(),
+    started_at      timestamptz,
+    finished_at     timestamptz
+);
+
+CREATE INDEX import_jobs_user_id_idx ON import_jobs (user_id, id);
+CREATE INDEX import_jobs_active_idx ON import_jobs (id) WHERE status IN ('pending', 'running');
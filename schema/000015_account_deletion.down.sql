@@ -0,0 +1,5 @@
+DROP TABLE audit_log;
+
+ALTER TABLE users
+    DROP COLUMN deletion_requested_at,
+    DROP COLUMN deletion_scheduled_at;
@@ -0,0 +1,20 @@
+-- удаление аккаунта с отсрочкой (DELETE /api/me). До deletion_scheduled_at
+-- удаление можно отменить, после него аккаунт удаляет фоновый обработчик
+ALTER TABLE users
+    ADD COLUMN deletion_requested_at timestamptz,
+    ADD COLUMN deletion_scheduled_at timestamptz;
+
+CREATE INDEX users_deletion_scheduled_at_idx ON users (deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
+
+-- журнал аудита. Ссылки на пользователя нет: записи хранятся и после удаления аккаунта,
+-- поэтому персональных данных (имени, логина) они не содержат
+CREATE TABLE audit_log
+(
+    id         bigserial primary key,
+    action     varchar(64) not null,
+    user_id    int         not null,
+    details    jsonb       not null default '{}',
+    created_at timestamptz not null default now()
+);
+
+CREATE INDEX audit_log_user_id_idx ON audit_log (user_id, id);
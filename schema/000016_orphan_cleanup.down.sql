@@ -0,0 +1,3 @@
+DROP TRIGGER users_lists_delete_orphans ON users_lists;
+DROP TRIGGER lists_items_delete_orphans ON lists_items;
+DROP FUNCTION delete_orphans();
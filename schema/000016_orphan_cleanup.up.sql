@@ -0,0 +1,36 @@
+-- списки и задачи связаны с пользователями и друг с другом через таблицы связей,
+-- каскадное удаление которых удаляет только связи. Триггеры удаляют запись,
+-- у которой не осталось связей: задачу без списка и список без участников.
+-- Поэтому удаление списка удаляет его задачи, а удаление пользователя -
+-- списки, в которых не осталось участников.
+-- Записи, оставшиеся без связей до миграции, удаляет команда gc-orphans
+CREATE FUNCTION delete_orphans() RETURNS trigger AS
+$$
+BEGIN
+    IF TG_TABLE_NAME = 'lists_items' THEN
+        DELETE
+        FROM todo_items ti
+        WHERE ti.id = OLD.item_id
+          AND NOT EXISTS(SELECT 1 FROM lists_items li WHERE li.item_id = ti.id);
+    ELSIF TG_TABLE_NAME = 'users_lists' THEN
+        DELETE
+        FROM todo_lists tl
+        WHERE tl.id = OLD.list_id
+          AND NOT EXISTS(SELECT 1 FROM users_lists ul WHERE ul.list_id = tl.id);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER lists_items_delete_orphans
+    AFTER DELETE
+    ON lists_items
+    FOR EACH ROW
+EXECUTE PROCEDURE delete_orphans();
+
+CREATE TRIGGER users_lists_delete_orphans
+    AFTER DELETE
+    ON users_lists
+    FOR EACH ROW
+EXECUTE PROCEDURE delete_orphans();
@@ -0,0 +1,68 @@
+-- удаленные дубликаты связей не восстанавливаются
+DROP INDEX calendar_feeds_list_id_idx;
+DROP INDEX webhooks_list_id_idx;
+DROP INDEX saved_filters_user_id_idx;
+
+DROP INDEX lists_items_item_id_idx;
+
+ALTER TABLE lists_items
+    DROP CONSTRAINT lists_items_list_id_item_id_key;
+
+DROP INDEX users_lists_list_id_idx;
+
+ALTER TABLE users_lists
+    DROP CONSTRAINT users_lists_user_id_list_id_key;
+
+CREATE TEMPORARY TABLE referencing_keys ON COMMIT DROP AS
+SELECT conrelid::regclass AS table_name, conname AS name, pg_get_constraintdef(oid) AS definition
+FROM pg_constraint
+WHERE contype = 'f'
+  AND confrelid IN ('users'::regclass, 'todo_lists'::regclass, 'todo_items'::regclass);
+
+DO
+$$
+    DECLARE
+        fk record;
+    BEGIN
+        FOR fk IN SELECT * FROM referencing_keys
+            LOOP
+                EXECUTE format('ALTER TABLE %s DROP CONSTRAINT %I', fk.table_name, fk.name);
+            END LOOP;
+    END;
+$$;
+
+ALTER TABLE saved_filters
+    DROP CONSTRAINT saved_filters_pkey,
+    ADD CONSTRAINT saved_filters_id_key UNIQUE (id);
+
+ALTER TABLE lists_items
+    DROP CONSTRAINT lists_items_pkey,
+    ADD CONSTRAINT lists_items_id_key UNIQUE (id);
+
+ALTER TABLE users_lists
+    DROP CONSTRAINT users_lists_pkey,
+    ADD CONSTRAINT users_lists_id_key UNIQUE (id);
+
+ALTER TABLE todo_items
+    DROP CONSTRAINT todo_items_pkey,
+    ADD CONSTRAINT todo_items_id_key UNIQUE (id);
+
+ALTER TABLE todo_lists
+    DROP CONSTRAINT todo_lists_pkey,
+    ADD CONSTRAINT todo_lists_id_key UNIQUE (id);
+
+ALTER TABLE users
+    DROP CONSTRAINT users_pkey,
+    ADD CONSTRAINT users_id_key UNIQUE (id);
+
+DO
+$$
+    DECLARE
+        fk record;
+    BEGIN
+        FOR fk IN SELECT * FROM referencing_keys
+            LOOP
+                EXECUTE format('ALTER TABLE %s ADD CONSTRAINT %I %s', fk.table_name, fk.name, fk.definition);
+            END LOOP;
+    END;
+$$;
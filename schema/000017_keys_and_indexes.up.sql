@@ -0,0 +1,120 @@
+-- первичные ключи, уникальность связей и индексы для соединений.
+-- Таблицы первой миграции созданы с id serial not null unique без первичного ключа,
+-- таблицы связей допускали повторяющиеся пары, а колонки user_id, list_id и item_id
+-- не были проиндексированы, поэтому каждое соединение со связями читало таблицу целиком.
+-- Таблицы блокируются до удаления дубликатов, чтобы новые дубликаты
+-- не появились до создания ограничений уникальности
+LOCK TABLE users, todo_lists, todo_items, users_lists, lists_items, saved_filters IN ACCESS EXCLUSIVE MODE;
+
+-- из повторяющихся связей пользователя со списком остается связь с наивысшей ролью,
+-- из повторяющихся связей задачи со списком - первая. Триггеры надгробий и удаления
+-- записей без связей отключаются: пользователь и задача остаются в списке,
+-- удаляется только лишняя копия связи
+ALTER TABLE users_lists
+    DISABLE TRIGGER USER;
+
+DELETE
+FROM users_lists ul
+    USING (SELECT id,
+                  row_number() OVER (PARTITION BY user_id, list_id
+                      ORDER BY CASE role WHEN 'owner' THEN 0 WHEN 'editor' THEN 1 ELSE 2 END, id) AS n
+           FROM users_lists) d
+WHERE ul.id = d.id
+  AND d.n > 1;
+
+ALTER TABLE users_lists
+    ENABLE TRIGGER USER;
+
+ALTER TABLE lists_items
+    DISABLE TRIGGER USER;
+
+DELETE
+FROM lists_items li
+    USING (SELECT id, row_number() OVER (PARTITION BY list_id, item_id ORDER BY id) AS n
+           FROM lists_items) d
+WHERE li.id = d.id
+  AND d.n > 1;
+
+ALTER TABLE lists_items
+    ENABLE TRIGGER USER;
+
+-- внешние ключи ссылаются на ограничения уникальности id, поэтому на время замены
+-- уникальности первичным ключом они удаляются и затем создаются заново с прежними
+-- именами и определениями
+CREATE TEMPORARY TABLE referencing_keys ON COMMIT DROP AS
+SELECT conrelid::regclass AS table_name, conname AS name, pg_get_constraintdef(oid) AS definition
+FROM pg_constraint
+WHERE contype = 'f'
+  AND confrelid IN ('users'::regclass, 'todo_lists'::regclass, 'todo_items'::regclass);
+
+DO
+$$
+    DECLARE
+        fk record;
+    BEGIN
+        FOR fk IN SELECT * FROM referencing_keys
+            LOOP
+                EXECUTE format('ALTER TABLE %s DROP CONSTRAINT %I', fk.table_name, fk.name);
+            END LOOP;
+    END;
+$$;
+
+ALTER TABLE users
+    DROP CONSTRAINT users_id_key,
+    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+
+ALTER TABLE todo_lists
+    DROP CONSTRAINT todo_lists_id_key,
+    ADD CONSTRAINT todo_lists_pkey PRIMARY KEY (id);
+
+ALTER TABLE todo_items
+    DROP CONSTRAINT todo_items_id_key,
+    ADD CONSTRAINT todo_items_pkey PRIMARY KEY (id);
+
+ALTER TABLE users_lists
+    DROP CONSTRAINT users_lists_id_key,
+    ADD CONSTRAINT users_lists_pkey PRIMARY KEY (id);
+
+ALTER TABLE lists_items
+    DROP CONSTRAINT lists_items_id_key,
+    ADD CONSTRAINT lists_items_pkey PRIMARY KEY (id);
+
+ALTER TABLE saved_filters
+    DROP CONSTRAINT saved_filters_id_key,
+    ADD CONSTRAINT saved_filters_pkey PRIMARY KEY (id);
+
+DO
+$$
+    DECLARE
+        fk record;
+    BEGIN
+        FOR fk IN SELECT * FROM referencing_keys
+            LOOP
+                EXECUTE format('ALTER TABLE %s ADD CONSTRAINT %I %s', fk.table_name, fk.name, fk.definition);
+            END LOOP;
+    END;
+$$;
+
+-- уникальность связей. Индексы покрывающие: проверка роли пользователя в списке,
+-- списки пользователя, участники списка, задачи списка и список задачи
+-- читаются только из индекса, без обращения к таблице связей
+ALTER TABLE users_lists
+    ADD CONSTRAINT users_lists_user_id_list_id_key UNIQUE (user_id, list_id) INCLUDE (role);
+
+CREATE INDEX users_lists_list_id_idx ON users_lists (list_id) INCLUDE (user_id, role);
+
+ALTER TABLE lists_items
+    ADD CONSTRAINT lists_items_list_id_item_id_key UNIQUE (list_id, item_id);
+
+CREATE INDEX lists_items_item_id_idx ON lists_items (item_id) INCLUDE (list_id);
+
+-- индексы внешних ключей, по которым выбираются записи пользователя
+-- и удаляются каскадом записи удаленного списка
+CREATE INDEX saved_filters_user_id_idx ON saved_filters (user_id);
+
+CREATE INDEX webhooks_list_id_idx ON webhooks (list_id) WHERE list_id IS NOT NULL;
+
+CREATE INDEX calendar_feeds_list_id_idx ON calendar_feeds (list_id);
+
+-- статистика таблиц связей после удаления дубликатов для планировщика
+ANALYZE users_lists, lists_items;
@@ -0,0 +1,86 @@
+-- копии задач из нескольких списков и удаленные задачи без списка не восстанавливаются
+CREATE TABLE lists_items
+(
+    id        serial primary key,
+    item_id   int references todo_items (id) on delete cascade not null,
+    list_id   int references todo_lists (id) on delete cascade not null,
+    sync_txid bigint                                           not null default txid_current(),
+    CONSTRAINT lists_items_list_id_item_id_key UNIQUE (list_id, item_id)
+);
+
+CREATE INDEX lists_items_item_id_idx ON lists_items (item_id) INCLUDE (list_id);
+
+INSERT INTO lists_items (list_id, item_id)
+SELECT list_id, id
+FROM todo_items
+ORDER BY id;
+
+DROP TRIGGER todo_items_move_tombstone ON todo_items;
+
+DROP INDEX todo_items_list_id_idx;
+
+ALTER TABLE todo_items
+    DROP COLUMN list_id;
+
+CREATE OR REPLACE FUNCTION sync_tombstone() RETURNS trigger AS
+$$
+BEGIN
+    IF TG_TABLE_NAME = 'todo_lists' THEN
+        INSERT INTO sync_tombstones (entity, entity_id, list_id, recipients)
+        VALUES ('list', OLD.id, OLD.id, ARRAY(SELECT user_id FROM users_lists WHERE list_id = OLD.id));
+    ELSIF TG_TABLE_NAME = 'todo_items' THEN
+        INSERT INTO sync_tombstones (entity, entity_id, list_id, recipients)
+        SELECT 'item', OLD.id, li.list_id, ARRAY(SELECT user_id FROM users_lists WHERE list_id = li.list_id)
+        FROM lists_items li
+        WHERE li.item_id = OLD.id;
+    ELSIF TG_TABLE_NAME = 'users_lists' THEN
+        INSERT INTO sync_tombstones (entity, entity_id, list_id, recipients)
+        VALUES ('member', OLD.user_id, OLD.list_id, ARRAY(SELECT user_id FROM users_lists WHERE list_id = OLD.list_id));
+    ELSIF TG_TABLE_NAME = 'lists_items' THEN
+        IF NEW.list_id IS DISTINCT FROM OLD.list_id THEN
+            INSERT INTO sync_tombstones (entity, entity_id, list_id, recipients)
+            VALUES ('item', OLD.item_id, OLD.list_id, ARRAY(SELECT user_id FROM users_lists WHERE list_id = OLD.list_id));
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION delete_orphans() RETURNS trigger AS
+$$
+BEGIN
+    IF TG_TABLE_NAME = 'lists_items' THEN
+        DELETE
+        FROM todo_items ti
+        WHERE ti.id = OLD.item_id
+          AND NOT EXISTS(SELECT 1 FROM lists_items li WHERE li.item_id = ti.id);
+    ELSIF TG_TABLE_NAME = 'users_lists' THEN
+        DELETE
+        FROM todo_lists tl
+        WHERE tl.id = OLD.list_id
+          AND NOT EXISTS(SELECT 1 FROM users_lists ul WHERE ul.list_id = tl.id);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER lists_items_sync
+    BEFORE INSERT OR UPDATE
+    ON lists_items
+    FOR EACH ROW
+EXECUTE PROCEDURE sync_track_changes();
+
+CREATE TRIGGER lists_items_tombstone
+    BEFORE UPDATE
+    ON lists_items
+    FOR EACH ROW
+EXECUTE PROCEDURE sync_tombstone();
+
+CREATE TRIGGER lists_items_delete_orphans
+    AFTER DELETE
+    ON lists_items
+    FOR EACH ROW
+EXECUTE PROCEDURE delete_orphans();
@@ -0,0 +1,101 @@
+-- задача принадлежит ровно одному списку, поэтому связь задачи со списком хранится
+-- в todo_items.list_id вместо таблицы lists_items: выборки задач не соединяют три таблицы,
+-- а задача не может остаться без списка или оказаться в нескольких списках.
+-- Задачи удаляются вместе со списком каскадно по внешнему ключу
+LOCK TABLE todo_items, lists_items IN ACCESS EXCLUSIVE MODE;
+
+ALTER TABLE todo_items
+    ADD COLUMN list_id int;
+
+-- перенос данных не считается изменением задач: триггеры синхронизации,
+-- надгробий и поиска отключаются, клиенты не получают все задачи повторно
+ALTER TABLE todo_items
+    DISABLE TRIGGER USER;
+
+-- задача остается в списке, с которым связана раньше других
+UPDATE todo_items ti
+SET list_id = li.list_id
+FROM (SELECT DISTINCT ON (item_id) item_id, list_id FROM lists_items ORDER BY item_id, id) li
+WHERE ti.id = li.item_id;
+
+-- в остальные списки задачи из нескольких списков копируются с новыми id,
+-- чтобы участники этих списков не потеряли задачи. Копия повторяет все колонки задачи,
+-- кроме id, list_id и sync_txid: клиенты получат копии при следующей синхронизации.
+-- LATERAL вычисляет nextval один раз на копию
+INSERT INTO todo_items
+SELECT copy.*
+FROM lists_items li
+         INNER JOIN todo_items ti ON ti.id = li.item_id,
+     LATERAL jsonb_populate_record(NULL::todo_items,
+                                   to_jsonb(ti) || jsonb_build_object('id', nextval('todo_items_id_seq'),
+                                                                      'list_id', li.list_id,
+                                                                      'sync_txid', txid_current())) copy
+WHERE li.list_id <> ti.list_id;
+
+-- задачи без списка недоступны пользователям и удаляются, как командой gc-orphans
+DELETE
+FROM todo_items
+WHERE list_id IS NULL;
+
+ALTER TABLE todo_items
+    ENABLE TRIGGER USER;
+
+ALTER TABLE todo_items
+    ALTER COLUMN list_id SET NOT NULL,
+    ADD CONSTRAINT todo_items_list_id_fkey FOREIGN KEY (list_id) REFERENCES todo_lists (id) ON DELETE CASCADE;
+
+-- задачи списка, выбираемые по порядку id (сортировка по умолчанию)
+CREATE INDEX todo_items_list_id_idx ON todo_items (list_id, id);
+
+-- надгробия задач: удаление и перенос в другой список меняют list_id задачи.
+-- Задачи, удаляемые каскадно вместе со списком, надгробий не получают:
+-- их покрывает надгробие списка
+CREATE OR REPLACE FUNCTION sync_tombstone() RETURNS trigger AS
+$$
+BEGIN
+    IF TG_TABLE_NAME = 'todo_lists' THEN
+        INSERT INTO sync_tombstones (entity, entity_id, list_id, recipients)
+        VALUES ('list', OLD.id, OLD.id, ARRAY(SELECT user_id FROM users_lists WHERE list_id = OLD.id));
+    ELSIF TG_TABLE_NAME = 'todo_items' THEN
+        IF TG_OP = 'UPDATE' THEN
+            IF NEW.list_id IS DISTINCT FROM OLD.list_id THEN
+                INSERT INTO sync_tombstones (entity, entity_id, list_id, recipients)
+                VALUES ('item', OLD.id, OLD.list_id, ARRAY(SELECT user_id FROM users_lists WHERE list_id = OLD.list_id));
+            END IF;
+            RETURN NEW;
+        END IF;
+        IF EXISTS(SELECT 1 FROM todo_lists WHERE id = OLD.list_id) THEN
+            INSERT INTO sync_tombstones (entity, entity_id, list_id, recipients)
+            VALUES ('item', OLD.id, OLD.list_id, ARRAY(SELECT user_id FROM users_lists WHERE list_id = OLD.list_id));
+        END IF;
+    ELSIF TG_TABLE_NAME = 'users_lists' THEN
+        INSERT INTO sync_tombstones (entity, entity_id, list_id, recipients)
+        VALUES ('member', OLD.user_id, OLD.list_id, ARRAY(SELECT user_id FROM users_lists WHERE list_id = OLD.list_id));
+    END IF;
+
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER todo_items_move_tombstone
+    BEFORE UPDATE OF list_id
+    ON todo_items
+    FOR EACH ROW
+EXECUTE PROCEDURE sync_tombstone();
+
+-- задачи без списка больше не появляются, триггер удаляет только списки без участников
+CREATE OR REPLACE FUNCTION delete_orphans() RETURNS trigger AS
+$$
+BEGIN
+    DELETE
+    FROM todo_lists tl
+    WHERE tl.id = OLD.list_id
+      AND NOT EXISTS(SELECT 1 FROM users_lists ul WHERE ul.list_id = tl.id);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TABLE lists_items;
+
+ANALYZE todo_items;
@@ -0,0 +1,9 @@
+-- txid - id транзакции, опубликовавшей событие. id событий выделяются до фиксации транзакций,
+-- поэтому событие с меньшим id может стать видимым позже события с большим id.
+-- Курсор потока /api/stream - снимок транзакций (txid_current_snapshot()):
+-- клиенту повторяются события, транзакции которых не видны в его снимке.
+-- Существующие события получают txid транзакции миграции
+ALTER TABLE events
+    ADD COLUMN txid bigint not null default txid_current();
+
+CREATE INDEX events_txid_idx ON events (txid);
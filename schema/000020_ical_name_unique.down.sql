@@ -0,0 +1 @@
+DROP INDEX todo_items_ical_name_key;
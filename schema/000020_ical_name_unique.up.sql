@@ -0,0 +1,14 @@
+-- имя ресурса CalDAV уникально в списке. Имена вида todo-item-<id>.ics
+-- зарезервированы за задачами без сохраненного имени и в ical_name не хранятся.
+-- Сохраненные ранее зарезервированные имена и повторы имени в списке
+-- (кроме задачи с наименьшим id) сбрасываются к имени по умолчанию
+UPDATE todo_items t
+SET ical_name = NULL
+WHERE t.ical_name ~ '^todo-item-[0-9]+\.ics$'
+   OR EXISTS (SELECT 1
+              FROM todo_items o
+              WHERE o.list_id = t.list_id
+                AND o.ical_name = t.ical_name
+                AND o.id < t.id);
+
+CREATE UNIQUE INDEX todo_items_ical_name_key ON todo_items (list_id, ical_name) WHERE ical_name IS NOT NULL;
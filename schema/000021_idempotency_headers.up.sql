@@ -0,0 +1,5 @@
+-- заголовки сохраненного ответа (Location, ETag, Content-Type), которые повтор запроса
+-- с тем же ключом идемпотентности возвращает вместе со статусом и телом.
+-- У ответов, сохраненных до миграции, заголовков нет
+ALTER TABLE idempotency_keys
+    ADD COLUMN response_headers jsonb not null default '{}';
@@ -0,0 +1,10 @@
+// Package schema встраивает файлы миграций схемы БД в бинарный файл приложения.
+// Миграции применяются при запуске приложения и командой migrate (repository/migrate.go)
+package schema
+
+import "embed"
+
+// файлы миграций <версия>_<название>.up.sql и .down.sql
+//
+//go:embed *.sql
+var Migrations embed.FS
@@ -0,0 +1,13 @@
+-- задача по id, как в TodoItemPostgres.GetItemById.
+-- Запрос для схемы до миграции 000018, где задачи связаны со списками через lists_items.
+-- Параметры задает команда, которую выводит seed.sql
+\set item random(0, :lists * :items_per_list - 1)
+\set item_id :first_item + :item
+\set user_id :first_user + :item / (:lists_per_user * :items_per_list)
+SELECT ti.id, ti.title, ti.description, ti.done, ti.position, ti.due_date, ti.priority, ti.labels, ti.version,
+       ti.completed_at, ti.created_at, ti.updated_at, ti.created_by, ti.updated_by
+FROM todo_items ti
+         INNER JOIN lists_items li on li.item_id = ti.id
+         INNER JOIN users_lists ul on ul.list_id = li.list_id
+WHERE ti.id = :item_id
+  AND ul.user_id = :user_id;
@@ -0,0 +1,13 @@
+-- страница задач списка, как в TodoItemPostgres.GetAllItems с сортировкой по умолчанию.
+-- Параметры задает команда, которую выводит seed.sql
+\set list random(0, :lists - 1)
+\set list_id :first_list + :list
+\set user_id :first_user + :list / :lists_per_user
+SELECT ti.id, ti.title, ti.description, ti.done, ti.position, ti.due_date, ti.priority, ti.labels, ti.version,
+       ti.completed_at, ti.created_at, ti.updated_at, ti.created_by, ti.updated_by, ti.id::text AS sort_key
+FROM todo_items ti
+         INNER JOIN users_lists ul on ul.list_id = ti.list_id
+WHERE ti.list_id = :list_id
+  AND ul.user_id = :user_id
+ORDER BY ti.id ASC, ti.id ASC
+LIMIT 51;
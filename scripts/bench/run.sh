@@ -0,0 +1,58 @@
+#!/bin/sh
+# Бенчмарк запросов задач до и после миграций 000017 (ключи и индексы) и 000018 (список задачи в todo_items.list_id).
+# Схема откатывается до версии 16, заполняется тестовыми данными seed.sql, и pgbench запускается
+# с одними и теми же параметрами на версиях 16, 17 и 18, после чего схема возвращается к последней версии.
+# Результат - таблица tps и средней задержки для README.
+#
+#	PGHOST=localhost PGUSER=postgres PGPASSWORD=... sh scripts/bench/run.sh
+#
+# Подключение psql и pgbench задается переменными PG*, подключение команды migrate - конфигом приложения и файлом .env.
+# Размер данных задается переменными USERS, LISTS_PER_USER и ITEMS_PER_LIST, длительность прогона - DURATION (секунды),
+# число клиентов - CLIENTS. Тестовые данные остаются в БД, поэтому запускать скрипт нужно на отдельной БД
+set -eu
+
+cd "$(dirname "$0")/../.."
+
+USERS=${USERS:-1000}
+LISTS_PER_USER=${LISTS_PER_USER:-10}
+ITEMS_PER_LIST=${ITEMS_PER_LIST:-100}
+DURATION=${DURATION:-30}
+CLIENTS=${CLIENTS:-8}
+
+migrate() {
+	go run ./cmd migrate "$@"
+}
+
+schema_version() {
+	migrate version | sed -n 's/^version: //p'
+}
+
+# прогон pgbench, вывод строки таблицы: версия, tps, средняя задержка
+bench() {
+	out=$(pgbench -n -T "$DURATION" -c "$CLIENTS" $params -f "scripts/bench/get_items$1.sql" -f "scripts/bench/get_item$1.sql")
+	tps=$(echo "$out" | sed -n 's/^tps = \([0-9.]*\).*/\1/p' | head -n 1)
+	latency=$(echo "$out" | sed -n 's/^latency average = \([0-9.]*\) ms/\1/p')
+	echo "| $(schema_version) | $tps | $latency |"
+}
+
+version=$(schema_version)
+if [ "$version" -lt 16 ]; then
+	migrate up $((16 - version))
+elif [ "$version" -gt 16 ]; then
+	migrate down $((version - 16))
+fi
+
+# seed.sql выводит команду pgbench, из нее берутся только параметры -D
+params=$(psql -X -q -At -v users="$USERS" -v lists_per_user="$LISTS_PER_USER" -v items_per_list="$ITEMS_PER_LIST" \
+	-f scripts/bench/seed.sql | grep '^pgbench ' | grep -o -- '-D [^ ]*' | tr '\n' ' ')
+
+echo "users=$USERS lists_per_user=$LISTS_PER_USER items_per_list=$ITEMS_PER_LIST, pgbench -T $DURATION -c $CLIENTS"
+echo
+echo "| версия схемы | tps | средняя задержка, мс |"
+echo "|---|---|---|"
+bench _lists_items
+migrate up 1
+bench _lists_items
+migrate up 1
+bench ''
+migrate up
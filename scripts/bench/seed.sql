@@ -0,0 +1,128 @@
+-- Тестовые данные для бенчмарка запросов задач.
+-- Пользователи bench_<id> с lists_per_user списками по items_per_list задач,
+-- в каждый список добавлены два участника. Каждая сотая связь задачи со списком
+-- и связи участников каждого десятого списка продублированы, как в БД до миграции 000017.
+-- id записей выделяются подряд, поэтому pgbench выбирает записи по номеру:
+-- скрипт выводит команду запуска pgbench со скриптами get_items.sql и get_item.sql,
+-- а для схемы до миграции 000018 - со скриптами get_items_lists_items.sql и get_item_lists_items.sql.
+--
+--	psql -h localhost -U postgres -v users=1000 -f scripts/bench/seed.sql
+--
+-- Размер данных задается переменными users, lists_per_user и items_per_list
+\set ON_ERROR_STOP on
+\if :{?users}
+\else
+    \set users 1000
+\endif
+\if :{?lists_per_user}
+\else
+    \set lists_per_user 10
+\endif
+\if :{?items_per_list}
+\else
+    \set items_per_list 100
+\endif
+
+-- до миграции 000018 задачи связаны со списками через таблицу lists_items
+SELECT to_regclass('lists_items') IS NOT NULL AS has_lists_items \gset
+\if :has_lists_items
+    \set scripts_suffix _lists_items
+\else
+    \set scripts_suffix ''
+\endif
+
+BEGIN;
+
+CREATE TEMPORARY TABLE bench_users ON COMMIT DROP AS
+SELECT nextval('users_id_seq')::int AS id, n
+FROM (SELECT n FROM generate_series(0, :users - 1) n ORDER BY n) s;
+
+INSERT INTO users (id, name, username, password_hash, created_by, updated_by)
+SELECT id, 'Bench user ' || n, 'bench_' || id, '', id, id
+FROM bench_users;
+
+-- id выделяются после сортировки, чтобы они шли в порядке номеров записей
+CREATE TEMPORARY TABLE bench_lists ON COMMIT DROP AS
+SELECT nextval('todo_lists_id_seq')::int AS id, user_id, n
+FROM (SELECT u.id AS user_id, u.n * :lists_per_user + l AS n
+      FROM bench_users u,
+           generate_series(0, :lists_per_user - 1) l
+      ORDER BY 2) s;
+
+INSERT INTO todo_lists (id, title, position, created_by, updated_by)
+SELECT id, 'Bench list ' || n, n, user_id, user_id
+FROM bench_lists;
+
+INSERT INTO users_lists (user_id, list_id, role)
+SELECT user_id, id, 'owner'
+FROM bench_lists
+UNION ALL
+SELECT m.id, l.id, CASE WHEN k = 1 THEN 'editor' ELSE 'viewer' END
+FROM bench_lists l,
+     generate_series(1, 2) k,
+     bench_users m
+WHERE m.n = (l.n / :lists_per_user + k * 7) % :users
+  AND m.id <> l.user_id;
+
+CREATE TEMPORARY TABLE bench_items ON COMMIT DROP AS
+SELECT nextval('todo_items_id_seq')::int AS id, list_id, user_id, i
+FROM (SELECT l.id AS list_id, l.user_id, i
+      FROM bench_lists l,
+           generate_series(0, :items_per_list - 1) i
+      ORDER BY l.n, i) s;
+
+\if :has_lists_items
+INSERT INTO todo_items (id, title, done, position, priority, created_by, updated_by)
+SELECT id, 'Bench item ' || i, i % 3 = 0, i, i % 4, user_id, user_id
+FROM bench_items;
+
+INSERT INTO lists_items (list_id, item_id)
+SELECT list_id, id
+FROM bench_items;
+
+-- дубликаты связей задач вставляются, только пока их не запрещает миграция 000017
+DO
+$$
+    BEGIN
+        INSERT INTO lists_items (list_id, item_id)
+        SELECT list_id, id
+        FROM bench_items
+        WHERE i % 100 = 0;
+    EXCEPTION
+        WHEN unique_violation THEN
+            RAISE NOTICE 'duplicate item links are not allowed by the schema, skipped';
+    END;
+$$;
+
+ANALYZE lists_items;
+\else
+INSERT INTO todo_items (id, list_id, title, done, position, priority, created_by, updated_by)
+SELECT id, list_id, 'Bench item ' || i, i % 3 = 0, i, i % 4, user_id, user_id
+FROM bench_items;
+\endif
+
+-- дубликаты участников вставляются, только пока их не запрещает миграция 000017
+DO
+$$
+    BEGIN
+        INSERT INTO users_lists (user_id, list_id, role)
+        SELECT ul.user_id, ul.list_id, ul.role
+        FROM users_lists ul
+                 INNER JOIN bench_lists l ON l.id = ul.list_id
+        WHERE ul.role <> 'owner'
+          AND l.n % 10 = 0;
+    EXCEPTION
+        WHEN unique_violation THEN
+            RAISE NOTICE 'duplicate member links are not allowed by the schema, skipped';
+    END;
+$$;
+
+ANALYZE users, todo_lists, todo_items, users_lists;
+
+SELECT format('pgbench -n -T 30 -c 8 -D first_user=%s -D first_list=%s -D first_item=%s ' ||
+              '-D lists=%s -D lists_per_user=%s -D items_per_list=%s ' ||
+              '-f scripts/bench/get_items' || :'scripts_suffix' || '.sql -f scripts/bench/get_item' || :'scripts_suffix' || '.sql',
+              (SELECT min(id) FROM bench_users), (SELECT min(id) FROM bench_lists), (SELECT min(id) FROM bench_items),
+              :users * :lists_per_user, :lists_per_user, :items_per_list) AS pgbench;
+
+COMMIT;
@@ -0,0 +1,49 @@
+package todo
+
+import "strings"
+
+// Описываем структуры полнотекстового поиска по спискам и задачам.
+// Применяются при чтении запросов клиента и выводе результатов.
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+
+	SearchTypeList = "list"
+	SearchTypeItem = "item"
+)
+
+type SearchQuery struct {
+	Query string `form:"q"`
+	Limit int    `form:"limit"`
+}
+
+// метод валидации параметров поиска, проставляет значения по умолчанию
+// используется в хендлере search.go
+func (q *SearchQuery) Validate() error {
+	q.Query = strings.TrimSpace(q.Query)
+	if q.Query == "" {
+		return NewValidationError("q", CodeRequired, "search query is empty")
+	}
+
+	if q.Limit == 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	if q.Limit < 0 || q.Limit > MaxSearchLimit {
+		return NewValidationError("limit", CodeOutOfRange, "limit must be between 1 and 100")
+	}
+
+	return nil
+}
+
+// результат поиска: тип найденной сущности (list или item),
+// её id, id списка, к которому она относится, ранг и фрагмент текста
+// с подсвеченными совпадениями. Фрагмент - HTML: текст в нем экранирован,
+// совпадения обернуты в <mark></mark>
+type SearchResult struct {
+	Type    string  `json:"type" db:"type"`
+	Id      int     `json:"id" db:"id"`
+	ListId  int     `json:"list_id" db:"list_id"`
+	Title   string  `json:"title" db:"title"`
+	Snippet string  `json:"snippet" db:"snippet"`
+	Rank    float64 `json:"rank" db:"rank"`
+}
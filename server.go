@@ -2,6 +2,8 @@ package todo
 
 import (
 	"context"
+	"errors"
+	"net"
 	"net/http"
 	"time"
 )
@@ -16,6 +18,8 @@ type Server struct {
 // стандартный метод ListenAndServer пакета http , который
 // запускает бесконечный цикл и слушает все входящие запросы
 // для последующей обработки.
+// Соединение запроса передается в контекст (ConnContext), чтобы обработчики
+// долгих ответов (поток событий, экспорт) могли продлить WriteTimeout функцией SetWriteDeadline
 func (s *Server) Run(port string, handler http.Handler) error {
 	s.httpServer = &http.Server{
 		Addr:           ":" + port,
@@ -23,6 +27,10 @@ func (s *Server) Run(port string, handler http.Handler) error {
 		MaxHeaderBytes: 1 << 20,
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
+		IdleTimeout:    2 * time.Minute,
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			return context.WithValue(ctx, connCtxKey{}, conn)
+		},
 	}
 
 	return s.httpServer.ListenAndServe()
@@ -32,3 +40,17 @@ func (s *Server) Run(port string, handler http.Handler) error {
 func (s *Server) Shutdown(ctx context.Context) error {
 	return s.httpServer.Shutdown(ctx)
 }
+
+// ключ контекста, в котором сервер передает соединение запроса
+type connCtxKey struct{}
+
+// функция переносит срок записи ответа в соединение запроса из ctx,
+// нулевое значение deadline снимает ограничение
+func SetWriteDeadline(ctx context.Context, deadline time.Time) error {
+	conn, ok := ctx.Value(connCtxKey{}).(net.Conn)
+	if !ok {
+		return errors.New("request connection not found")
+	}
+
+	return conn.SetWriteDeadline(deadline)
+}
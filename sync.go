@@ -0,0 +1,187 @@
+package todo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Описываем протокол синхронизации для офлайн-клиентов.
+//
+// GET /api/sync?since=<cursor> возвращает списки, задачи и участников, которые изменились
+// после выдачи курсора, и надгробия удаленных записей. Без since возвращается полный снимок.
+// Курсор монотонно растет, выдается сервером и передается клиентом без изменений.
+// Ответ может повторять уже полученные изменения, поэтому клиент применяет их как upsert:
+// сначала удаляет записи по надгробиям, затем сохраняет записи из ответа.
+// Надгробие member с id текущего пользователя означает потерю доступа к списку,
+// удаление списка удаляет у клиента и его задачи.
+// Если курсор старше срока хранения надгробий, возвращается полный снимок с reset = true.
+//
+// POST /api/sync принимает мутации, выполненные клиентом офлайн, в порядке выполнения.
+// Новые записи получают client_id, сгенерированный клиентом: повторная отправка мутации
+// не создает запись второй раз, а последующие мутации могут ссылаться на запись по client_id.
+// Конфликты разрешаются по правилу "последний записавший побеждает" для каждого поля:
+// поле изменяется, только если changed_at мутации позже последнего изменения этого поля
+// на сервере (при равенстве побеждает сервер). changed_at из будущего заменяется
+// текущим временем сервера. Удаление побеждает изменения: мутация удаленной записи
+// возвращает not_found.
+
+// ограничения запроса синхронизации
+const (
+	MaxSyncMutations      = 500
+	MaxSyncClientIdLength = 64
+)
+
+// сущности и операции мутаций
+const (
+	SyncEntityList   = "list"
+	SyncEntityItem   = "item"
+	SyncEntityMember = "member"
+
+	SyncOpCreate = "create"
+	SyncOpUpdate = "update"
+	SyncOpDelete = "delete"
+)
+
+// статусы результата мутации: applied - мутация применена (для update - хотя бы одно поле),
+// ignored - все поля изменены на сервере позже мутации,
+// not_found, forbidden и invalid - мутация отклонена
+const (
+	SyncStatusApplied   = "applied"
+	SyncStatusIgnored   = "ignored"
+	SyncStatusNotFound  = "not_found"
+	SyncStatusForbidden = "forbidden"
+	SyncStatusInvalid   = "invalid"
+)
+
+// задача вместе со списком, в котором она находится
+type SyncItem struct {
+	ListId int `json:"list_id" db:"list_id"`
+	TodoItem
+}
+
+// участник списка
+type SyncMember struct {
+	ListId   int    `json:"list_id" db:"list_id"`
+	UserId   int    `json:"user_id" db:"user_id"`
+	Username string `json:"username" db:"username"`
+	Role     string `json:"role" db:"role"`
+}
+
+// надгробие удаленной записи, для member Id - id исключенного пользователя
+type SyncTombstone struct {
+	Entity    string    `json:"entity" db:"entity"`
+	Id        int       `json:"id" db:"entity_id"`
+	ListId    int       `json:"list_id" db:"list_id"`
+	DeletedAt time.Time `json:"deleted_at" db:"deleted_at"`
+}
+
+// изменения после курсора, Cursor - курсор для следующего запроса
+type SyncChanges struct {
+	Cursor     string          `json:"cursor"`
+	Reset      bool            `json:"reset"`
+	Lists      []TodoList      `json:"lists"`
+	Items      []SyncItem      `json:"items"`
+	Members    []SyncMember    `json:"members"`
+	Tombstones []SyncTombstone `json:"tombstones"`
+}
+
+// мутация клиента. Запись для update и delete задается id или client_id,
+// задача при create создается в списке list_id или list_client_id.
+// Fields - поля записи: для create как при создании списка или задачи,
+// для update как при их изменении (PUT)
+type SyncMutation struct {
+	Entity       string          `json:"entity" binding:"required"`
+	Op           string          `json:"op" binding:"required"`
+	Id           *int            `json:"id"`
+	ClientId     string          `json:"client_id"`
+	ListId       *int            `json:"list_id"`
+	ListClientId string          `json:"list_client_id"`
+	Fields       json.RawMessage `json:"fields" swaggertype:"object"`
+	ChangedAt    time.Time       `json:"changed_at" binding:"required"`
+}
+
+type SyncPushInput struct {
+	Mutations []SyncMutation `json:"mutations" binding:"required"`
+}
+
+// метод валидации запроса, поля мутаций проверяются при их применении
+// используется в сервисе sync.go
+func (i *SyncPushInput) Validate() error {
+	if len(i.Mutations) == 0 {
+		return NewValidationError("mutations", CodeRequired, "mutations are empty")
+	}
+	if len(i.Mutations) > MaxSyncMutations {
+		return NewValidationError("mutations", CodeOutOfRange, fmt.Sprintf("too many mutations, max %d", MaxSyncMutations))
+	}
+
+	errs := &ValidationError{}
+	for n := range i.Mutations {
+		i.Mutations[n].validate(errs, fmt.Sprintf("mutations[%d].", n))
+	}
+
+	return errs.Err()
+}
+
+func (m *SyncMutation) validate(errs *ValidationError, prefix string) {
+	if m.Entity != SyncEntityList && m.Entity != SyncEntityItem {
+		errs.Add(prefix+"entity", CodeUnsupported, "entity must be list or item")
+	}
+
+	m.ClientId = strings.TrimSpace(m.ClientId)
+	m.ListClientId = strings.TrimSpace(m.ListClientId)
+	if len(m.ClientId) > MaxSyncClientIdLength {
+		errs.Add(prefix+"client_id", CodeTooLong, fmt.Sprintf("must be at most %d characters", MaxSyncClientIdLength))
+	}
+	if len(m.ListClientId) > MaxSyncClientIdLength {
+		errs.Add(prefix+"list_client_id", CodeTooLong, fmt.Sprintf("must be at most %d characters", MaxSyncClientIdLength))
+	}
+
+	switch m.Op {
+	case SyncOpCreate:
+		if m.ClientId == "" {
+			errs.Add(prefix+"client_id", CodeRequired, "client_id is required for create")
+		}
+		if m.Entity == SyncEntityItem && m.ListId == nil && m.ListClientId == "" {
+			errs.Add(prefix+"list_id", CodeRequired, "list_id or list_client_id is required for item create")
+		}
+	case SyncOpUpdate, SyncOpDelete:
+		if m.Id == nil && m.ClientId == "" {
+			errs.Add(prefix+"id", CodeRequired, "id or client_id is required")
+		}
+	default:
+		errs.Add(prefix+"op", CodeUnsupported, "op must be create, update or delete")
+	}
+
+	if m.Op != SyncOpDelete && len(m.Fields) == 0 {
+		errs.Add(prefix+"fields", CodeRequired, "fields are required")
+	}
+}
+
+// результат мутации: Id - id записи на сервере,
+// Applied и Ignored - примененные и отклоненные поля update
+type SyncResult struct {
+	Index    int          `json:"index"`
+	ClientId string       `json:"client_id,omitempty"`
+	Id       int          `json:"id,omitempty"`
+	Status   string       `json:"status"`
+	Applied  []string     `json:"applied,omitempty"`
+	Ignored  []string     `json:"ignored,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// время последнего изменения полей записи для разрешения конфликтов,
+// поле без времени изменения не менялось после создания записи
+type FieldTimes struct {
+	CreatedAt time.Time
+	Fields    map[string]time.Time
+}
+
+// метод возвращает время последнего изменения поля
+func (t FieldTimes) Get(field string) time.Time {
+	if changed, ok := t.Fields[field]; ok {
+		return changed
+	}
+	return t.CreatedAt
+}
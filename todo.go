@@ -1,6 +1,6 @@
 package todo
 
-import "errors"
+import "time"
 
 // Описываем структуры листов, задач и их списков,
 // а так же структуры для их обновления.
@@ -12,6 +12,9 @@ type TodoList struct {
 	Id          int    `json:"id" db:"id"`
 	Title       string `json:"title" db:"title" binding:"required"`
 	Description string `json:"description" db:"description"`
+	Position    int    `json:"position" db:"position"`
+	Version     int    `json:"version" db:"version"`
+	Audit
 }
 
 type UsersList struct {
@@ -21,45 +24,118 @@ type UsersList struct {
 }
 
 type TodoItem struct {
-	Id          int    `json:"id" db:"id"`
-	Title       string `json:"title" db:"title" binding:"required"`
-	Description string `json:"description" db:"description"`
-	Done        string `json:"done" db:"done"`
+	Id          int        `json:"id" db:"id"`
+	Title       string     `json:"title" db:"title" binding:"required"`
+	Description string     `json:"description" db:"description"`
+	Done        string     `json:"done" db:"done"`
+	Position    int        `json:"position" db:"position"`
+	DueDate     *time.Time `json:"due_date" db:"due_date"`
+	Priority    int        `json:"priority" db:"priority"`
+	Labels      Labels     `json:"labels" db:"labels"`
+	Version     int        `json:"version" db:"version"`
+	// время перевода задачи в выполненные, nil для невыполненных задач
+	CompletedAt *time.Time `json:"completed_at" db:"completed_at"`
+	Audit
+}
+
+// метод валидации списка при создании
+// используется в сервисе todo_list.go
+func (l *TodoList) Validate() error {
+	errs := &ValidationError{}
+
+	errs.text("title", &l.Title, true, MaxTextLength)
+	errs.text("description", &l.Description, false, MaxTextLength)
+
+	return errs.Err()
+}
+
+// метод валидации задачи при создании
+// используется в сервисе todo_item.go
+func (i *TodoItem) Validate() error {
+	errs := &ValidationError{}
+
+	errs.text("title", &i.Title, true, MaxTextLength)
+	errs.text("description", &i.Description, false, MaxTextLength)
+	errs.priority("priority", i.Priority)
+	errs.labels("labels", &i.Labels)
+
+	return errs.Err()
 }
 
+// приоритеты задач, 0 - приоритет не задан
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
 type ListsItem struct {
 	Id     int
 	ListId int
 	ItemID int
 }
 
+// Version - ожидаемая версия записи из заголовка If-Match,
+// если задана, обновление выполняется только при совпадении версий
 type UpdateListInput struct {
 	Title       *string `json:"title"`
 	Description *string `json:"description"`
+	Position    *int    `json:"position"`
+	Version     *int    `json:"-"`
 }
 
-// метод валидации данных запроса на nil
+// метод валидации данных запроса на nil и заданных полей
 // используется в сервисе todo_list.go
-func (i UpdateListInput) Validate() error {
-	if i.Title == nil && i.Description == nil {
-		return errors.New("update structure has no values")
+func (i *UpdateListInput) Validate() error {
+	if i.Title == nil && i.Description == nil && i.Position == nil {
+		return NewValidationError("", CodeRequired, "update structure has no values")
+	}
+
+	errs := &ValidationError{}
+	if i.Title != nil {
+		errs.text("title", i.Title, true, MaxTextLength)
+	}
+	if i.Description != nil {
+		errs.text("description", i.Description, false, MaxTextLength)
 	}
 
-	return nil
+	return errs.Err()
 }
 
+// Version - ожидаемая версия записи из заголовка If-Match
 type UpdateItemInput struct {
-	Title       *string `json:"title"`
-	Description *string `json:"description"`
-	Done        *bool   `json:"done"`
+	Title       *string    `json:"title"`
+	Description *string    `json:"description"`
+	Done        *bool      `json:"done"`
+	Position    *int       `json:"position"`
+	DueDate     *time.Time `json:"due_date"`
+	Priority    *int       `json:"priority"`
+	Labels      *Labels    `json:"labels"`
+	Version     *int       `json:"-"`
 }
 
-// метод валидации данных запроса на nil
+// метод валидации данных запроса на nil и заданных полей
 // используется в сервисе todo_item.go
-func (i UpdateItemInput) Validate() error {
-	if i.Title == nil && i.Description == nil && i.Done == nil {
-		return errors.New("update structure has no values")
+func (i *UpdateItemInput) Validate() error {
+	if i.Title == nil && i.Description == nil && i.Done == nil && i.Position == nil && i.DueDate == nil &&
+		i.Priority == nil && i.Labels == nil {
+		return NewValidationError("", CodeRequired, "update structure has no values")
+	}
+
+	errs := &ValidationError{}
+	if i.Title != nil {
+		errs.text("title", i.Title, true, MaxTextLength)
+	}
+	if i.Description != nil {
+		errs.text("description", i.Description, false, MaxTextLength)
+	}
+	if i.Priority != nil {
+		errs.priority("priority", *i.Priority)
+	}
+	if i.Labels != nil {
+		errs.labels("labels", i.Labels)
 	}
 
-	return nil
+	return errs.Err()
 }
@@ -1,5 +1,12 @@
 package todo
 
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
 // Описываем структуру пользователя, соответствующую базе данных.
 // Применяется при чтении запросов клиента.
 // Добавлены json-теги для корректного чтения и
@@ -9,4 +16,68 @@ type User struct {
 	Name     string `json:"name" binding:"required"`
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	// пользователь не возвращается в ответах api,
+	// поля аудита не читаются из запроса на регистрацию
+	Audit `json:"-"`
+}
+
+// метод валидации пользователя при регистрации
+// используется в сервисе auth.go
+func (u *User) Validate() error {
+	errs := &ValidationError{}
+
+	errs.text("name", &u.Name, true, MaxTextLength)
+
+	u.Username = strings.TrimSpace(u.Username)
+	switch length := utf8.RuneCountInString(u.Username); {
+	case length == 0:
+		errs.Add("username", CodeRequired, "field is required")
+	case length < MinUsernameLength:
+		errs.Add("username", CodeTooShort, fmt.Sprintf("must be at least %d characters", MinUsernameLength))
+	case length > MaxUsernameLength:
+		errs.Add("username", CodeTooLong, fmt.Sprintf("must be at most %d characters", MaxUsernameLength))
+	case !validUsername(u.Username):
+		errs.Add("username", CodeInvalidChar, "may contain only latin letters, digits, '.', '_' and '-'")
+	}
+
+	// пароль не обрезается: пробелы являются его частью
+	switch length := len(u.Password); {
+	case length == 0:
+		errs.Add("password", CodeRequired, "field is required")
+	case length < MinPasswordLength:
+		errs.Add("password", CodeTooShort, fmt.Sprintf("must be at least %d characters", MinPasswordLength))
+	case length > MaxPasswordLength:
+		errs.Add("password", CodeTooLong, fmt.Sprintf("must be at most %d bytes", MaxPasswordLength))
+	case !strongPassword(u.Password):
+		errs.Add("password", CodeWeak, "must contain a letter and a digit")
+	}
+
+	return errs.Err()
+}
+
+func validUsername(username string) bool {
+	for _, r := range username {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// пароль должен содержать хотя бы одну букву и одну цифру
+func strongPassword(password string) bool {
+	var letter, digit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+
+	return letter && digit
 }
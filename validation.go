@@ -0,0 +1,95 @@
+package todo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Описываем общий слой валидации входных данных.
+// Методы Validate обрезают пробелы по краям строк и проверяют
+// обязательность и длину полей в соответствии со схемой БД (varchar(255)),
+// ошибки накапливаются по всем полям в ValidationError.
+// Вызываются в сервисах перед передачей данных в репозитории.
+const (
+	MaxTextLength = 255
+
+	MinUsernameLength = 3
+	MaxUsernameLength = 32
+
+	// пароль хэшируется sha1 с солью (generatePasswordHash в pkg/service/auth.go),
+	// длина ограничена в байтах, чтобы не хэшировать произвольно большие строки;
+	// 72 байта - предел bcrypt, поэтому ограничение не придется менять при переходе на него
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+
+	MaxLabelLength = 64
+	MaxLabels      = 20
+)
+
+// коды ошибок валидации строк
+const (
+	CodeTooLong     = "too_long"
+	CodeTooShort    = "too_short"
+	CodeInvalidChar = "invalid_characters"
+	CodeWeak        = "weak_password"
+)
+
+// метод проверяет текстовое поле: обрезает пробелы,
+// required - поле не может быть пустым, max - максимальная длина в символах
+func (e *ValidationError) text(field string, value *string, required bool, max int) {
+	*value = strings.TrimSpace(*value)
+
+	if required && *value == "" {
+		e.Add(field, CodeRequired, "field is required")
+		return
+	}
+	if utf8.RuneCountInString(*value) > max {
+		e.Add(field, CodeTooLong, fmt.Sprintf("must be at most %d characters", max))
+	}
+}
+
+// метод проверяет приоритет задачи
+func (e *ValidationError) priority(field string, value int) {
+	if value < PriorityNone || value > PriorityHigh {
+		e.Add(field, CodeOutOfRange, fmt.Sprintf("priority must be between %d and %d", PriorityNone, PriorityHigh))
+	}
+}
+
+// метод проверяет метки задачи: обрезает пробелы, убирает повторы,
+// метки не могут быть пустыми и длиннее MaxLabelLength
+func (e *ValidationError) labels(field string, labels *Labels) {
+	seen := make(map[string]bool, len(*labels))
+	result := make(Labels, 0, len(*labels))
+
+	for _, label := range *labels {
+		label = strings.TrimSpace(label)
+		if label == "" {
+			e.Add(field, CodeInvalid, "labels must not be empty")
+			return
+		}
+		if utf8.RuneCountInString(label) > MaxLabelLength {
+			e.Add(field, CodeTooLong, fmt.Sprintf("labels must be at most %d characters", MaxLabelLength))
+			return
+		}
+		if !seen[label] {
+			seen[label] = true
+			result = append(result, label)
+		}
+	}
+
+	if len(result) > MaxLabels {
+		e.Add(field, CodeOutOfRange, fmt.Sprintf("at most %d labels are allowed", MaxLabels))
+		return
+	}
+
+	*labels = result
+}
+
+// метод проверяет интервал времени фильтра: нижняя граница не может быть позже верхней
+func (e *ValidationError) timeRange(afterField, beforeField string, after, before *time.Time) {
+	if after != nil && before != nil && after.After(*before) {
+		e.Add(afterField, CodeOutOfRange, fmt.Sprintf("%s must not be later than %s", afterField, beforeField))
+	}
+}
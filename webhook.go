@@ -0,0 +1,204 @@
+package todo
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Описываем вебхуки: подписки пользователя на события (event.go),
+// которые доставляются POST-запросом на url пользователя.
+// Вебхук получает события списков, участником которых является пользователь,
+// list_id ограничивает вебхук одним списком, events - фильтр по типам событий
+// (пустой фильтр - все события).
+// Тело запроса подписывается HMAC-SHA256 секретом вебхука,
+// секрет возвращается только при создании вебхука.
+// После DisableAfter неудачных доставок подряд вебхук отключается (active = false).
+type Webhook struct {
+	Id           int        `json:"id" db:"id"`
+	Url          string     `json:"url" db:"url"`
+	ListId       *int       `json:"list_id" db:"list_id"`
+	Events       EventTypes `json:"events" db:"events"`
+	Active       bool       `json:"active" db:"active"`
+	Secret       string     `json:"secret,omitempty" db:"secret"`
+	FailureCount int        `json:"failure_count" db:"failure_count"`
+	DisabledAt   *time.Time `json:"disabled_at" db:"disabled_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// максимальная длина url вебхука
+const MaxWebhookUrlLength = 2048
+
+// типы событий в фильтре вебхука. Хранятся в колонке text[],
+// в json пустой фильтр выводится как [], а не null.
+type EventTypes []string
+
+func (t EventTypes) Value() (driver.Value, error) {
+	if t == nil {
+		return "{}", nil
+	}
+	return pq.StringArray(t).Value()
+}
+
+func (t *EventTypes) Scan(src interface{}) error {
+	var array pq.StringArray
+	if err := array.Scan(src); err != nil {
+		return err
+	}
+
+	*t = EventTypes(array)
+	return nil
+}
+
+func (t EventTypes) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]string(t))
+}
+
+// данные запроса на создание вебхука
+type WebhookInput struct {
+	Url    string     `json:"url" binding:"required"`
+	ListId *int       `json:"list_id"`
+	Events EventTypes `json:"events"`
+}
+
+// метод валидации данных запроса на создание вебхука
+// используется в сервисе webhook.go
+func (i *WebhookInput) Validate() error {
+	errs := &ValidationError{}
+
+	errs.webhookUrl("url", &i.Url)
+	errs.eventTypes("events", i.Events)
+
+	return errs.Err()
+}
+
+// данные запроса на изменение вебхука,
+// active = true включает отключенный вебхук и сбрасывает счетчик неудач
+type UpdateWebhookInput struct {
+	Url    *string     `json:"url"`
+	Events *EventTypes `json:"events"`
+	Active *bool       `json:"active"`
+}
+
+// метод валидации данных запроса на nil и заданных полей
+// используется в сервисе webhook.go
+func (i *UpdateWebhookInput) Validate() error {
+	if i.Url == nil && i.Events == nil && i.Active == nil {
+		return NewValidationError("", CodeRequired, "update structure has no values")
+	}
+
+	errs := &ValidationError{}
+	if i.Url != nil {
+		errs.webhookUrl("url", i.Url)
+	}
+	if i.Events != nil {
+		errs.eventTypes("events", *i.Events)
+	}
+
+	return errs.Err()
+}
+
+// статусы доставки: pending - ожидает отправки (в том числе повторной),
+// succeeded - получатель ответил 2xx, failed - попытки исчерпаны
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// доставка события вебхуку, payload - тело запроса
+type WebhookDelivery struct {
+	Id             int64           `json:"id" db:"id"`
+	WebhookId      int             `json:"webhook_id" db:"webhook_id"`
+	EventId        int64           `json:"event_id" db:"event_id"`
+	EventType      string          `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage `json:"payload" db:"payload" swaggertype:"object"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code" db:"last_status_code"`
+	LastError      *string         `json:"last_error" db:"last_error"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at" db:"delivered_at"`
+}
+
+// параметры выборки журнала доставок: от новых к старым,
+// cursor - next_cursor предыдущей страницы, status - фильтр по статусу
+type DeliveryQuery struct {
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
+	Status string `form:"status"`
+}
+
+// метод валидации параметров выборки журнала, проставляет значения по умолчанию
+// используется в сервисе webhook.go
+func (q *DeliveryQuery) Validate() error {
+	if q.Limit == 0 {
+		q.Limit = DefaultPageLimit
+	}
+	if q.Limit < 0 || q.Limit > MaxPageLimit {
+		return NewValidationError("limit", CodeOutOfRange, "limit must be between 1 and 100")
+	}
+
+	switch q.Status {
+	case "", DeliveryPending, DeliverySucceeded, DeliveryFailed:
+	default:
+		return NewValidationError("status", CodeUnsupported, "status must be pending, succeeded or failed")
+	}
+
+	return nil
+}
+
+// метод проверяет url вебхука: абсолютный http(s) адрес.
+// Адрес, заданный ip внутренней сети или localhost, отклоняется сразу,
+// имена хостов проверяются диспетчером при подключении (PublicIP)
+func (e *ValidationError) webhookUrl(field string, value *string) {
+	*value = strings.TrimSpace(*value)
+	if len(*value) > MaxWebhookUrlLength {
+		e.Add(field, CodeTooLong, fmt.Sprintf("must be at most %d characters", MaxWebhookUrlLength))
+		return
+	}
+
+	u, err := url.Parse(*value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		e.Add(field, CodeInvalid, "must be an absolute http or https url")
+		return
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if ip := net.ParseIP(host); ip != nil && !PublicIP(ip) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		e.Add(field, CodeInvalid, "must not point to a private, loopback or link-local address")
+	}
+}
+
+// адреса общего пространства NAT операторов связи (RFC 6598), не проверяемые методами net.IP
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// функция проверяет, что ip доступен из интернета. Вебхуки не отправляются
+// на адреса внутренней сети, loopback и link-local (в том числе сервис метаданных облака),
+// иначе пользователь мог бы отправлять запросы во внутреннюю сеть от имени сервера (SSRF)
+func PublicIP(ip net.IP) bool {
+	return !(ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip) || ip.To4() != nil && ip.To4()[0] == 0)
+}
+
+// метод проверяет фильтр событий: допускаются только известные типы
+func (e *ValidationError) eventTypes(field string, types EventTypes) {
+	for _, eventType := range types {
+		if !contains(AllEventTypes, eventType) {
+			e.Add(field, CodeUnsupported, fmt.Sprintf("unsupported event type %q", eventType))
+			return
+		}
+	}
+}